@@ -0,0 +1,99 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/st107853/fast_reading/models"
+)
+
+func TestAdminOnly(t *testing.T) {
+	app := newTestApp(t)
+
+	expect(t, app.guest().do(http.MethodGet, "/library/admin/users", nil), http.StatusUnauthorized)
+	expect(t, app.signIn(app.Reader.Email).do(http.MethodGet, "/library/admin/users", nil), http.StatusForbidden)
+}
+
+func TestAdminUserRoutes(t *testing.T) {
+	app := newTestApp(t)
+	admin := app.signIn(app.Admin.Email)
+
+	var list struct {
+		Data models.UserPage `json:"data"`
+	}
+	w := admin.do(http.MethodGet, "/library/admin/users?q=newcomer", nil)
+	expect(t, w, http.StatusOK)
+	decode(t, w, &list)
+	if list.Data.Total != 1 || list.Data.Users[0].ID != app.Newcomer.ID {
+		t.Errorf("users = %+v", list.Data.Users)
+	}
+
+	user := "/library/admin/users/" + itoa(app.Reader.ID)
+	expect(t, admin.do(http.MethodPut, user+"/role", gin.H{"role": "owner"}), http.StatusBadRequest)
+	expect(t, admin.do(http.MethodPut, user+"/role", gin.H{"role": models.RoleAdmin}), http.StatusOK)
+	expect(t, admin.do(http.MethodPut, "/library/admin/users/999/role", gin.H{"role": models.RoleAdmin}), http.StatusNotFound)
+	expect(t, admin.do(http.MethodPut, "/library/admin/users/"+itoa(app.Admin.ID)+"/role", gin.H{"role": models.RoleUser}), http.StatusBadRequest)
+
+	expect(t, admin.do(http.MethodPut, "/library/admin/users/"+itoa(app.Newcomer.ID)+"/verified", gin.H{"verified": true}), http.StatusOK)
+	expect(t, admin.do(http.MethodPut, "/library/admin/users/"+itoa(app.Newcomer.ID)+"/verified", gin.H{}), http.StatusBadRequest)
+
+	author := app.signIn(app.Author.Email)
+	expect(t, admin.do(http.MethodPut, "/library/admin/users/"+itoa(app.Author.ID)+"/banned", gin.H{"banned": true}), http.StatusOK)
+	expect(t, author.do(http.MethodGet, "/library/auth/refresh", nil), http.StatusForbidden)
+
+	var reader, newcomer models.User
+	app.db.First(&reader, app.Reader.ID)
+	app.db.First(&newcomer, app.Newcomer.ID)
+	if reader.Role != models.RoleAdmin || !newcomer.Verified {
+		t.Errorf("reader role %q, newcomer verified %v", reader.Role, newcomer.Verified)
+	}
+}
+
+func TestAdminBookRoutes(t *testing.T) {
+	app := newTestApp(t)
+	admin := app.signIn(app.Admin.Email)
+
+	expect(t, admin.do(http.MethodPut, "/library/admin/books/"+itoa(app.Fox.BookID)+"/unrelease", nil), http.StatusOK)
+	var fox models.Book
+	app.db.First(&fox, app.Fox.BookID)
+	if fox.Released {
+		t.Error("the book is still released")
+	}
+
+	expect(t, admin.do(http.MethodDelete, "/library/admin/books/"+itoa(app.Fox.BookID), nil), http.StatusOK)
+	expect(t, admin.do(http.MethodDelete, "/library/admin/books/"+itoa(app.Fox.BookID), nil), http.StatusNotFound)
+}
+
+func TestAdminLabelRoutes(t *testing.T) {
+	app := newTestApp(t)
+	admin := app.signIn(app.Admin.Email)
+
+	var created struct {
+		Data struct {
+			Label models.Label `json:"label"`
+		} `json:"data"`
+	}
+	w := admin.do(http.MethodPost, "/library/admin/labels", gin.H{"name": "Essays"})
+	expect(t, w, http.StatusCreated)
+	decode(t, w, &created)
+	expect(t, admin.do(http.MethodPost, "/library/admin/labels", gin.H{"name": "Fiction"}), http.StatusConflict)
+
+	essays := "/library/admin/labels/" + itoa(created.Data.Label.LabelID)
+	expect(t, admin.do(http.MethodPut, essays, gin.H{"name": "Short Essays"}), http.StatusOK)
+	expect(t, admin.do(http.MethodPut, essays, gin.H{"name": "Poetry"}), http.StatusConflict)
+
+	poetry := "/library/admin/labels/" + itoa(app.Poetry.LabelID)
+	expect(t, admin.do(http.MethodPost, poetry+"/merge", gin.H{"into": app.Poetry.LabelID}), http.StatusBadRequest)
+	expect(t, admin.do(http.MethodPost, poetry+"/merge", gin.H{"into": app.Fiction.LabelID}), http.StatusOK)
+
+	var poems models.Book
+	app.db.Preload("BookLabels").First(&poems, app.Poems.BookID)
+	if len(poems.BookLabels) != 1 || poems.BookLabels[0].LabelID != app.Fiction.LabelID {
+		t.Errorf("merged book has labels %+v", poems.BookLabels)
+	}
+
+	expect(t, admin.do(http.MethodDelete, essays, nil), http.StatusOK)
+	expect(t, admin.do(http.MethodDelete, essays, nil), http.StatusNotFound)
+	expect(t, admin.do(http.MethodDelete, "/library/admin/labels/abc", nil), http.StatusBadRequest)
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/st107853/fast_reading/controllers"
+	"github.com/st107853/fast_reading/models"
+)
+
+// apiError is the error envelope of the JSON API.
+type apiError struct {
+	Status string `json:"status"`
+	Error  struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func expectAPIError(t *testing.T, c *client, method, path string, status int, code string) {
+	t.Helper()
+
+	w := c.do(method, path, nil)
+	expect(t, w, status)
+	var res apiError
+	decode(t, w, &res)
+	if res.Status != "fail" || res.Error.Code != code || res.Error.Message == "" {
+		t.Errorf("%s %s: error %+v, want a fail with code %q", method, path, res, code)
+	}
+}
+
+func TestAPICatalog(t *testing.T) {
+	app := newTestApp(t)
+	guest := app.guest()
+
+	var catalog struct {
+		Data struct {
+			Books        []models.BookBase `json:"books"`
+			Labels       []models.Label    `json:"labels"`
+			LastReleased []models.BookBase `json:"last_released"`
+		} `json:"data"`
+	}
+	w := guest.do(http.MethodGet, "/api/v1/catalog", nil)
+	expect(t, w, http.StatusOK)
+	decode(t, w, &catalog)
+	if len(catalog.Data.Books) != 2 || len(catalog.Data.Labels) != 3 || catalog.Data.LastReleased[0].Name != app.Poems.Name {
+		t.Errorf("catalog = %+v", catalog.Data)
+	}
+
+	var labels struct {
+		Data struct {
+			Labels []models.Label `json:"labels"`
+		} `json:"data"`
+	}
+	w = guest.do(http.MethodGet, "/api/v1/labels", nil)
+	expect(t, w, http.StatusOK)
+	decode(t, w, &labels)
+	if len(labels.Data.Labels) != 3 {
+		t.Errorf("labels = %+v", labels.Data.Labels)
+	}
+
+	var page struct {
+		Data models.BookPage `json:"data"`
+	}
+	w = guest.do(http.MethodGet, "/api/v1/books?labels="+itoa(app.Poetry.LabelID), nil)
+	expect(t, w, http.StatusOK)
+	decode(t, w, &page)
+	if page.Data.Total != 1 || page.Data.Books[0].BookID != app.Poems.BookID {
+		t.Errorf("books labelled Poetry = %+v", page.Data.Books)
+	}
+	expectAPIError(t, guest, http.MethodGet, "/api/v1/books?code=2", http.StatusUnauthorized, "unauthorized")
+	expectAPIError(t, guest, http.MethodGet, "/api/v1/books?labels=x", http.StatusBadRequest, "invalid_request")
+
+	var search struct {
+		Data models.SearchPage `json:"data"`
+	}
+	w = guest.do(http.MethodGet, "/api/v1/search?q=poems", nil)
+	expect(t, w, http.StatusOK)
+	decode(t, w, &search)
+	if search.Data.Total != 1 || search.Data.Hits[0].Book.BookID != app.Poems.BookID {
+		t.Errorf("search hits = %+v", search.Data.Hits)
+	}
+	expectAPIError(t, guest, http.MethodGet, "/api/v1/search", http.StatusBadRequest, "invalid_request")
+}
+
+func TestAPIBook(t *testing.T) {
+	app := newTestApp(t)
+	c := app.signIn(app.Reader.Email)
+	c.token = c.cookie("access_token")
+	fox := "/api/v1/books/" + itoa(app.Fox.BookID)
+
+	expect(t, c.do(http.MethodPut, "/library/"+itoa(app.Fox.BookID)+"/2/5", nil), http.StatusOK)
+
+	var book struct {
+		Data struct {
+			Book models.BookDetail `json:"book"`
+		} `json:"data"`
+	}
+	w := c.do(http.MethodGet, fox, nil)
+	expect(t, w, http.StatusOK)
+	decode(t, w, &book)
+	if len(book.Data.Book.Chapters) != 2 || book.Data.Book.Progress.ChapterID != 2 || book.Data.Book.IsCreator {
+		t.Errorf("book = %+v", book.Data.Book)
+	}
+	expectAPIError(t, c, http.MethodGet, "/api/v1/books/999", http.StatusNotFound, "not_found")
+
+	var chapter struct {
+		Data struct {
+			Chapter models.Chapter `json:"chapter"`
+		} `json:"data"`
+	}
+	w = c.do(http.MethodGet, fox+"/chapters/2", nil)
+	expect(t, w, http.StatusOK)
+	decode(t, w, &chapter)
+	if chapter.Data.Chapter.Title != app.FoxChapters[1].Title {
+		t.Errorf("chapter = %+v", chapter.Data.Chapter)
+	}
+	expectAPIError(t, c, http.MethodGet, fox+"/chapters/9", http.StatusNotFound, "not_found")
+
+	var plan struct {
+		Data struct {
+			ChunkSize int `json:"chunk_size"`
+		} `json:"data"`
+	}
+	w = c.do(http.MethodGet, fox+"/chapters/1/tokens?chunk=3", nil)
+	expect(t, w, http.StatusOK)
+	decode(t, w, &plan)
+	if plan.Data.ChunkSize != 3 {
+		t.Errorf("chunk size %d, want 3", plan.Data.ChunkSize)
+	}
+}
+
+func TestAPIBundle(t *testing.T) {
+	app := newTestApp(t)
+	c := app.signIn(app.Reader.Email)
+	bundle := "/api/v1/books/" + itoa(app.Fox.BookID) + "/bundle"
+
+	var res struct {
+		Data controllers.BookBundle `json:"data"`
+	}
+	w := c.do(http.MethodGet, bundle+"?chunk=3", nil)
+	expect(t, w, http.StatusOK)
+	decode(t, w, &res)
+	if len(res.Data.Chapters) != 2 || res.Data.Version == "" {
+		t.Fatalf("bundle = %+v", res.Data)
+	}
+
+	// The bundle holds the plan of the tokens endpoint without repeating the text
+	var plan struct {
+		Data controllers.ReaderPlan `json:"data"`
+	}
+	first := res.Data.Chapters[0]
+	w = c.do(http.MethodGet, "/api/v1/books/"+itoa(app.Fox.BookID)+"/chapters/"+itoa(uint(first.ChapterOrder))+"/tokens?chunk=3", nil)
+	expect(t, w, http.StatusOK)
+	decode(t, w, &plan)
+	words := first.Words
+	if len(words.Offsets) != len(plan.Data.Tokens) || len(first.Chunks.Sizes) != len(plan.Data.Chunks) {
+		t.Fatalf("bundle has %d words and %d chunks, tokens endpoint %d and %d",
+			len(words.Offsets), len(first.Chunks.Sizes), len(plan.Data.Tokens), len(plan.Data.Chunks))
+	}
+	text := []rune(first.Text)
+	for i, token := range plan.Data.Tokens {
+		word := string(text[words.Offsets[i] : words.Offsets[i]+len([]rune(token.Word))])
+		if word != token.Word || words.Pivots[i] != token.Pivot || words.Delays[i] != token.Delay {
+			t.Errorf("word %d = %q/%d/%v, want %+v", i, word, words.Pivots[i], words.Delays[i], token)
+		}
+	}
+	for i, chunk := range plan.Data.Chunks {
+		if first.Chunks.Sizes[i] != chunk.Words {
+			t.Errorf("chunk %d has %d words, want %d", i, first.Chunks.Sizes[i], chunk.Words)
+		}
+	}
+	w = c.do(http.MethodGet, bundle, nil)
+	expect(t, w, http.StatusOK)
+
+	etag := w.Header().Get("ETag")
+	revalidate := func() *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, bundle, nil)
+		r.Header.Set("If-None-Match", etag)
+		return c.send(r)
+	}
+	expect(t, revalidate(), http.StatusNotModified)
+
+	// Moving the bookmark changes the bundle
+	expect(t, c.do(http.MethodPut, "/library/"+itoa(app.Fox.BookID)+"/2/1", nil), http.StatusOK)
+	expect(t, revalidate(), http.StatusOK)
+}
+
+func TestAPIProgress(t *testing.T) {
+	app := newTestApp(t)
+	c := app.signIn(app.Reader.Email)
+
+	marks := gin.H{"bookmarks": []gin.H{
+		{"book_id": app.Fox.BookID, "chapter_id": 2, "last_index": 3, "updated_at": time.Now().Add(-time.Minute)},
+		{"book_id": 999, "chapter_id": 1, "last_index": 0, "updated_at": time.Now()},
+	}}
+	var sync struct {
+		Data models.SyncResult `json:"data"`
+	}
+	w := c.do(http.MethodPost, "/api/v1/progress/sync", marks)
+	expect(t, w, http.StatusOK)
+	decode(t, w, &sync)
+	if sync.Data.Applied != 1 || len(sync.Data.Rejected) != 1 {
+		t.Errorf("sync = %+v", sync.Data)
+	}
+	expect(t, app.guest().do(http.MethodPost, "/api/v1/progress/sync", marks), http.StatusUnauthorized)
+
+	var page struct {
+		Data models.BookPage `json:"data"`
+	}
+	w = c.do(http.MethodGet, "/api/v1/continue", nil)
+	expect(t, w, http.StatusOK)
+	decode(t, w, &page)
+	if page.Data.Total != 1 || page.Data.Books[0].BookID != app.Fox.BookID {
+		t.Errorf("started books = %+v", page.Data.Books)
+	}
+	expectAPIError(t, app.guest(), http.MethodGet, "/api/v1/continue", http.StatusUnauthorized, "unauthorized")
+}
+
+func TestAPIMe(t *testing.T) {
+	app := newTestApp(t)
+	c := app.signIn(app.Author.Email)
+
+	var me struct {
+		Data struct {
+			User         models.UserResponse `json:"user"`
+			CreatedBooks []models.BookBase   `json:"created_books"`
+		} `json:"data"`
+	}
+	w := c.do(http.MethodGet, "/api/v1/users/me", nil)
+	expect(t, w, http.StatusOK)
+	decode(t, w, &me)
+	if me.Data.User.ID != app.Author.ID || len(me.Data.CreatedBooks) != 2 {
+		t.Errorf("me = %+v", me.Data)
+	}
+	expectAPIError(t, app.guest(), http.MethodGet, "/api/v1/users/me", http.StatusUnauthorized, "unauthorized")
+
+	// Requests the auth middleware rejects get the same envelope
+	forged := app.guest()
+	forged.token = "not.a.token"
+	expectAPIError(t, forged, http.MethodGet, "/api/v1/users/me", http.StatusUnauthorized, "unauthorized")
+
+	stolen := app.guest()
+	stolen.token = c.cookie("access_token")
+	expect(t, c.do(http.MethodGet, "/library/auth/logout", nil), http.StatusOK)
+	expectAPIError(t, stolen, http.MethodGet, "/api/v1/users/me", http.StatusUnauthorized, "unauthorized")
+
+	banned := app.signIn(app.Reader.Email)
+	app.db.Model(app.Reader).Update("banned_at", app.Reader.CreatedAt)
+	expectAPIError(t, banned, http.MethodGet, "/api/v1/users/me", http.StatusForbidden, "forbidden")
+}
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/st107853/fast_reading/config"
+	"github.com/st107853/fast_reading/mailer"
+	"github.com/st107853/fast_reading/storage"
+	"github.com/st107853/fast_reading/testutil"
+	"gorm.io/gorm"
+)
+
+// TestMain fails the run if a route registered in newServer was never
+// requested by any test. The check is skipped when -run picks a subset.
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	gin.DefaultWriter = io.Discard
+	flag.Parse()
+
+	code := m.Run()
+	if code == 0 && flag.Lookup("test.run").Value.String() == "" {
+		if missed := unrequestedRoutes(); len(missed) > 0 {
+			fmt.Fprintf(os.Stderr, "routes no test requested:\n\t%s\n", strings.Join(missed, "\n\t"))
+			code = 1
+		}
+	}
+	os.Exit(code)
+}
+
+// testApp is the whole application on a seeded SQLite database, with mail
+// kept in memory and covers stored in a temporary directory.
+type testApp struct {
+	t      *testing.T
+	server *gin.Engine
+	db     *gorm.DB
+	conf   config.Config
+	mail   *mailer.LogMailer
+	blobs  storage.BlobStore
+	*testutil.Fixtures
+}
+
+func newTestApp(t *testing.T) *testApp {
+	t.Helper()
+
+	db := testutil.OpenDB(t)
+	app := &testApp{
+		t:        t,
+		db:       db,
+		conf:     testutil.Config(t),
+		mail:     mailer.NewLogMailer(nil),
+		blobs:    storage.NewLocalStore(t.TempDir(), ""),
+		Fixtures: testutil.Seed(t, db),
+	}
+	app.server = newServer(db, app.conf, app.mail, app.blobs)
+	return app
+}
+
+// client makes requests as a browser would: cookies set by responses are
+// sent back with later requests.
+type client struct {
+	app *testApp
+	jar *cookiejar.Jar
+	// token, if set, is sent as a Bearer token instead of relying on cookies.
+	token string
+}
+
+var baseURL, _ = url.Parse("http://localhost:8080")
+
+// guest returns a client without a session.
+func (app *testApp) guest() *client {
+	jar, _ := cookiejar.New(nil)
+	return &client{app: app, jar: jar}
+}
+
+// signIn logs in with a fixture password through the login route.
+func (app *testApp) signIn(email string) *client {
+	app.t.Helper()
+
+	c := app.guest()
+	w := c.do(http.MethodPost, "/library/auth/login", gin.H{"email": email, "password": testutil.Password})
+	if w.Code != http.StatusOK {
+		app.t.Fatalf("sign in %s: status %d: %s", email, w.Code, w.Body)
+	}
+	return c
+}
+
+// do sends body, if any, as JSON.
+func (c *client) do(method, path string, body interface{}) *httptest.ResponseRecorder {
+	c.app.t.Helper()
+
+	var r io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			c.app.t.Fatal(err)
+		}
+		r = bytes.NewReader(data)
+	}
+
+	req := httptest.NewRequest(method, path, r)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return c.send(req)
+}
+
+// upload sends a multipart form with files given as field name → file name
+// and content.
+func (c *client) upload(method, path string, fields map[string]string, files map[string][2]string) *httptest.ResponseRecorder {
+	c.app.t.Helper()
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for name, value := range fields {
+		mw.WriteField(name, value)
+	}
+	for name, file := range files {
+		fw, err := mw.CreateFormFile(name, file[0])
+		if err != nil {
+			c.app.t.Fatal(err)
+		}
+		fw.Write([]byte(file[1]))
+	}
+	mw.Close()
+
+	req := httptest.NewRequest(method, path, &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return c.send(req)
+}
+
+func (c *client) send(req *http.Request) *httptest.ResponseRecorder {
+	for _, cookie := range c.jar.Cookies(baseURL) {
+		req.AddCookie(cookie)
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	w := httptest.NewRecorder()
+	c.app.server.ServeHTTP(w, req)
+	c.jar.SetCookies(baseURL, w.Result().Cookies())
+
+	recordRequest(c.app.server, req.Method, req.URL.Path)
+	return w
+}
+
+// cookie returns the value of a cookie the client holds.
+func (c *client) cookie(name string) string {
+	for _, cookie := range c.jar.Cookies(baseURL) {
+		if cookie.Name == name {
+			return cookie.Value
+		}
+	}
+	return ""
+}
+
+// expect fails the test unless the response has the given status.
+func expect(t *testing.T, w *httptest.ResponseRecorder, status int) {
+	t.Helper()
+	if w.Code != status {
+		t.Fatalf("status %d, want %d: %s", w.Code, status, w.Body)
+	}
+}
+
+// decode reads a JSON response into v.
+func decode(t *testing.T, w *httptest.ResponseRecorder, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+		t.Fatalf("decode %s: %v", w.Body, err)
+	}
+}
+
+func itoa(id uint) string {
+	return strconv.FormatUint(uint64(id), 10)
+}
+
+var (
+	requestedMu sync.Mutex
+	requested   = map[string]bool{}
+)
+
+// recordRequest marks the route a request was routed to as covered.
+func recordRequest(server *gin.Engine, method, path string) {
+	if route, ok := matchRoute(server.Routes(), method, path); ok {
+		requestedMu.Lock()
+		requested[route] = true
+		requestedMu.Unlock()
+	}
+}
+
+// matchRoute finds the registered route gin serves path with. Like gin's
+// router, a static segment wins over a parameter.
+func matchRoute(routes gin.RoutesInfo, method, path string) (string, bool) {
+	best, bestScore := "", -1
+	for _, r := range routes {
+		if r.Method != method {
+			continue
+		}
+		if score, ok := matchPath(r.Path, path); ok && score > bestScore {
+			best, bestScore = r.Method+" "+r.Path, score
+		}
+	}
+	return best, bestScore >= 0
+}
+
+// matchPath reports whether path fits pattern and scores the match by its
+// number of static segments.
+func matchPath(pattern, path string) (int, bool) {
+	want := strings.Split(strings.Trim(pattern, "/"), "/")
+	got := strings.Split(strings.Trim(path, "/"), "/")
+
+	score := 0
+	for i, segment := range want {
+		if strings.HasPrefix(segment, "*") {
+			return score, true
+		}
+		if i >= len(got) {
+			return 0, false
+		}
+		switch {
+		case strings.HasPrefix(segment, ":"):
+			if got[i] == "" {
+				return 0, false
+			}
+		case segment != got[i]:
+			return 0, false
+		default:
+			score++
+		}
+	}
+	return score, len(want) == len(got)
+}
+
+// unrequestedRoutes lists the routes of routes/*.go no test requested.
+func unrequestedRoutes() []string {
+	requestedMu.Lock()
+	defer requestedMu.Unlock()
+
+	var missed []string
+	for _, r := range newServer(nil, config.Config{}, nil, nil).Routes() {
+		// The static file server is set up in newServer, not in routes/*.go
+		if strings.HasPrefix(r.Path, "/static/") {
+			continue
+		}
+		if !requested[r.Method+" "+r.Path] {
+			missed = append(missed, r.Method+" "+r.Path)
+		}
+	}
+	sort.Strings(missed)
+	return missed
+}
+
+func TestMatchRoute(t *testing.T) {
+	routes := gin.RoutesInfo{
+		{Method: "GET", Path: "/library/book/:book_id/:chapter_id/:last_index"},
+		{Method: "GET", Path: "/library/book/:book_id/:chapter_id/tokens"},
+		{Method: "GET", Path: "/library/book/:book_id/export.epub"},
+		{Method: "GET", Path: "/library/filter/"},
+		{Method: "GET", Path: "/covers/*name"},
+	}
+
+	tests := []struct{ path, want string }{
+		{"/library/book/1/2/30", "GET /library/book/:book_id/:chapter_id/:last_index"},
+		{"/library/book/1/2/tokens", "GET /library/book/:book_id/:chapter_id/tokens"},
+		{"/library/book/1/export.epub", "GET /library/book/:book_id/export.epub"},
+		{"/library/filter/", "GET /library/filter/"},
+		{"/covers/ab/cd.png", "GET /covers/*name"},
+		{"/library/book/1", ""},
+	}
+	for _, tt := range tests {
+		if got, _ := matchRoute(routes, "GET", tt.path); got != tt.want {
+			t.Errorf("%s matched %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
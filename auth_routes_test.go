@@ -0,0 +1,171 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/st107853/fast_reading/testutil"
+)
+
+// mailedToken returns what follows prefix in the last message sent to to.
+func (app *testApp) mailedToken(to, prefix string) string {
+	app.t.Helper()
+
+	messages := app.mail.Messages()
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].To != to {
+			continue
+		}
+		_, rest, found := strings.Cut(messages[i].Body, prefix)
+		if !found {
+			app.t.Fatalf("message to %s has no %s link", to, prefix)
+		}
+		token, _, _ := strings.Cut(rest, "\n")
+		return token
+	}
+	app.t.Fatalf("no message was sent to %s", to)
+	return ""
+}
+
+func TestRegisterAndVerify(t *testing.T) {
+	app := newTestApp(t)
+	c := app.guest()
+
+	body := gin.H{"name": "Sam", "email": "sam@example.com", "password": "secret-pass", "passwordConfirm": "secret-pass"}
+	expect(t, c.do(http.MethodPost, "/library/auth/register", body), http.StatusCreated)
+	expect(t, c.do(http.MethodPost, "/library/auth/register", body), http.StatusConflict)
+
+	body["passwordConfirm"] = "something-else"
+	body["email"] = "other@example.com"
+	expect(t, c.do(http.MethodPost, "/library/auth/register", body), http.StatusBadRequest)
+
+	token := app.mailedToken("sam@example.com", "/library/auth/verify/")
+	expect(t, c.do(http.MethodGet, "/library/auth/verify/"+token, nil), http.StatusOK)
+	expect(t, c.do(http.MethodGet, "/library/auth/verify/"+token, nil), http.StatusBadRequest)
+
+	expect(t, c.do(http.MethodPost, "/library/auth/verify/resend", gin.H{"email": app.Newcomer.Email}), http.StatusOK)
+	token = app.mailedToken(app.Newcomer.Email, "/library/auth/verify/")
+	expect(t, c.do(http.MethodGet, "/library/auth/verify/"+token, nil), http.StatusOK)
+	expect(t, c.do(http.MethodPost, "/library/auth/verify/resend", gin.H{"email": "not an email"}), http.StatusBadRequest)
+}
+
+func TestLoginRefreshLogout(t *testing.T) {
+	app := newTestApp(t)
+
+	w := app.guest().do(http.MethodGet, "/library/auth/login", nil)
+	expect(t, w, http.StatusOK)
+	if !strings.Contains(w.Body.String(), "<form") {
+		t.Error("login page has no form")
+	}
+
+	bad := gin.H{"email": app.Reader.Email, "password": "wrong"}
+	expect(t, app.guest().do(http.MethodPost, "/library/auth/login", bad), http.StatusBadRequest)
+
+	c := app.signIn(app.Reader.Email)
+	if c.cookie("access_token") == "" || c.cookie("refresh_token") == "" {
+		t.Fatal("login set no token cookies")
+	}
+
+	old := c.cookie("refresh_token")
+	expect(t, c.do(http.MethodGet, "/library/auth/refresh", nil), http.StatusOK)
+	if c.cookie("refresh_token") == old {
+		t.Error("refresh did not rotate the refresh token")
+	}
+	expect(t, app.guest().do(http.MethodGet, "/library/auth/refresh", nil), http.StatusForbidden)
+
+	// A copy of the access token taken before logout
+	stolen := app.guest()
+	stolen.token = c.cookie("access_token")
+	expect(t, stolen.do(http.MethodGet, "/library/users/me/preferences", nil), http.StatusOK)
+
+	expect(t, c.do(http.MethodGet, "/library/auth/logout", nil), http.StatusOK)
+	if c.cookie("access_token") != "" {
+		t.Error("logout left the access token cookie")
+	}
+	expect(t, c.do(http.MethodGet, "/library/auth/refresh", nil), http.StatusForbidden)
+	expect(t, stolen.do(http.MethodGet, "/library/users/me/preferences", nil), http.StatusUnauthorized)
+}
+
+func TestBannedUserCannotLogIn(t *testing.T) {
+	app := newTestApp(t)
+
+	c := app.signIn(app.Reader.Email)
+	app.db.Model(app.Reader).Update("banned_at", app.Reader.CreatedAt)
+
+	credentials := gin.H{"email": app.Reader.Email, "password": testutil.Password}
+	expect(t, app.guest().do(http.MethodPost, "/library/auth/login", credentials), http.StatusForbidden)
+	expect(t, c.do(http.MethodGet, "/library/users/me/preferences", nil), http.StatusForbidden)
+}
+
+func TestPasswordReset(t *testing.T) {
+	app := newTestApp(t)
+	c := app.guest()
+	// Someone else is signed in to the account being recovered
+	intruder := app.signIn(app.Reader.Email)
+
+	expect(t, c.do(http.MethodPost, "/library/auth/forgot-password", gin.H{"email": app.Reader.Email}), http.StatusOK)
+	expect(t, c.do(http.MethodPost, "/library/auth/forgot-password", gin.H{"email": "nobody@example.com"}), http.StatusOK)
+	token := app.mailedToken(app.Reader.Email, "reset_token=")
+
+	mismatch := gin.H{"token": token, "password": "a-new-password", "passwordConfirm": "another-password"}
+	expect(t, c.do(http.MethodPost, "/library/auth/reset-password", mismatch), http.StatusBadRequest)
+
+	reset := gin.H{"token": token, "password": "a-new-password", "passwordConfirm": "a-new-password"}
+	expect(t, c.do(http.MethodPost, "/library/auth/reset-password", reset), http.StatusOK)
+	expect(t, c.do(http.MethodPost, "/library/auth/reset-password", reset), http.StatusBadRequest)
+	expect(t, intruder.do(http.MethodGet, "/library/users/me/preferences", nil), http.StatusUnauthorized)
+
+	login := gin.H{"email": app.Reader.Email, "password": "a-new-password"}
+	expect(t, c.do(http.MethodPost, "/library/auth/login", login), http.StatusOK)
+}
+
+func TestSessionRoutes(t *testing.T) {
+	app := newTestApp(t)
+
+	phone := app.signIn(app.Reader.Email)
+	laptop := app.signIn(app.Reader.Email)
+	tablet := app.signIn(app.Reader.Email)
+	// A Bearer client has no refresh token cookie to tell its session by
+	api := app.guest()
+	api.token = app.signIn(app.Reader.Email).cookie("access_token")
+
+	var list struct {
+		Data struct {
+			Sessions []struct {
+				ID      uint `json:"id"`
+				Current bool `json:"current"`
+			} `json:"sessions"`
+		} `json:"data"`
+	}
+	w := api.do(http.MethodGet, "/library/auth/sessions", nil)
+	expect(t, w, http.StatusOK)
+	decode(t, w, &list)
+	if len(list.Data.Sessions) != 4 {
+		t.Fatalf("%d sessions, want 4", len(list.Data.Sessions))
+	}
+
+	var other uint
+	current := 0
+	for _, s := range list.Data.Sessions {
+		if s.Current {
+			current++
+		} else {
+			other = s.ID
+		}
+	}
+	if current != 1 {
+		t.Errorf("%d sessions are marked current, want 1", current)
+	}
+	expect(t, laptop.do(http.MethodDelete, "/library/auth/sessions/"+itoa(other), nil), http.StatusOK)
+	expect(t, laptop.do(http.MethodDelete, "/library/auth/sessions/"+itoa(other+100), nil), http.StatusNotFound)
+	expect(t, api.do(http.MethodDelete, "/library/auth/sessions", nil), http.StatusOK)
+
+	for _, c := range []*client{phone, laptop, tablet} {
+		expect(t, c.do(http.MethodGet, "/library/users/me/preferences", nil), http.StatusUnauthorized)
+		expect(t, c.do(http.MethodGet, "/library/auth/refresh", nil), http.StatusForbidden)
+	}
+	expect(t, api.do(http.MethodGet, "/library/users/me/preferences", nil), http.StatusOK)
+	expect(t, app.guest().do(http.MethodGet, "/library/auth/sessions", nil), http.StatusUnauthorized)
+}
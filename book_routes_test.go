@@ -0,0 +1,342 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/st107853/fast_reading/models"
+)
+
+// pngCover returns a small PNG image to upload as a cover.
+func pngCover(t *testing.T) string {
+	t.Helper()
+
+	img := image.NewRGBA(image.Rect(0, 0, 60, 90))
+	for x := 0; x < 60; x++ {
+		img.Set(x, x, color.RGBA{B: 200, A: 255})
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+// createdBook reads the book_id of a create or import response and loads
+// the book.
+func (app *testApp) createdBook(w *httptest.ResponseRecorder) models.Book {
+	app.t.Helper()
+
+	var res struct {
+		BookID uint `json:"book_id"`
+	}
+	decode(app.t, w, &res)
+
+	var book models.Book
+	if err := app.db.Preload("BookLabels").First(&book, res.BookID).Error; err != nil {
+		app.t.Fatalf("load book %d: %v", res.BookID, err)
+	}
+	return book
+}
+
+// chapters returns the chapters of a book in reading order.
+func (app *testApp) chapters(bookID uint) []models.Chapter {
+	var chapters []models.Chapter
+	app.db.Where("book_id = ?", bookID).Order("chapter_order").Find(&chapters)
+	return chapters
+}
+
+func TestCatalogPages(t *testing.T) {
+	app := newTestApp(t)
+	c := app.signIn(app.Reader.Email)
+
+	w := app.guest().do(http.MethodGet, "/library/", nil)
+	expect(t, w, http.StatusOK)
+	if !strings.Contains(w.Body.String(), app.Fox.Name) || strings.Contains(w.Body.String(), app.Draft.Name) {
+		t.Error("main page should list released books only")
+	}
+
+	expect(t, c.do(http.MethodPut, "/library/"+itoa(app.Fox.BookID)+"/1/4", nil), http.StatusOK)
+	w = c.do(http.MethodGet, "/library/continue", nil)
+	expect(t, w, http.StatusOK)
+	if !strings.Contains(w.Body.String(), app.Fox.Name) {
+		t.Error("continue page does not list the started book")
+	}
+
+	var page models.BookPage
+	w = c.do(http.MethodGet, "/library/filter/?code=0&sort=name", nil)
+	expect(t, w, http.StatusOK)
+	decode(t, w, &page)
+	if page.Total != 2 || page.Books[0].Name != app.Poems.Name {
+		t.Errorf("released books by name = %+v", page.Books)
+	}
+	expect(t, c.do(http.MethodGet, "/library/filter/?sort=colour", nil), http.StatusBadRequest)
+}
+
+func TestBookReaderRoutes(t *testing.T) {
+	app := newTestApp(t)
+	c := app.signIn(app.Reader.Email)
+	fox := "/library/book/" + itoa(app.Fox.BookID)
+
+	w := c.do(http.MethodGet, fox, nil)
+	expect(t, w, http.StatusOK)
+	if !strings.Contains(w.Body.String(), app.FoxChapters[1].Title) {
+		t.Error("book page does not list its chapters")
+	}
+	expect(t, app.guest().do(http.MethodGet, "/library/book/999", nil), http.StatusNotFound)
+
+	w = c.do(http.MethodGet, fox+"/2/0", nil)
+	expect(t, w, http.StatusOK)
+	if !strings.Contains(w.Body.String(), "the dog was still asleep") {
+		t.Error("reader page does not show the chapter text")
+	}
+	expect(t, c.do(http.MethodGet, fox+"/3/0", nil), http.StatusNotFound)
+
+	var plan struct {
+		ChunkSize int `json:"chunk_size"`
+		Tokens    []struct {
+			Word string `json:"word"`
+		} `json:"tokens"`
+		Chunks []struct {
+			Text string `json:"text"`
+		} `json:"chunks"`
+	}
+	w = c.do(http.MethodGet, fox+"/1/tokens?chunk=2", nil)
+	expect(t, w, http.StatusOK)
+	decode(t, w, &plan)
+	if plan.ChunkSize != 2 || plan.Tokens[0].Word != "The" || len(plan.Chunks) >= len(plan.Tokens) {
+		t.Errorf("plan has chunk size %d, %d tokens and %d chunks", plan.ChunkSize, len(plan.Tokens), len(plan.Chunks))
+	}
+	expect(t, c.do(http.MethodGet, fox+"/1/tokens?chunk=9", nil), http.StatusBadRequest)
+
+	expect(t, c.do(http.MethodPost, fox+"/favourite", nil), http.StatusCreated)
+	var favourites int64
+	app.db.Table("user_favorites").Where("user_id = ? AND book_id = ?", app.Reader.ID, app.Fox.BookID).Count(&favourites)
+	if favourites != 1 {
+		t.Error("the book was not added to the favourites")
+	}
+	expect(t, app.guest().do(http.MethodPost, fox+"/favourite", nil), http.StatusUnauthorized)
+
+	var mark models.ReadingProgress
+	expect(t, c.do(http.MethodPut, "/library/"+itoa(app.Fox.BookID)+"/2/17", nil), http.StatusOK)
+	app.db.Where("user_id = ? AND book_id = ?", app.Reader.ID, app.Fox.BookID).First(&mark)
+	if mark.ChapterID != 2 || mark.LastIndex != 17 {
+		t.Errorf("bookmark = %+v", mark)
+	}
+	expect(t, app.guest().do(http.MethodPut, "/library/"+itoa(app.Fox.BookID)+"/2/20", nil), http.StatusUnauthorized)
+}
+
+func TestCreateBookRoutes(t *testing.T) {
+	app := newTestApp(t)
+	c := app.signIn(app.Reader.Email)
+
+	w := c.do(http.MethodGet, "/library/addbook", nil)
+	expect(t, w, http.StatusOK)
+	if !strings.Contains(w.Body.String(), app.Science.Name) {
+		t.Error("new book form does not offer the labels")
+	}
+	expect(t, app.guest().do(http.MethodGet, "/library/addbook", nil), http.StatusUnauthorized)
+
+	fields := map[string]string{"name": "Night Train", "author": "Roman Reader", "description": "A short trip."}
+	w = c.upload(http.MethodPost, "/library/", fields, map[string][2]string{"cover_image": {"cover.png", pngCover(t)}})
+	expect(t, w, http.StatusCreated)
+	book := app.createdBook(w)
+	if book.CreatorUserID != app.Reader.ID || book.CoverPath == "" {
+		t.Errorf("book = %+v", book)
+	}
+	expect(t, c.upload(http.MethodPost, "/library/", fields, map[string][2]string{"cover_image": {"cover.png", "not an image"}}), http.StatusBadRequest)
+	expect(t, app.guest().upload(http.MethodPost, "/library/", fields, nil), http.StatusUnauthorized)
+
+	path := "/library/addbook/" + itoa(book.BookID)
+	expect(t, c.do(http.MethodGet, path, nil), http.StatusOK)
+	expect(t, c.do(http.MethodGet, path+"/chapter", nil), http.StatusOK)
+
+	w = c.do(http.MethodPost, path+"/chapter", gin.H{"title": "Departure", "text": "The train leaves at night.", "chapter_order": 1})
+	expect(t, w, http.StatusCreated)
+	var created struct {
+		ChapterID uint `json:"chapter_id"`
+	}
+	decode(t, w, &created)
+
+	chapter := path + "/chapter/" + itoa(created.ChapterID)
+	w = c.do(http.MethodGet, chapter, nil)
+	expect(t, w, http.StatusOK)
+	if !strings.Contains(w.Body.String(), "The train leaves at night.") {
+		t.Error("chapter editor does not show the chapter text")
+	}
+	expect(t, c.do(http.MethodPut, chapter, gin.H{"title": "Departure", "text": "The train leaves at dawn."}), http.StatusOK)
+	if chapters := app.chapters(book.BookID); len(chapters) != 1 || chapters[0].Text != "The train leaves at dawn." {
+		t.Errorf("chapters = %+v", chapters)
+	}
+
+	other := app.signIn(app.Author.Email)
+	expect(t, other.do(http.MethodGet, path, nil), http.StatusForbidden)
+	expect(t, other.do(http.MethodPut, chapter, gin.H{"title": "Mine now"}), http.StatusForbidden)
+
+	// A chapter is only reached through its own book
+	foreign := "/library/addbook/" + itoa(app.Draft.BookID) + "/chapter/" + itoa(app.FoxChapters[0].ChapterID)
+	expect(t, app.signIn(app.Author.Email).do(http.MethodGet, foreign, nil), http.StatusNotFound)
+
+	// Unverified users can write books but not publish them
+	newcomer := app.signIn(app.Newcomer.Email)
+	w = newcomer.upload(http.MethodPost, "/library/", map[string]string{"name": "First Steps", "author": "Nina"}, nil)
+	expect(t, w, http.StatusCreated)
+	expect(t, newcomer.do(http.MethodPut, "/library/release/"+itoa(app.createdBook(w).BookID), nil), http.StatusForbidden)
+}
+
+func TestChapterRevisionRoutes(t *testing.T) {
+	app := newTestApp(t)
+	author := app.signIn(app.Author.Email)
+	first := app.FoxChapters[0]
+	chapter := "/library/addbook/" + itoa(app.Fox.BookID) + "/chapter/" + itoa(first.ChapterID)
+
+	expect(t, author.do(http.MethodPut, chapter, gin.H{"title": first.Title, "text": "The quick fox sleeps."}), http.StatusOK)
+
+	var list struct {
+		Revisions []models.RevisionSummary `json:"revisions"`
+	}
+	w := author.do(http.MethodGet, chapter+"/revisions", nil)
+	expect(t, w, http.StatusOK)
+	decode(t, w, &list)
+	if len(list.Revisions) != 2 || list.Revisions[0].UserName != app.Author.Name {
+		t.Fatalf("revisions = %+v", list.Revisions)
+	}
+	edit, original := list.Revisions[0].ID, list.Revisions[1].ID
+	expect(t, app.signIn(app.Reader.Email).do(http.MethodGet, chapter+"/revisions", nil), http.StatusForbidden)
+
+	var diff models.RevisionDiff
+	w = author.do(http.MethodGet, chapter+"/revisions/diff?from="+itoa(original)+"&to="+itoa(edit), nil)
+	expect(t, w, http.StatusOK)
+	decode(t, w, &diff)
+	if diff.Inserted != 1 || diff.Deleted == 0 {
+		t.Errorf("diff = %+v", diff)
+	}
+	expect(t, author.do(http.MethodGet, chapter+"/revisions/diff?from="+itoa(original), nil), http.StatusBadRequest)
+	expect(t, author.do(http.MethodGet, chapter+"/revisions/diff?from="+itoa(original)+"&to=999", nil), http.StatusNotFound)
+
+	expect(t, author.do(http.MethodPost, chapter+"/revisions/"+itoa(original)+"/restore", nil), http.StatusOK)
+	if chapters := app.chapters(app.Fox.BookID); chapters[0].Text != first.Text {
+		t.Errorf("restored text %q, want %q", chapters[0].Text, first.Text)
+	}
+	expect(t, author.do(http.MethodPost, chapter+"/revisions/999/restore", nil), http.StatusNotFound)
+}
+
+func TestChapterDraftRoutes(t *testing.T) {
+	app := newTestApp(t)
+	author := app.signIn(app.Author.Email)
+	book := "/library/addbook/" + itoa(app.Fox.BookID)
+	chapter := book + "/chapter/" + itoa(app.FoxChapters[0].ChapterID)
+
+	expect(t, author.do(http.MethodGet, chapter+"/draft", nil), http.StatusNotFound)
+	expect(t, author.do(http.MethodPut, chapter+"/draft", gin.H{"title": "The Jump", "text": "Unsaved"}), http.StatusOK)
+
+	var draft models.ChapterDraft
+	w := author.do(http.MethodGet, chapter+"/draft", nil)
+	expect(t, w, http.StatusOK)
+	decode(t, w, &draft)
+	if draft.Text != "Unsaved" || draft.UserID != app.Author.ID {
+		t.Errorf("draft = %+v", draft)
+	}
+	// Drafts are per user: an admin editing the same chapter has none
+	expect(t, app.signIn(app.Admin.Email).do(http.MethodGet, chapter+"/draft", nil), http.StatusNotFound)
+	expect(t, app.signIn(app.Reader.Email).do(http.MethodPut, chapter+"/draft", gin.H{"text": "Mine"}), http.StatusForbidden)
+
+	expect(t, author.do(http.MethodPut, chapter, gin.H{"title": "The Jump", "text": "Unsaved"}), http.StatusOK)
+	expect(t, author.do(http.MethodGet, chapter+"/draft", nil), http.StatusNotFound)
+
+	// The new chapter slot of a book
+	expect(t, author.do(http.MethodPut, book+"/chapter/draft", gin.H{"title": "Epilogue", "text": "In the end"}), http.StatusOK)
+	expect(t, author.do(http.MethodGet, book+"/chapter/draft", nil), http.StatusOK)
+	expect(t, author.do(http.MethodDelete, book+"/chapter/draft", nil), http.StatusOK)
+	expect(t, author.do(http.MethodGet, book+"/chapter/draft", nil), http.StatusNotFound)
+
+	expect(t, author.do(http.MethodPut, chapter+"/draft", gin.H{"title": "The Jump", "text": "Again"}), http.StatusOK)
+	expect(t, author.do(http.MethodDelete, chapter+"/draft", nil), http.StatusOK)
+	expect(t, author.do(http.MethodGet, chapter+"/draft", nil), http.StatusNotFound)
+}
+
+func TestEditBookRoutes(t *testing.T) {
+	app := newTestApp(t)
+	author := app.signIn(app.Author.Email)
+	reader := app.signIn(app.Reader.Email)
+	draft := itoa(app.Draft.BookID)
+
+	fields := map[string]string{"name": "Finished Notes", "author": "Anna Author", "description": "Ready now."}
+	expect(t, reader.upload(http.MethodPut, "/library/"+draft, fields, nil), http.StatusForbidden)
+	expect(t, author.upload(http.MethodPut, "/library/"+draft, fields, nil), http.StatusOK)
+
+	labels := gin.H{"label_ids": []bool{false, true, false, true}}
+	expect(t, author.do(http.MethodPut, "/library/book/"+draft+"/labels", labels), http.StatusOK)
+	var book models.Book
+	app.db.Preload("BookLabels").First(&book, app.Draft.BookID)
+	if book.Name != "Finished Notes" || len(book.BookLabels) != 2 {
+		t.Errorf("book %q has labels %+v", book.Name, book.BookLabels)
+	}
+
+	expect(t, reader.do(http.MethodPut, "/library/release/"+draft, nil), http.StatusForbidden)
+	expect(t, author.do(http.MethodPut, "/library/release/"+draft, nil), http.StatusOK)
+	app.db.First(&book, app.Draft.BookID)
+	if !book.Released {
+		t.Error("the book was not released")
+	}
+
+	chapter := "/library/chapter/" + itoa(app.DraftChapter.ChapterID)
+	expect(t, reader.do(http.MethodDelete, chapter, nil), http.StatusForbidden)
+	expect(t, author.do(http.MethodDelete, chapter, nil), http.StatusOK)
+	expect(t, author.do(http.MethodDelete, chapter, nil), http.StatusNotFound)
+
+	expect(t, reader.do(http.MethodDelete, "/library/"+draft, nil), http.StatusForbidden)
+	expect(t, author.do(http.MethodDelete, "/library/"+draft, nil), http.StatusOK)
+	expect(t, author.do(http.MethodDelete, "/library/"+draft, nil), http.StatusNotFound)
+}
+
+func TestDeleteAllBooks(t *testing.T) {
+	app := newTestApp(t)
+
+	expect(t, app.signIn(app.Author.Email).do(http.MethodDelete, "/library/", nil), http.StatusForbidden)
+	expect(t, app.signIn(app.Admin.Email).do(http.MethodDelete, "/library/", nil), http.StatusOK)
+
+	var books int64
+	app.db.Model(&models.BookBase{}).Count(&books)
+	if books != 0 {
+		t.Errorf("%d books left", books)
+	}
+}
+
+func TestEpubAndTextImport(t *testing.T) {
+	app := newTestApp(t)
+	c := app.signIn(app.Reader.Email)
+
+	w := c.do(http.MethodGet, "/library/book/"+itoa(app.Fox.BookID)+"/export.epub", nil)
+	expect(t, w, http.StatusOK)
+	if w.Header().Get("Content-Type") != "application/epub+zip" {
+		t.Errorf("content type %q", w.Header().Get("Content-Type"))
+	}
+
+	w = c.upload(http.MethodPost, "/library/import/epub", nil, map[string][2]string{"file": {"fox.epub", w.Body.String()}})
+	expect(t, w, http.StatusCreated)
+	imported := app.createdBook(w)
+	if imported.Name != app.Fox.Name || len(app.chapters(imported.BookID)) != len(app.FoxChapters) {
+		t.Errorf("imported %q with %d chapters", imported.Name, len(app.chapters(imported.BookID)))
+	}
+	expect(t, c.upload(http.MethodPost, "/library/import/epub", nil, map[string][2]string{"file": {"fox.zip", "PK"}}), http.StatusBadRequest)
+
+	text := "# One\n\nThe first part.\n\n# Two\n\nThe second part.\n"
+	fields := map[string]string{"name": "Two Parts", "author": "Roman Reader", "split": "markdown"}
+	w = c.upload(http.MethodPost, "/library/import/text", fields, map[string][2]string{"file": {"parts.md", text}})
+	expect(t, w, http.StatusCreated)
+	if chapters := app.chapters(app.createdBook(w).BookID); len(chapters) != 2 || chapters[1].Title != "Two" {
+		t.Errorf("chapters = %+v", chapters)
+	}
+
+	fields["split"] = "sentences"
+	expect(t, c.upload(http.MethodPost, "/library/import/text", fields, map[string][2]string{"file": {"parts.md", text}}), http.StatusBadRequest)
+	expect(t, app.guest().upload(http.MethodPost, "/library/import/text", fields, nil), http.StatusUnauthorized)
+}
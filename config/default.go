@@ -7,6 +7,10 @@ import (
 )
 
 type Config struct {
+	// DBDriver is "postgres" (default), "sqlite" or "mysql". For SQLite
+	// DBname is the path of the database file.
+	DBDriver               string        `mapstructure:"DB_DRIVER"`
+	DBPort                 string        `mapstructure:"DB_PORT"`
 	DBname                 string        `mapstructure:"DBNAME"`
 	DBuser                 string        `mapstructure:"DBUSER"`
 	DBpassword             string        `mapstructure:"DBPASS"`
@@ -20,6 +24,31 @@ type Config struct {
 	RefreshTokenExpiresIn  time.Duration `mapstructure:"REFRESH_TOKEN_EXPIRED_IN"`
 	AccessTokenMaxAge      int           `mapstructure:"ACCESS_TOKEN_MAXAGE"`
 	RefreshTokenMaxAge     int           `mapstructure:"REFRESH_TOKEN_MAXAGE"`
+
+	AppURL                     string        `mapstructure:"APP_URL"`
+	Mailer                     string        `mapstructure:"MAILER"`
+	MailLogPath                string        `mapstructure:"MAIL_LOG_PATH"`
+	SMTPHost                   string        `mapstructure:"SMTP_HOST"`
+	SMTPPort                   string        `mapstructure:"SMTP_PORT"`
+	SMTPUser                   string        `mapstructure:"SMTP_USER"`
+	SMTPPassword               string        `mapstructure:"SMTP_PASS"`
+	EmailFrom                  string        `mapstructure:"EMAIL_FROM"`
+	VerificationTokenExpiresIn time.Duration `mapstructure:"VERIFICATION_TOKEN_EXPIRED_IN"`
+	PasswordResetExpiresIn     time.Duration `mapstructure:"PASSWORD_RESET_EXPIRED_IN"`
+
+	// BlobStore is "local" (default) or "s3". Covers live below BlobDir
+	// locally; BlobPublicURL, when set, is where clients download them from
+	// instead of the /covers/ route.
+	BlobStore     string `mapstructure:"BLOB_STORE"`
+	BlobDir       string `mapstructure:"BLOB_DIR"`
+	BlobPublicURL string `mapstructure:"BLOB_PUBLIC_URL"`
+	S3Endpoint    string `mapstructure:"S3_ENDPOINT"`
+	S3Region      string `mapstructure:"S3_REGION"`
+	S3Bucket      string `mapstructure:"S3_BUCKET"`
+	S3AccessKey   string `mapstructure:"S3_ACCESS_KEY"`
+	S3SecretKey   string `mapstructure:"S3_SECRET_KEY"`
+	S3UseSSL      bool   `mapstructure:"S3_USE_SSL"`
+	S3PathStyle   bool   `mapstructure:"S3_PATH_STYLE"`
 }
 
 func LoadConfig(path string) (config Config, err error) {
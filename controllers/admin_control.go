@@ -0,0 +1,237 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/st107853/fast_reading/models"
+	"github.com/st107853/fast_reading/services"
+	"gorm.io/gorm"
+)
+
+// AdminController serves the moderation API under /library/admin.
+type AdminController struct {
+	userService services.UserService
+	bookService services.BookService
+}
+
+func NewAdminController(userService services.UserService, bookService services.BookService) AdminController {
+	return AdminController{userService, bookService}
+}
+
+// ListUsers returns a page of users. Query parameters: q, page, per_page.
+func (ac *AdminController) ListUsers(c *gin.Context) {
+	var query models.UserQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "fail", "error": err.Error()})
+		return
+	}
+
+	page, err := ac.userService.ListUsers(query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"status": "error", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "success", "data": page})
+}
+
+// SetUserRole changes the role of a user: {"role": "admin"}.
+func (ac *AdminController) SetUserRole(c *gin.Context) {
+	var input struct {
+		Role string `json:"role" binding:"required"`
+	}
+
+	userID, ok := ac.otherUserID(c)
+	if !ok {
+		return
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "fail", "error": err.Error()})
+		return
+	}
+
+	ac.respond(c, ac.userService.SetUserRole(userID, input.Role))
+}
+
+// SetUserVerified sets the verified flag of a user: {"verified": true}.
+func (ac *AdminController) SetUserVerified(c *gin.Context) {
+	var input struct {
+		Verified *bool `json:"verified" binding:"required"`
+	}
+
+	userID, ok := ac.userID(c)
+	if !ok {
+		return
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "fail", "error": err.Error()})
+		return
+	}
+
+	ac.respond(c, ac.userService.SetUserVerified(userID, *input.Verified))
+}
+
+// SetUserBanned suspends or restores an account: {"banned": true}.
+func (ac *AdminController) SetUserBanned(c *gin.Context) {
+	var input struct {
+		Banned *bool `json:"banned" binding:"required"`
+	}
+
+	userID, ok := ac.otherUserID(c)
+	if !ok {
+		return
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "fail", "error": err.Error()})
+		return
+	}
+
+	ac.respond(c, ac.userService.SetUserBanned(userID, *input.Banned))
+}
+
+// UnreleaseBook hides any book from the catalogue.
+func (ac *AdminController) UnreleaseBook(c *gin.Context) {
+	var uri models.BookURI
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "fail", "error": "Invalid ID format"})
+		return
+	}
+
+	ac.respond(c, ac.bookService.UnreleaseBook(uri.BookID))
+}
+
+// DeleteBook deletes any book.
+func (ac *AdminController) DeleteBook(c *gin.Context) {
+	var uri models.BookURI
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "fail", "error": "Invalid ID format"})
+		return
+	}
+
+	if _, err := ac.bookService.FindBookCreatorID(uri.BookID); err != nil {
+		ac.respond(c, err)
+		return
+	}
+
+	ac.respond(c, ac.bookService.DeleteBook(uri.BookID))
+}
+
+// CreateLabel adds a label: {"name": "Poetry"}.
+func (ac *AdminController) CreateLabel(c *gin.Context) {
+	var input models.LabelInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "fail", "error": err.Error()})
+		return
+	}
+
+	label, err := ac.bookService.CreateLabel(input.Name)
+	if err != nil {
+		ac.respond(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"status": "success", "data": gin.H{"label": label}})
+}
+
+// RenameLabel renames a label: {"name": "Poetry"}.
+func (ac *AdminController) RenameLabel(c *gin.Context) {
+	labelID, ok := idParam(c, "label_id")
+	if !ok {
+		return
+	}
+
+	var input models.LabelInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "fail", "error": err.Error()})
+		return
+	}
+
+	label, err := ac.bookService.RenameLabel(labelID, input.Name)
+	if err != nil {
+		ac.respond(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "success", "data": gin.H{"label": label}})
+}
+
+// MergeLabel moves the books of a label to another one and deletes it:
+// {"into": 3}.
+func (ac *AdminController) MergeLabel(c *gin.Context) {
+	var input struct {
+		Into uint `json:"into" binding:"required"`
+	}
+
+	labelID, ok := idParam(c, "label_id")
+	if !ok {
+		return
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "fail", "error": err.Error()})
+		return
+	}
+
+	ac.respond(c, ac.bookService.MergeLabels(labelID, input.Into))
+}
+
+// DeleteLabel removes a label from every book and deletes it.
+func (ac *AdminController) DeleteLabel(c *gin.Context) {
+	labelID, ok := idParam(c, "label_id")
+	if !ok {
+		return
+	}
+
+	ac.respond(c, ac.bookService.DeleteLabel(labelID))
+}
+
+// userID reads the :user_id parameter.
+func (ac *AdminController) userID(c *gin.Context) (uint, bool) {
+	return idParam(c, "user_id")
+}
+
+// otherUserID reads the :user_id parameter and refuses the admin's own ID, so
+// admins cannot demote or ban themselves out of the console.
+func (ac *AdminController) otherUserID(c *gin.Context) (uint, bool) {
+	userID, ok := ac.userID(c)
+	if !ok {
+		return 0, false
+	}
+
+	if current, _ := c.Get("UserId"); current == userID {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": "You cannot change your own role or ban yourself"})
+		return 0, false
+	}
+
+	return userID, true
+}
+
+// respond maps the result of an admin action to a JSON response.
+func (ac *AdminController) respond(c *gin.Context, err error) {
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, gin.H{"status": "success"})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"status": "fail", "message": err.Error()})
+	case errors.Is(err, services.ErrLabelExists):
+		c.JSON(http.StatusConflict, gin.H{"status": "fail", "message": err.Error()})
+	case errors.Is(err, services.ErrInvalidRole), errors.Is(err, services.ErrEmptyLabelName),
+		errors.Is(err, services.ErrMergeLabelIntoItself):
+		c.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"status": "error", "error": err.Error()})
+	}
+}
+
+// idParam parses a numeric path parameter, answering 400 if it is not one.
+func idParam(c *gin.Context, name string) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param(name), 10, 32)
+	if err != nil || id == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "fail", "error": "Invalid ID format"})
+		return 0, false
+	}
+
+	return uint(id), true
+}
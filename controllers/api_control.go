@@ -0,0 +1,483 @@
+package controllers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/st107853/fast_reading/models"
+	"github.com/st107853/fast_reading/services"
+	"gorm.io/gorm"
+)
+
+// Error codes of the JSON API. Clients should switch on the code, the message
+// is for people.
+const (
+	APIErrInvalidRequest = "invalid_request"
+	APIErrUnauthorized   = "unauthorized"
+	APIErrNotFound       = "not_found"
+	APIErrInternal       = "internal_error"
+)
+
+// APIController serves the data of the HTML pages as JSON under /api/v1.
+// Every response is an envelope: {"status": "success", "data": {...}} or
+// {"status": "fail"|"error", "error": {"code": "...", "message": "..."}}.
+type APIController struct {
+	bookService services.BookService
+	userService services.UserService
+}
+
+func NewAPIController(bookService services.BookService, userService services.UserService) APIController {
+	return APIController{bookService, userService}
+}
+
+// Catalog returns what the main page shows: released books, all labels and
+// the latest releases.
+func (ac *APIController) Catalog(c *gin.Context) {
+	popular, err := ac.bookService.SearchBooks(models.BookQuery{Code: models.FilterReleased, Sort: models.SortPopularity})
+	if err != nil {
+		apiFail(c, err)
+		return
+	}
+
+	labels, err := ac.bookService.ListAllLabels()
+	if err != nil {
+		apiFail(c, err)
+		return
+	}
+
+	lastReleased, err := ac.bookService.ListLastReleased(2)
+	if err != nil {
+		apiFail(c, err)
+		return
+	}
+	for i := range lastReleased {
+		lastReleased[i].BookBase = lastReleased[i].WithCoverURLs()
+	}
+
+	apiOK(c, http.StatusOK, gin.H{
+		"books":         withCoverURLs(popular.Books),
+		"labels":        labels,
+		"last_released": lastReleased,
+	})
+}
+
+// SearchBooks returns one page of books like /library/filter/: keyword,
+// labels (comma separated IDs), code (0 released, 1 started, 2 created,
+// 3 favourite), sort, order, cursor and limit.
+func (ac *APIController) SearchBooks(c *gin.Context) {
+	query, err := bindBookQuery(c)
+	if err != nil {
+		apiError(c, http.StatusBadRequest, APIErrInvalidRequest, err.Error())
+		return
+	}
+
+	if query.Code == "" {
+		query.Code = models.FilterReleased
+	}
+	if query.Code != models.FilterReleased && query.UserID == 0 {
+		apiError(c, http.StatusUnauthorized, APIErrUnauthorized, "You are not logged in")
+		return
+	}
+
+	ac.bookPage(c, query)
+}
+
+// Search runs a full-text search over titles, authors, descriptions and
+// chapter text of released books, best match first.
+func (ac *APIController) Search(c *gin.Context) {
+	var query models.SearchQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		apiError(c, http.StatusBadRequest, APIErrInvalidRequest, "q is required")
+		return
+	}
+
+	page, err := ac.bookService.SearchText(query)
+	if err != nil {
+		if errors.Is(err, services.ErrEmptySearch) {
+			apiError(c, http.StatusBadRequest, APIErrInvalidRequest, err.Error())
+			return
+		}
+		apiFail(c, err)
+		return
+	}
+
+	for i := range page.Hits {
+		page.Hits[i].Book = page.Hits[i].Book.WithCoverURLs()
+	}
+	apiOK(c, http.StatusOK, page)
+}
+
+// ListLabels returns every label.
+func (ac *APIController) ListLabels(c *gin.Context) {
+	labels, err := ac.bookService.ListAllLabels()
+	if err != nil {
+		apiFail(c, err)
+		return
+	}
+
+	apiOK(c, http.StatusOK, gin.H{"labels": labels})
+}
+
+// GetBook returns a book with its labels and table of contents and, for
+// logged in users, the favourite flag and reading progress.
+func (ac *APIController) GetBook(c *gin.Context) {
+	var uri models.BookURI
+	if err := c.ShouldBindUri(&uri); err != nil {
+		apiError(c, http.StatusBadRequest, APIErrInvalidRequest, "Invalid ID format")
+		return
+	}
+
+	book, err := ac.bookService.FindBookByID(uri.BookID)
+	if err != nil {
+		apiFail(c, err)
+		return
+	}
+
+	book.Progress = *models.NewReadingProgress()
+	if user := apiUser(c); user != nil {
+		if book.IsFavorited, err = ac.userService.IsBookFavorited(user.ID, book.BookID); err != nil {
+			apiFail(c, err)
+			return
+		}
+		book.Progress = *ac.userService.GetBooksMark(user.ID, book.BookID)
+		book.IsCreator = user.CanManage(book.CreatorUserID)
+	}
+	book.Progress.BookID = book.BookID
+	book.BookBase = book.WithCoverURLs()
+
+	apiOK(c, http.StatusOK, gin.H{"book": models.NewBookDetail(book)})
+}
+
+// GetChapter returns the text of the chapter at the given position.
+func (ac *APIController) GetChapter(c *gin.Context) {
+	var uri models.ChapterURI
+	if err := c.ShouldBindUri(&uri); err != nil {
+		apiError(c, http.StatusBadRequest, APIErrInvalidRequest, "Invalid ID format")
+		return
+	}
+
+	chapter, err := ac.bookService.FindBooksChapterByIDs(uri.BookID, uri.ChapterID)
+	if err != nil {
+		apiFail(c, err)
+		return
+	}
+	chapter.BookBase = chapter.WithCoverURLs()
+
+	apiOK(c, http.StatusOK, gin.H{"book": chapter.BookBase, "chapter": chapter.Chapter})
+}
+
+// ChapterTokens returns the chapter split into RSVP tokens and flashes.
+// Query parameters: chunk, ramp and ramp_from (see models.ReaderQuery).
+func (ac *APIController) ChapterTokens(c *gin.Context) {
+	var uri models.ChapterURI
+	if err := c.ShouldBindUri(&uri); err != nil {
+		apiError(c, http.StatusBadRequest, APIErrInvalidRequest, "Invalid ID format")
+		return
+	}
+
+	var query models.ReaderQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		apiError(c, http.StatusBadRequest, APIErrInvalidRequest, err.Error())
+		return
+	}
+
+	chapter, err := ac.bookService.FindBooksChapterByIDs(uri.BookID, uri.ChapterID)
+	if err != nil {
+		apiFail(c, err)
+		return
+	}
+
+	apiOK(c, http.StatusOK, newReaderPlan(chapter, query, readerPreferences(c, ac.userService)))
+}
+
+// BookBundle is everything needed to read a book offline. Version changes
+// only when the book, its chapters or the reader settings change, not when
+// the reader moves on.
+type BookBundle struct {
+	Version   string            `json:"version"`
+	Book      models.BookDetail `json:"book"`
+	ChunkSize int               `json:"chunk_size"`
+	Ramp      []float64         `json:"ramp"`
+	Chapters  []BundleChapter   `json:"chapters"`
+}
+
+// BundleChapter is a chapter of a BookBundle. The text is sent once and the
+// RSVP plan of ChapterTokens as parallel arrays over its words, which are the
+// whitespace-separated runs of Text in order.
+type BundleChapter struct {
+	ChapterID    uint         `json:"id"`
+	Title        string       `json:"title"`
+	ChapterOrder int          `json:"chapter_order"`
+	Text         string       `json:"text"`
+	Words        BundleWords  `json:"words"`
+	Chunks       BundleChunks `json:"chunks"`
+}
+
+// BundleWords has one entry per word in Offsets, Pivots and Delays, with the
+// meaning of the fields of the same name in reader.Token.
+type BundleWords struct {
+	Offsets []int     `json:"offsets"`
+	Pivots  []int     `json:"pivots"`
+	Delays  []float64 `json:"delays"`
+	// ParagraphEnds lists the indexes of the words that end a paragraph.
+	ParagraphEnds []int `json:"paragraph_ends"`
+}
+
+// BundleChunks has the number of words of each flash. A flash takes up where
+// the previous one ended and shows its words joined by single spaces, aligned
+// to the pivot of the middle word, for the sum of their delays.
+type BundleChunks struct {
+	Sizes []int `json:"sizes"`
+}
+
+func newBundleChapter(ch *models.Chapter, plan ReaderPlan) BundleChapter {
+	bundled := BundleChapter{
+		ChapterID:    ch.ChapterID,
+		Title:        ch.Title,
+		ChapterOrder: ch.ChapterOrder,
+		Text:         ch.Text,
+		Words: BundleWords{
+			Offsets:       make([]int, len(plan.Tokens)),
+			Pivots:        make([]int, len(plan.Tokens)),
+			Delays:        make([]float64, len(plan.Tokens)),
+			ParagraphEnds: []int{},
+		},
+		Chunks: BundleChunks{Sizes: make([]int, len(plan.Chunks))},
+	}
+	for i, t := range plan.Tokens {
+		bundled.Words.Offsets[i] = t.Offset
+		bundled.Words.Pivots[i] = t.Pivot
+		bundled.Words.Delays[i] = t.Delay
+		if t.ParagraphEnd {
+			bundled.Words.ParagraphEnds = append(bundled.Words.ParagraphEnds, i)
+		}
+	}
+	for i, chunk := range plan.Chunks {
+		bundled.Chunks.Sizes[i] = chunk.Words
+	}
+	return bundled
+}
+
+// GetBundle returns a book with every chapter and the reading progress of
+// the current user for offline reading. It takes the query parameters of
+// ChapterTokens. The ETag covers the whole bundle, so a cached copy is
+// revalidated with If-None-Match.
+func (ac *APIController) GetBundle(c *gin.Context) {
+	var uri models.BookURI
+	if err := c.ShouldBindUri(&uri); err != nil {
+		apiError(c, http.StatusBadRequest, APIErrInvalidRequest, "Invalid ID format")
+		return
+	}
+
+	var query models.ReaderQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		apiError(c, http.StatusBadRequest, APIErrInvalidRequest, err.Error())
+		return
+	}
+
+	book, err := ac.bookService.FindBookByID(uri.BookID)
+	if err != nil {
+		apiFail(c, err)
+		return
+	}
+
+	prefs := readerPreferences(c, ac.userService)
+	settings := newReaderPlan(models.ChapterResponse{BookBase: book.BookBase}, query, prefs)
+	bundle := BookBundle{
+		ChunkSize: settings.ChunkSize,
+		Ramp:      settings.Ramp,
+		Chapters:  make([]BundleChapter, 0, len(book.Chapters)),
+	}
+	for _, ch := range book.Chapters {
+		plan := newReaderPlan(models.ChapterResponse{BookBase: book.BookBase, Chapter: *ch}, query, prefs)
+		bundle.Chapters = append(bundle.Chapters, newBundleChapter(ch, plan))
+	}
+
+	book.BookBase = book.WithCoverURLs()
+	book.Progress = *models.NewReadingProgress()
+	bundle.Book = models.NewBookDetail(book)
+	bundle.Version = jsonDigest(bundle)
+
+	if user := apiUser(c); user != nil {
+		if bundle.Book.IsFavorited, err = ac.userService.IsBookFavorited(user.ID, book.BookID); err != nil {
+			apiFail(c, err)
+			return
+		}
+		bundle.Book.Progress = *ac.userService.GetBooksMark(user.ID, book.BookID)
+		bundle.Book.IsCreator = user.CanManage(book.CreatorUserID)
+	}
+	bundle.Book.Progress.BookID = book.BookID
+
+	etag := `"` + jsonDigest(bundle) + `"`
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", "private, no-cache")
+	if c.GetHeader("If-None-Match") == etag {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	apiOK(c, http.StatusOK, bundle)
+}
+
+// SyncProgress merges the bookmarks an offline client queued into the
+// reading progress; for each book the latest bookmark wins.
+func (ac *APIController) SyncProgress(c *gin.Context) {
+	user := apiUser(c)
+	if user == nil {
+		apiError(c, http.StatusUnauthorized, APIErrUnauthorized, "You are not logged in")
+		return
+	}
+
+	var input models.SyncInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apiError(c, http.StatusBadRequest, APIErrInvalidRequest, err.Error())
+		return
+	}
+
+	result, err := ac.userService.SyncBooksMarks(user.ID, input.Bookmarks)
+	if err != nil {
+		apiFail(c, err)
+		return
+	}
+
+	apiOK(c, http.StatusOK, result)
+}
+
+// jsonDigest returns the hex SHA-256 of the JSON encoding of v.
+func jsonDigest(v interface{}) string {
+	data, _ := json.Marshal(v)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// ContinueReading returns one page of the books the user has started.
+func (ac *APIController) ContinueReading(c *gin.Context) {
+	query, err := bindBookQuery(c)
+	if err != nil {
+		apiError(c, http.StatusBadRequest, APIErrInvalidRequest, err.Error())
+		return
+	}
+
+	if query.UserID == 0 {
+		apiError(c, http.StatusUnauthorized, APIErrUnauthorized, "You are not logged in")
+		return
+	}
+
+	query.Code = models.FilterStarted
+	ac.bookPage(c, query)
+}
+
+func (ac *APIController) bookPage(c *gin.Context, query models.BookQuery) {
+	page, err := ac.bookService.SearchBooks(query)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidSort) || errors.Is(err, services.ErrInvalidCursor) {
+			apiError(c, http.StatusBadRequest, APIErrInvalidRequest, err.Error())
+			return
+		}
+		apiFail(c, err)
+		return
+	}
+
+	page.Books = withCoverURLs(page.Books)
+	apiOK(c, http.StatusOK, page)
+}
+
+// GetMe returns the profile of the current user with the books they created
+// and favourited.
+func (ac *APIController) GetMe(c *gin.Context) {
+	user := apiUser(c)
+	if user == nil {
+		apiError(c, http.StatusUnauthorized, APIErrUnauthorized, "You are not logged in")
+		return
+	}
+
+	created, createdLabels, err := ac.bookService.FindBooksByCreatorID(user.ID)
+	if err != nil {
+		apiFail(c, err)
+		return
+	}
+
+	favourite, favouriteLabels, err := ac.bookService.FindFavoriteBooksByUserID(user.ID)
+	if err != nil {
+		apiFail(c, err)
+		return
+	}
+
+	apiOK(c, http.StatusOK, gin.H{
+		"user": models.UserResponse{
+			ID:        user.ID,
+			Name:      user.Name,
+			Email:     user.Email,
+			Role:      user.Role,
+			CreatedAt: user.CreatedAt,
+			UpdatedAt: user.UpdatedAt,
+		},
+		"verified":         user.Verified,
+		"created_books":    withCoverURLs(created),
+		"created_labels":   nonNil(createdLabels),
+		"favourite_books":  withCoverURLs(favourite),
+		"favourite_labels": nonNil(favouriteLabels),
+	})
+}
+
+func apiOK(c *gin.Context, status int, data interface{}) {
+	c.JSON(status, gin.H{"status": "success", "data": data})
+}
+
+// apiError writes an error envelope. 4xx responses are the client's fault
+// ("fail"), 5xx the server's ("error").
+func apiError(c *gin.Context, status int, code, message string) {
+	result := "fail"
+	if status >= http.StatusInternalServerError {
+		result = "error"
+	}
+
+	c.AbortWithStatusJSON(status, gin.H{"status": result, "error": gin.H{"code": code, "message": message}})
+}
+
+// apiFail maps a service error to an error envelope.
+func apiFail(c *gin.Context, err error) {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		apiError(c, http.StatusNotFound, APIErrNotFound, "Not found")
+		return
+	}
+
+	apiError(c, http.StatusInternalServerError, APIErrInternal, err.Error())
+}
+
+func apiUser(c *gin.Context) *models.User {
+	value, _ := c.Get("currentUser")
+	user, _ := value.(*models.User)
+	return user
+}
+
+func apiUserID(c *gin.Context) uint {
+	if user := apiUser(c); user != nil {
+		return user.ID
+	}
+	return 0
+}
+
+// withCoverURLs replaces stored cover file names with their URLs and makes
+// sure an empty list encodes as [] rather than null.
+func withCoverURLs(books []models.BookBase) []models.BookBase {
+	if books == nil {
+		return []models.BookBase{}
+	}
+
+	for i := range books {
+		books[i] = books[i].WithCoverURLs()
+	}
+	return books
+}
+
+func nonNil(labels []models.Label) []models.Label {
+	if labels == nil {
+		return []models.Label{}
+	}
+	return labels
+}
@@ -1,9 +1,9 @@
 package controllers
 
 import (
-	"database/sql"
 	"errors"
 	"html/template"
+	"log"
 	"net/http"
 	"strconv"
 	"strings"
@@ -12,7 +12,6 @@ import (
 	"github.com/st107853/fast_reading/config"
 	"github.com/st107853/fast_reading/models"
 	"github.com/st107853/fast_reading/services"
-	"github.com/st107853/fast_reading/utils"
 )
 
 // Book reading page
@@ -21,10 +20,11 @@ var loginPage = template.Must(template.New("login_page.html").ParseFiles("./stat
 type AuthController struct {
 	authService services.AuthService
 	userService services.UserService
+	config      config.Config
 }
 
-func NewAuthController(authService services.AuthService, userService services.UserService) AuthController {
-	return AuthController{authService, userService}
+func NewAuthController(authService services.AuthService, userService services.UserService, config config.Config) AuthController {
+	return AuthController{authService, userService, config}
 }
 
 // LoginPage renders the login page.
@@ -60,7 +60,41 @@ func (ac *AuthController) SignUpUser(ctx *gin.Context) {
 		return
 	}
 
-	ctx.JSON(http.StatusCreated, gin.H{"status": "success", "data": gin.H{"user": models.FilteredResponse(newUser)}})
+	ctx.JSON(http.StatusCreated, gin.H{"status": "success", "message": "We sent a verification link to " + newUser.Email, "data": gin.H{"user": models.FilteredResponse(newUser)}})
+}
+
+// VerifyEmail confirms the email address of the account a verification link was sent to.
+func (ac *AuthController) VerifyEmail(ctx *gin.Context) {
+	if err := ac.authService.VerifyEmail(ctx.Param("token")); err != nil {
+		if errors.Is(err, services.ErrInvalidUserToken) {
+			ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusBadGateway, gin.H{"status": "error", "error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"status": "success", "message": "Email verified"})
+}
+
+// ResendVerification emails a new verification link. The response does not
+// reveal whether the address belongs to an account.
+func (ac *AuthController) ResendVerification(ctx *gin.Context) {
+	var input struct {
+		Email string `json:"email" binding:"required,email"`
+	}
+
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "error": err.Error()})
+		return
+	}
+
+	if err := ac.authService.ResendVerification(input.Email); err != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{"status": "error", "error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"status": "success", "message": "If the account exists and is not verified, a new link was sent"})
 }
 
 // SignInUser validates the user’s input and checked if that user exists in the database.
@@ -72,51 +106,81 @@ func (ac *AuthController) SignInUser(ctx *gin.Context) {
 		return
 	}
 
-	user, err := ac.userService.FindUserByEmail(credentials.Email)
+	credentials.Device = ctx.Request.UserAgent()
+
+	result, err := ac.authService.SignInUser(credentials)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
+		switch {
+		case errors.Is(err, services.ErrUserNotFound), errors.Is(err, services.ErrInvalidPassword):
 			ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": "Invalid email or password"})
-			return
+		case errors.Is(err, services.ErrUserBanned):
+			ctx.JSON(http.StatusForbidden, gin.H{"status": "fail", "message": "This account is suspended"})
+		default:
+			ctx.JSON(http.StatusBadGateway, gin.H{"status": "error", "error": err.Error()})
 		}
-		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "error": err.Error()})
 		return
 	}
 
-	if err := utils.VerifyPassword(user.Password, credentials.Password); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": "Invalid email or Password"})
+	id_string := strconv.Itoa(int(result.User.ID))
+
+	ctx.SetCookie("access_token", result.AccessToken, ac.config.AccessTokenMaxAge*60, "/", ac.config.Host, false, true)
+	ctx.SetCookie("refresh_token", result.RefreshToken, ac.config.RefreshTokenMaxAge*60, "/", ac.config.Host, false, true)
+	ctx.SetCookie("logged_in", "true", ac.config.AccessTokenMaxAge*60, "/", ac.config.Host, false, false)
+	ctx.SetCookie("email", result.User.Email, ac.config.AccessTokenMaxAge*60, "/", ac.config.Host, false, false)
+	ctx.SetCookie("user_id", id_string, ac.config.AccessTokenMaxAge*60, "/", ac.config.Host, false, false)
+
+	ctx.JSON(http.StatusOK, gin.H{"status": "success", "access_token": result.AccessToken})
+}
+
+// ForgotPassword emails a password reset link. It always answers with
+// success so it cannot be used to find out which emails are registered.
+func (ac *AuthController) ForgotPassword(ctx *gin.Context) {
+	var input *models.ForgotPasswordInput
+
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "error": err.Error()})
 		return
 	}
 
-	config, err := config.LoadConfig(".")
-	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "error": "Could not load config " + err.Error()})
-		return
+	if err := ac.authService.ForgotPassword(input.Email); err != nil {
+		log.Printf("auth: %v", err)
 	}
 
-	// Generate Tokens
-	access_token, err := utils.CreateToken(config.AccessTokenExpiresIn, user.ID, config.AccessTokenPrivateKey)
-	if err != nil {
+	ctx.JSON(http.StatusOK, gin.H{"status": "success", "message": "If an account with that email exists, we sent a password reset link"})
+}
+
+// ResetPassword sets a new password using the token from a reset email and
+// logs the user out everywhere.
+func (ac *AuthController) ResetPassword(ctx *gin.Context) {
+	var input *models.ResetPasswordInput
+
+	if err := ctx.ShouldBindJSON(&input); err != nil {
 		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "error": err.Error()})
 		return
 	}
 
-	refresh_token, err := utils.CreateToken(config.RefreshTokenExpiresIn, user.ID, config.RefreshTokenPrivateKey)
-	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "error": err.Error()})
+	if input.Password != input.PasswordConfirm {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": "Passwords do not match"})
 		return
 	}
 
-	id_string := strconv.Itoa(int(user.ID))
+	if err := ac.authService.ResetPassword(input); err != nil {
+		if errors.Is(err, services.ErrInvalidUserToken) {
+			ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusBadGateway, gin.H{"status": "error", "error": err.Error()})
+		return
+	}
 
-	ctx.SetCookie("access_token", access_token, config.AccessTokenMaxAge*60, "/", config.Host, false, true)
-	ctx.SetCookie("refresh_token", refresh_token, config.RefreshTokenMaxAge*60, "/", config.Host, false, true)
-	ctx.SetCookie("logged_in", "true", config.AccessTokenMaxAge*60, "/", config.Host, false, false)
-	ctx.SetCookie("email", credentials.Email, config.AccessTokenMaxAge*60, "/", config.Host, false, false)
-	ctx.SetCookie("user_id", id_string, config.AccessTokenMaxAge*60, "/", config.Host, false, false)
+	ctx.SetCookie("access_token", "", -1, "/", ac.config.Host, false, true)
+	ctx.SetCookie("refresh_token", "", -1, "/", ac.config.Host, false, true)
+	ctx.SetCookie("logged_in", "", -1, "/", ac.config.Host, false, true)
 
-	ctx.JSON(http.StatusOK, gin.H{"status": "success", "access_token": access_token})
+	ctx.JSON(http.StatusOK, gin.H{"status": "success", "message": "Password updated, please log in again"})
 }
 
+// RefreshAccessToken rotates the refresh token cookie and issues a new access token.
 func (ac *AuthController) RefreshAccessToken(ctx *gin.Context) {
 	cookie, err := ctx.Cookie("refresh_token")
 
@@ -125,41 +189,90 @@ func (ac *AuthController) RefreshAccessToken(ctx *gin.Context) {
 		return
 	}
 
-	config, _ := config.LoadConfig(".")
-
-	sub, err := utils.ValidateToken(cookie, config.RefreshTokenPublicKey)
+	result, err := ac.authService.RefreshTokens(cookie, ctx.Request.UserAgent())
 	if err != nil {
-		ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{"status": "fail", "error": err.Error()})
+		if errors.Is(err, services.ErrInvalidToken) || errors.Is(err, services.ErrTokenReused) ||
+			errors.Is(err, services.ErrUserNotFound) || errors.Is(err, services.ErrUserBanned) {
+			ctx.SetCookie("refresh_token", "", -1, "/", ac.config.Host, false, true)
+			ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{"status": "fail", "message": "could not refresh access token", "error": err.Error()})
+			return
+		}
+		ctx.AbortWithStatusJSON(http.StatusBadGateway, gin.H{"status": "error", "error": err.Error()})
 		return
 	}
 
-	user_id := sub.(float64)
+	ctx.SetCookie("access_token", result.AccessToken, ac.config.AccessTokenMaxAge*60, "/", ac.config.Host, false, true)
+	ctx.SetCookie("refresh_token", result.RefreshToken, ac.config.RefreshTokenMaxAge*60, "/", ac.config.Host, false, true)
+	ctx.SetCookie("logged_in", "true", ac.config.AccessTokenMaxAge*60, "/", ac.config.Host, false, false)
 
-	user, err := ac.userService.FindUserById(uint(user_id))
-	if err != nil {
-		ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{"status": "fail", "message": "the user belonging to this token no logger exists", "error": err.Error()})
+	ctx.JSON(http.StatusOK, gin.H{"status": "success", "access_token": result.AccessToken})
+}
+
+// LogoutUser revokes the device session server-side and sends expired cookies
+// to the user’s browser or client to log them out.
+func (ac *AuthController) LogoutUser(ctx *gin.Context) {
+	if cookie, err := ctx.Cookie("refresh_token"); err == nil {
+		if err := ac.authService.LogoutUser(cookie); err != nil && !errors.Is(err, services.ErrInvalidToken) {
+			ctx.JSON(http.StatusBadGateway, gin.H{"status": "error", "error": err.Error()})
+			return
+		}
+	}
+
+	ctx.SetCookie("access_token", "", -1, "/", ac.config.Host, false, true)
+	ctx.SetCookie("refresh_token", "", -1, "/", ac.config.Host, false, true)
+	ctx.SetCookie("logged_in", "", -1, "/", ac.config.Host, false, true)
+
+	ctx.JSON(http.StatusOK, gin.H{"status": "success"})
+}
+
+// ListSessions returns the devices the current user is logged in on.
+func (ac *AuthController) ListSessions(ctx *gin.Context) {
+	userId, _ := ctx.Get("UserId")
+	uID, _ := userId.(uint)
+	if uID == 0 {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"status": "fail", "message": "You are not logged in"})
 		return
 	}
 
-	access_token, err := utils.CreateToken(config.AccessTokenExpiresIn, user.ID, config.AccessTokenPrivateKey)
+	sessions, err := ac.authService.ListSessions(uID, ctx.GetString("FamilyID"))
 	if err != nil {
-		ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{"status": "fail", "error": err.Error()})
+		ctx.JSON(http.StatusBadGateway, gin.H{"status": "error", "error": err.Error()})
 		return
 	}
 
-	ctx.SetCookie("access_token", access_token, config.AccessTokenMaxAge*60, "/", config.Host, false, true)
-	ctx.SetCookie("logged_in", "true", config.AccessTokenMaxAge*60, "/", config.Host, false, false)
-
-	ctx.JSON(http.StatusOK, gin.H{"status": "success", "access_token": access_token})
+	ctx.JSON(http.StatusOK, gin.H{"status": "success", "data": gin.H{"sessions": sessions}})
 }
 
-// LogoutUser sends expired cookies to the user’s browser or client to log them out.
-func (ac *AuthController) LogoutUser(ctx *gin.Context) {
-	conf, _ := config.LoadConfig(".")
+// RevokeSessions logs out one device when a session_id is given, otherwise
+// every device except the current one.
+func (ac *AuthController) RevokeSessions(ctx *gin.Context) {
+	userId, _ := ctx.Get("UserId")
+	uID, _ := userId.(uint)
+	if uID == 0 {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"status": "fail", "message": "You are not logged in"})
+		return
+	}
+
+	var err error
+	if param := ctx.Param("session_id"); param != "" {
+		sessionID, parseErr := strconv.ParseUint(param, 10, 32)
+		if parseErr != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": "Invalid ID format"})
+			return
+		}
+		err = ac.authService.RevokeSession(uID, uint(sessionID))
+	} else {
+		err = ac.authService.RevokeOtherSessions(uID, ctx.GetString("FamilyID"))
+	}
 
-	ctx.SetCookie("access_token", "", -1, "/", conf.Host, false, true)
-	ctx.SetCookie("refresh_token", "", -1, "/", conf.Host, false, true)
-	ctx.SetCookie("logged_in", "", -1, "/", conf.Host, false, true)
+	if err != nil {
+		if errors.Is(err, services.ErrSessionNotFound) {
+			ctx.JSON(http.StatusNotFound, gin.H{"status": "fail", "message": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusBadGateway, gin.H{"status": "error", "error": err.Error()})
+		return
+	}
 
 	ctx.JSON(http.StatusOK, gin.H{"status": "success"})
 }
@@ -0,0 +1,53 @@
+package controllers
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/st107853/fast_reading/services"
+	"github.com/st107853/fast_reading/storage"
+)
+
+// CoverController serves uploaded covers from the blob store.
+type CoverController struct {
+	blobs storage.BlobStore
+}
+
+func NewCoverController(blobs storage.BlobStore) CoverController {
+	return CoverController{blobs}
+}
+
+// GetCover sends the cover file, or redirects to the public URL of the blob
+// when the store has one.
+func (cc *CoverController) GetCover(c *gin.Context) {
+	key := services.CoverKey(strings.TrimPrefix(c.Param("name"), "/"))
+
+	if url := cc.blobs.URL(key); url != "" {
+		c.Redirect(http.StatusFound, url)
+		return
+	}
+
+	rc, err := cc.blobs.Get(c.Request.Context(), key)
+	if errors.Is(err, storage.ErrNotFound) || errors.Is(err, storage.ErrInvalidKey) {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	defer rc.Close()
+
+	contentType := mime.TypeByExtension(filepath.Ext(key))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	c.DataFromReader(http.StatusOK, -1, contentType, rc, map[string]string{
+		"Cache-Control": "public, max-age=3600",
+	})
+}
@@ -1,14 +1,19 @@
 package controllers
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"html/template"
+	"mime"
 	"net/http"
+	"path/filepath"
 	"strconv"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/st107853/fast_reading/models"
+	"github.com/st107853/fast_reading/reader"
 	"github.com/st107853/fast_reading/services"
 )
 
@@ -41,36 +46,53 @@ func NewBookController(bookService services.BookService, userService services.Us
 	return BookController{bookService, userService}
 }
 
+// ListAllBooks returns one page of books as JSON. Query parameters: keyword,
+// labels (comma separated IDs), code, sort, order, cursor and limit.
 func (bc *BookController) ListAllBooks(c *gin.Context) {
-	keyword := c.Query("keyword")
-	labelIDsString := c.Query("labels")
-	filterCode := c.Query("code")
-
-	userId, _ := c.Get("UserId")
-	uID, _ := userId.(uint)
-
-	var labelIDs []uint
-	if labelIDsString != "" {
-		idStrings := strings.Split(labelIDsString, ",")
-		for _, s := range idStrings {
-			if id, err := strconv.ParseUint(s, 10, 32); err == nil {
-				labelIDs = append(labelIDs, uint(id))
-			}
-		}
+	query, err := bindBookQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
-	books, err := bc.bookService.SearchBooks(keyword, labelIDs, filterCode, uID)
+	page, err := bc.bookService.SearchBooks(query)
 	if err != nil {
+		if errors.Is(err, services.ErrInvalidSort) || errors.Is(err, services.ErrInvalidCursor) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	jsonData, _ := models.MarshalBookList(books)
-	c.Data(http.StatusOK, "application/json", jsonData)
+	c.JSON(http.StatusOK, page)
+}
+
+// bindBookQuery reads a book listing query from the URL.
+func bindBookQuery(c *gin.Context) (models.BookQuery, error) {
+	var query models.BookQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		return query, err
+	}
+
+	if param := c.Query("labels"); param != "" {
+		for _, s := range strings.Split(param, ",") {
+			id, err := strconv.ParseUint(s, 10, 32)
+			if err != nil {
+				return query, fmt.Errorf("labels must be a comma separated list of IDs")
+			}
+			query.LabelIDs = append(query.LabelIDs, uint(id))
+		}
+	}
+
+	userId, _ := c.Get("UserId")
+	query.UserID, _ = userId.(uint)
+
+	return query, nil
 }
 
 func (bc *BookController) AllBooks(c *gin.Context) {
-	books, err := bc.bookService.ListAllBooks()
+	popular, err := bc.bookService.SearchBooks(models.BookQuery{Code: models.FilterReleased, Sort: models.SortPopularity})
 
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -91,7 +113,7 @@ func (bc *BookController) AllBooks(c *gin.Context) {
 
 	data := BookData{
 		Title:        "All what we have",
-		Books:        books,
+		Books:        popular.Books,
 		Labels:       labels,
 		LastReleased: lastReleased,
 	}
@@ -107,7 +129,7 @@ func (bc *BookController) ContinueReading(c *gin.Context) {
 	userId, _ := c.Get("UserId")
 	uID, _ := userId.(uint)
 
-	books, err := bc.bookService.SearchBooks("", []uint{}, "1", uID)
+	started, err := bc.bookService.SearchBooks(models.BookQuery{Code: models.FilterStarted, UserID: uID, Sort: models.SortName, Limit: 100})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -122,7 +144,7 @@ func (bc *BookController) ContinueReading(c *gin.Context) {
 	data := BookData{
 		Title:  "All what we have",
 		Labels: labels,
-		Books:  books,
+		Books:  started.Books,
 	}
 
 	// Execute the template and write the output to the response writer
@@ -151,6 +173,10 @@ func (bc *BookController) CreateBook(c *gin.Context) {
 
 	bookID, serviceErr := bc.bookService.InsertBook(input, file, uID)
 
+	if status, ok := coverErrorStatus(serviceErr); ok {
+		c.JSON(status, gin.H{"error": serviceErr.Error()})
+		return
+	}
 	if serviceErr != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to create book: %s", serviceErr.Error())})
 		return
@@ -162,6 +188,41 @@ func (bc *BookController) CreateBook(c *gin.Context) {
 	})
 }
 
+// ImportEpub creates a book with all of its chapters from an uploaded .epub file.
+func (bc *BookController) ImportEpub(c *gin.Context) {
+	userId, _ := c.Get("UserId")
+	uID, _ := userId.(uint)
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing epub file: " + err.Error()})
+		return
+	}
+
+	if !strings.EqualFold(filepath.Ext(file.Filename), ".epub") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only .epub files can be imported"})
+		return
+	}
+
+	bookID, err := bc.bookService.ImportEpub(file, uID)
+	if err != nil {
+		if bookID == 0 {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": fmt.Sprintf("Failed to import book: %s", err.Error())})
+			return
+		}
+		c.JSON(http.StatusCreated, gin.H{
+			"message": "Book imported without cover: " + err.Error(),
+			"book_id": bookID,
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Book imported successfully",
+		"book_id": bookID,
+	})
+}
+
 func (bc *BookController) CreateChapter(c *gin.Context) {
 	var chapter models.Chapter
 	if err := c.ShouldBindJSON(&chapter); err != nil {
@@ -182,7 +243,10 @@ func (bc *BookController) CreateChapter(c *gin.Context) {
 		return
 	}
 
-	id, err := bc.bookService.InsertChapter(chapter)
+	userId, _ := c.Get("UserId")
+	uID, _ := userId.(uint)
+
+	id, err := bc.bookService.InsertChapter(chapter, uID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save chapter: " + err.Error()})
 		return
@@ -219,7 +283,10 @@ func (bc *BookController) UpdateBookChapter(c *gin.Context) {
 		return
 	}
 
-	updatedChapter, err := bc.bookService.UpdateChapter(chapter.ChapterID, chapter)
+	userId, _ := c.Get("UserId")
+	uID, _ := userId.(uint)
+
+	updatedChapter, err := bc.bookService.UpdateChapter(chapter.ChapterID, chapter, uID)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
@@ -228,6 +295,134 @@ func (bc *BookController) UpdateBookChapter(c *gin.Context) {
 	c.JSON(http.StatusOK, updatedChapter)
 }
 
+// ChapterRevisions lists the saved revisions of a chapter, newest first.
+func (bc *BookController) ChapterRevisions(c *gin.Context) {
+	var uri models.ChapterURI
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	revisions, err := bc.bookService.ListChapterRevisions(uri.ChapterID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"revisions": revisions})
+}
+
+// ChapterRevisionDiff shows the words changed between the revisions given
+// by the from and to query parameters.
+func (bc *BookController) ChapterRevisionDiff(c *gin.Context) {
+	var uri models.ChapterURI
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	var query models.RevisionQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	diff, err := bc.bookService.DiffChapterRevisions(uri.ChapterID, query.From, query.To)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, diff)
+}
+
+// RestoreChapterRevision puts the title and text of a revision back into
+// the chapter.
+func (bc *BookController) RestoreChapterRevision(c *gin.Context) {
+	var uri models.RevisionURI
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	userId, _ := c.Get("UserId")
+	uID, _ := userId.(uint)
+
+	chapter, err := bc.bookService.RestoreChapterRevision(uri.ChapterID, uri.RevisionID, uID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, chapter)
+}
+
+// ChapterDraft returns the autosaved draft the current user has of a chapter,
+// or of the new chapter of a book when the route has no chapter_id.
+func (bc *BookController) ChapterDraft(c *gin.Context) {
+	var uri models.DraftURI
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	userId, _ := c.Get("UserId")
+	uID, _ := userId.(uint)
+
+	draft, err := bc.bookService.FindChapterDraft(uID, uri.BookID, uri.ChapterID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, draft)
+}
+
+// SaveChapterDraft autosaves the editor content of the current user.
+func (bc *BookController) SaveChapterDraft(c *gin.Context) {
+	var uri models.DraftURI
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	var input models.DraftInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userId, _ := c.Get("UserId")
+	uID, _ := userId.(uint)
+
+	draft, err := bc.bookService.SaveChapterDraft(uID, uri.BookID, uri.ChapterID, input)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, draft)
+}
+
+// DiscardChapterDraft deletes the draft of the current user.
+func (bc *BookController) DiscardChapterDraft(c *gin.Context) {
+	var uri models.DraftURI
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	userId, _ := c.Get("UserId")
+	uID, _ := userId.(uint)
+
+	if err := bc.bookService.DiscardChapterDraft(uID, uri.BookID, uri.ChapterID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Draft discarded"})
+}
+
 func (bc *BookController) ReleaseBook(c *gin.Context) {
 	var uri models.BookURI
 	if err := c.ShouldBindUri(&uri); err != nil {
@@ -235,7 +430,15 @@ func (bc *BookController) ReleaseBook(c *gin.Context) {
 		return
 	}
 
-	if err := bc.bookService.ReleaseBook(uri.BookID); err != nil {
+	userId, _ := c.Get("UserId")
+	uID, _ := userId.(uint)
+
+	err := bc.bookService.ReleaseBook(uri.BookID, uID)
+	if errors.Is(err, services.ErrUserNotVerified) {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
 	}
@@ -267,10 +470,6 @@ func (bc *BookController) BookMark(c *gin.Context) {
 	userId, _ := c.Get("UserId")
 	uID, _ := userId.(uint)
 
-	if uID == 0 {
-		return
-	}
-
 	if err := c.ShouldBindUri(&uri); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
 		return
@@ -313,10 +512,13 @@ func (bc *BookController) GetBook(c *gin.Context) {
 		}
 
 		progress = bc.userService.GetBooksMark(uID, book.BookID)
-		book.IsCreator = userId == book.CreatorUserID
+		if user, ok := c.MustGet("currentUser").(*models.User); ok {
+			book.IsCreator = user.CanManage(book.CreatorUserID)
+		}
 	}
 
 	book.Progress = *progress
+	book.Preferences = readerPreferences(c, bc.userService)
 
 	// Execute the bookPage template and write the output to the response writer
 	if err := bookPage.Execute(c.Writer, book); err != nil {
@@ -325,7 +527,100 @@ func (bc *BookController) GetBook(c *gin.Context) {
 	}
 }
 
-// GetChapter retrieves a chapter by its ID
+// ImportText creates a book from an uploaded .txt or .md file. The optional
+// "split" form field selects the chapter rule (markdown, chapter, words);
+// "heading_level" and "words" tune it.
+func (bc *BookController) ImportText(c *gin.Context) {
+	userId, _ := c.Get("UserId")
+	uID, _ := userId.(uint)
+
+	var input models.Book
+	if err := c.ShouldBind(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input data: " + err.Error()})
+		return
+	}
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing text file: " + err.Error()})
+		return
+	}
+
+	switch strings.ToLower(filepath.Ext(file.Filename)) {
+	case ".txt", ".md", ".markdown":
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only .txt and .md files can be imported"})
+		return
+	}
+
+	opts := services.SplitOptions{Mode: services.SplitMode(c.PostForm("split"))}
+	switch opts.Mode {
+	case services.SplitAuto, services.SplitMarkdown, services.SplitChapterLines, services.SplitWordCount:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown split mode: " + string(opts.Mode)})
+		return
+	}
+	opts.HeadingLevel, _ = strconv.Atoi(c.PostForm("heading_level"))
+	opts.WordsPerChapter, _ = strconv.Atoi(c.PostForm("words"))
+
+	bookID, err := bc.bookService.ImportText(input, file, opts, uID)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": fmt.Sprintf("Failed to import book: %s", err.Error())})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Book imported successfully",
+		"book_id": bookID,
+	})
+}
+
+// ExportEpub sends a book with all of its chapters as an .epub download.
+func (bc *BookController) ExportEpub(c *gin.Context) {
+	var uri models.BookURI
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	book, err := bc.bookService.FindBookByID(uri.BookID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := bc.bookService.ExportEpub(book, &buf); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+		"filename": book.Name + ".epub",
+	}))
+	c.Data(http.StatusOK, "application/epub+zip", buf.Bytes())
+}
+
+// ReaderPlan is how the RSVP reader presents a chapter: every word, the
+// flashes the words are grouped into and the delay multipliers of the speed
+// ramp after playback starts or resumes.
+type ReaderPlan struct {
+	BookID    uint           `json:"book_id"`
+	ChapterID uint           `json:"chapter_id"`
+	ChunkSize int            `json:"chunk_size"`
+	Tokens    []reader.Token `json:"tokens"`
+	Chunks    []reader.Chunk `json:"chunks"`
+	Ramp      []float64      `json:"ramp"`
+}
+
+// chapterPage is the template data of the reader page.
+type chapterPage struct {
+	models.ChapterResponse
+	Plan ReaderPlan
+}
+
+// GetChapter retrieves a chapter by its ID. Query parameters: chunk, ramp
+// and ramp_from (see models.ReaderQuery).
 func (bc *BookController) GetChapter(c *gin.Context) {
 	var uri models.ReadingProgress
 	if err := c.ShouldBindUri(&uri); err != nil {
@@ -333,19 +628,109 @@ func (bc *BookController) GetChapter(c *gin.Context) {
 		return
 	}
 
+	var query models.ReaderQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	book, err := bc.bookService.FindBooksChapterByIDs(uri.BookID, uri.ChapterID)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
 	}
+	book.Preferences = readerPreferences(c, bc.userService)
+
+	page := chapterPage{
+		ChapterResponse: book,
+		Plan:            newReaderPlan(book, query, book.Preferences),
+	}
 
 	// Execute the bookPage template and write the output to the response writer
-	if err := bookChapter.Execute(c.Writer, book); err != nil {
+	if err := bookChapter.Execute(c.Writer, page); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 }
 
+// ChapterTokens returns the chapter split into RSVP tokens and flashes, as
+// the reader page gets it. It takes the query parameters of GetChapter.
+func (bc *BookController) ChapterTokens(c *gin.Context) {
+	var uri models.ChapterURI
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	var query models.ReaderQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	chapter, err := bc.bookService.FindBooksChapterByIDs(uri.BookID, uri.ChapterID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, newReaderPlan(chapter, query, readerPreferences(c, bc.userService)))
+}
+
+// newReaderPlan tokenizes a chapter with the pauses of prefs and groups the
+// words into flashes. Guests (nil prefs) get the default settings.
+func newReaderPlan(chapter models.ChapterResponse, query models.ReaderQuery, prefs *models.UserPreferences) ReaderPlan {
+	if prefs == nil {
+		defaults := models.DefaultPreferences()
+		prefs = &defaults
+	}
+
+	size := query.Chunk
+	if size == 0 {
+		size = prefs.ChunkSize
+	}
+	ramp := reader.Ramp{From: query.RampFrom, Steps: query.Ramp}
+	if ramp.From == 0 {
+		ramp.From = 0.5
+	}
+
+	tokens := reader.TokenizeWith(chapter.Chapter.Text, reader.Pauses{
+		Clause:    prefs.ClausePause,
+		Sentence:  prefs.SentencePause,
+		Paragraph: prefs.ParagraphPause,
+	})
+	chunks := reader.Chunks(tokens, size)
+	if chunks == nil {
+		chunks = []reader.Chunk{}
+	}
+
+	return ReaderPlan{
+		BookID:    chapter.BookID,
+		ChapterID: uint(chapter.Chapter.ChapterOrder),
+		ChunkSize: max(1, min(size, reader.MaxChunkWords)),
+		Tokens:    tokens,
+		Chunks:    chunks,
+		Ramp:      ramp.Delays(),
+	}
+}
+
+// readerPreferences returns the reader settings of the signed-in user. It
+// returns nil for guests, whose settings stay in the browser.
+func readerPreferences(c *gin.Context, userService services.UserService) *models.UserPreferences {
+	value, _ := c.Get("currentUser")
+	user, _ := value.(*models.User)
+	if user == nil {
+		return nil
+	}
+
+	prefs, err := userService.GetPreferences(user.ID)
+	if err != nil {
+		c.Error(err)
+		return nil
+	}
+	return &prefs
+}
+
 // UpdateBook handles the request, including file upload and service call.
 func (bc *BookController) UpdateBook(c *gin.Context) {
 	var uri models.BookURI
@@ -369,6 +754,10 @@ func (bc *BookController) UpdateBook(c *gin.Context) {
 
 	// Call Service
 	updatedBook, serviceErr := bc.bookService.UpdateBook(uri.BookID, file, input)
+	if status, ok := coverErrorStatus(serviceErr); ok {
+		c.JSON(status, gin.H{"error": serviceErr.Error()})
+		return
+	}
 	if serviceErr != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": serviceErr.Error()})
 		return
@@ -377,6 +766,17 @@ func (bc *BookController) UpdateBook(c *gin.Context) {
 	c.JSON(http.StatusOK, updatedBook)
 }
 
+// coverErrorStatus maps the errors of a rejected cover upload to a status.
+func coverErrorStatus(err error) (int, bool) {
+	switch {
+	case errors.Is(err, services.ErrCoverTooLarge):
+		return http.StatusRequestEntityTooLarge, true
+	case errors.Is(err, services.ErrInvalidCover), errors.Is(err, services.ErrCoverDimensions):
+		return http.StatusBadRequest, true
+	}
+	return 0, false
+}
+
 // DeleteBook deletes a book by its ID
 func (bc *BookController) DeleteBook(c *gin.Context) {
 	var uri models.BookURI
@@ -1,12 +1,14 @@
 package controllers
 
 import (
+	"errors"
 	"html/template"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 	"github.com/st107853/fast_reading/models"
 	"github.com/st107853/fast_reading/services"
+	"gorm.io/gorm"
 )
 
 var userPage = template.Must(template.New("user_page.html").ParseFiles("./static/user_page.html", "./static/template.html"))
@@ -49,3 +51,86 @@ func (uc *UserController) GetMe(ctx *gin.Context) {
 		return
 	}
 }
+
+// RecordSession stores a reading session reported by the RSVP reader.
+func (uc *UserController) RecordSession(ctx *gin.Context) {
+	currentUser := ctx.MustGet("currentUser").(*models.User)
+
+	var input models.ReadingSessionInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "error": err.Error()})
+		return
+	}
+
+	session, err := uc.userService.RecordReadingSession(currentUser.ID, input)
+	switch {
+	case errors.Is(err, services.ErrInvalidSession):
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "error": err.Error()})
+		return
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"status": "fail", "error": "Book not found"})
+		return
+	case err != nil:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"status": "error", "error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, gin.H{"status": "success", "data": session})
+}
+
+// GetStats returns the reading statistics of the current user. Query
+// parameters: days (30 by default), tz (IANA time zone, UTC by default).
+func (uc *UserController) GetStats(ctx *gin.Context) {
+	currentUser := ctx.MustGet("currentUser").(*models.User)
+
+	var query models.StatsQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "error": err.Error()})
+		return
+	}
+
+	stats, err := uc.userService.GetReadingStats(currentUser.ID, query)
+	if errors.Is(err, services.ErrInvalidTimeZone) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "error": err.Error()})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"status": "error", "error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"status": "success", "data": stats})
+}
+
+// GetPreferences returns the reader settings of the current user.
+func (uc *UserController) GetPreferences(ctx *gin.Context) {
+	currentUser := ctx.MustGet("currentUser").(*models.User)
+
+	prefs, err := uc.userService.GetPreferences(currentUser.ID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"status": "error", "error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"status": "success", "data": prefs})
+}
+
+// UpdatePreferences changes the reader settings given in the body and
+// returns all of them.
+func (uc *UserController) UpdatePreferences(ctx *gin.Context) {
+	currentUser := ctx.MustGet("currentUser").(*models.User)
+
+	var input models.PreferencesInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "error": err.Error()})
+		return
+	}
+
+	prefs, err := uc.userService.UpdatePreferences(currentUser.ID, input)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"status": "error", "error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"status": "success", "data": prefs})
+}
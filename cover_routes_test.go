@@ -0,0 +1,29 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/st107853/fast_reading/models"
+)
+
+func TestCoverRoute(t *testing.T) {
+	app := newTestApp(t)
+	c := app.signIn(app.Reader.Email)
+
+	w := c.upload(http.MethodPost, "/library/", map[string]string{"name": "Covered", "author": "Roman Reader"},
+		map[string][2]string{"cover_image": {"cover.png", pngCover(t)}})
+	expect(t, w, http.StatusCreated)
+	book := app.createdBook(w).BookBase.WithCoverURLs()
+
+	for _, path := range []string{string(book.CoverPath), string(book.CoverThumbPath)} {
+		w = app.guest().do(http.MethodGet, path, nil)
+		expect(t, w, http.StatusOK)
+		if w.Header().Get("Content-Type") != "image/jpeg" {
+			t.Errorf("%s has content type %q", path, w.Header().Get("Content-Type"))
+		}
+	}
+
+	expect(t, app.guest().do(http.MethodGet, models.StaticCoversPath+"missing.jpg", nil), http.StatusNotFound)
+	expect(t, app.guest().do(http.MethodGet, models.StaticCoversPath+"../go.mod", nil), http.StatusNotFound)
+}
@@ -0,0 +1,47 @@
+// Package epub reads and writes EPUB containers in the subset used by the
+// library: book metadata, a cover image and a linear list of text chapters.
+package epub
+
+import "errors"
+
+// Book is the library's view of an EPUB publication.
+type Book struct {
+	// Identifier is the dc:identifier of the package; Write derives one from
+	// the title when it is empty.
+	Identifier  string
+	Title       string
+	Author      string
+	Description string
+	Language    string
+	Subjects    []string
+	Cover       *Cover
+	Chapters    []Chapter
+}
+
+// Cover is the raw cover image stored in the container.
+type Cover struct {
+	// Ext is the file extension of the image including the dot (".jpeg").
+	Ext       string
+	MediaType string
+	Data      []byte
+}
+
+// Chapter is a single spine document reduced to plain text.
+type Chapter struct {
+	Title string
+	Text  string
+}
+
+const (
+	containerPath = "META-INF/container.xml"
+	mimetypePath  = "mimetype"
+	mimetype      = "application/epub+zip"
+
+	// maxEntrySize guards against decompression bombs in uploaded books.
+	maxEntrySize = 32 << 20
+)
+
+var (
+	ErrNoRootFile = errors.New("epub: container has no rootfile")
+	ErrEmptyBook  = errors.New("epub: book has no readable chapters")
+)
@@ -0,0 +1,236 @@
+package epub
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"net/url"
+	"path"
+	"strings"
+)
+
+type container struct {
+	RootFiles []struct {
+		FullPath  string `xml:"full-path,attr"`
+		MediaType string `xml:"media-type,attr"`
+	} `xml:"rootfiles>rootfile"`
+}
+
+type packageDoc struct {
+	Metadata struct {
+		Identifiers  []string `xml:"identifier"`
+		Titles       []string `xml:"title"`
+		Creators     []string `xml:"creator"`
+		Descriptions []string `xml:"description"`
+		Languages    []string `xml:"language"`
+		Subjects     []string `xml:"subject"`
+		Metas        []struct {
+			Name    string `xml:"name,attr"`
+			Content string `xml:"content,attr"`
+		} `xml:"meta"`
+	} `xml:"metadata"`
+	Manifest []manifestItem `xml:"manifest>item"`
+	Spine    []struct {
+		IDRef  string `xml:"idref,attr"`
+		Linear string `xml:"linear,attr"`
+	} `xml:"spine>itemref"`
+}
+
+type manifestItem struct {
+	ID         string `xml:"id,attr"`
+	Href       string `xml:"href,attr"`
+	MediaType  string `xml:"media-type,attr"`
+	Properties string `xml:"properties,attr"`
+}
+
+// Read parses an EPUB 2 or EPUB 3 container. Spine documents that contain no
+// text (cover pages, blank separators) are skipped so the returned chapters
+// can be numbered contiguously.
+func Read(r io.ReaderAt, size int64) (*Book, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("epub: open container: %w", err)
+	}
+
+	files := make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+
+	var c container
+	if err := decodeXML(files, containerPath, &c); err != nil {
+		return nil, err
+	}
+
+	var opfPath string
+	for _, rf := range c.RootFiles {
+		if rf.MediaType == "" || rf.MediaType == "application/oebps-package+xml" {
+			opfPath = rf.FullPath
+			break
+		}
+	}
+	if opfPath == "" {
+		return nil, ErrNoRootFile
+	}
+
+	var opf packageDoc
+	if err := decodeXML(files, opfPath, &opf); err != nil {
+		return nil, err
+	}
+	baseDir := path.Dir(opfPath)
+
+	book := &Book{
+		Identifier:  first(opf.Metadata.Identifiers),
+		Title:       first(opf.Metadata.Titles),
+		Author:      strings.Join(trimAll(opf.Metadata.Creators), ", "),
+		Description: htmlToText(first(opf.Metadata.Descriptions)),
+		Language:    first(opf.Metadata.Languages),
+		Subjects:    trimAll(opf.Metadata.Subjects),
+	}
+
+	items := make(map[string]manifestItem, len(opf.Manifest))
+	for _, item := range opf.Manifest {
+		items[item.ID] = item
+	}
+
+	if item, ok := coverItem(&opf, items); ok {
+		data, err := readFile(files, resolve(baseDir, item.Href))
+		if err != nil {
+			return nil, err
+		}
+		book.Cover = &Cover{
+			Ext:       coverExt(item),
+			MediaType: item.MediaType,
+			Data:      data,
+		}
+	}
+
+	for _, ref := range opf.Spine {
+		if ref.Linear == "no" {
+			continue
+		}
+		item, ok := items[ref.IDRef]
+		if !ok {
+			continue
+		}
+
+		data, err := readFile(files, resolve(baseDir, item.Href))
+		if err != nil {
+			return nil, err
+		}
+
+		title, text := documentText(data)
+		if text == "" {
+			continue
+		}
+		if title == "" {
+			title = fmt.Sprintf("Chapter %d", len(book.Chapters)+1)
+		}
+		book.Chapters = append(book.Chapters, Chapter{Title: title, Text: text})
+	}
+
+	if len(book.Chapters) == 0 {
+		return nil, ErrEmptyBook
+	}
+
+	return book, nil
+}
+
+// coverItem finds the cover image using the EPUB 3 "cover-image" property and
+// falls back to the EPUB 2 <meta name="cover"> convention.
+func coverItem(opf *packageDoc, items map[string]manifestItem) (manifestItem, bool) {
+	for _, item := range opf.Manifest {
+		for _, p := range strings.Fields(item.Properties) {
+			if p == "cover-image" {
+				return item, true
+			}
+		}
+	}
+
+	for _, meta := range opf.Metadata.Metas {
+		if meta.Name == "cover" {
+			item, ok := items[meta.Content]
+			if ok && strings.HasPrefix(item.MediaType, "image/") {
+				return item, true
+			}
+		}
+	}
+
+	return manifestItem{}, false
+}
+
+func coverExt(item manifestItem) string {
+	if ext := path.Ext(item.Href); ext != "" {
+		return strings.ToLower(ext)
+	}
+	if exts, _ := mime.ExtensionsByType(item.MediaType); len(exts) > 0 {
+		return exts[0]
+	}
+	return ".jpeg"
+}
+
+func decodeXML(files map[string]*zip.File, name string, v interface{}) error {
+	data, err := readFile(files, name)
+	if err != nil {
+		return err
+	}
+	if err := xml.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("epub: parse %s: %w", name, err)
+	}
+	return nil
+}
+
+func readFile(files map[string]*zip.File, name string) ([]byte, error) {
+	f, ok := files[name]
+	if !ok {
+		return nil, fmt.Errorf("epub: missing file %q", name)
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		return nil, fmt.Errorf("epub: open %s: %w", name, err)
+	}
+	defer rc.Close()
+
+	data, err := io.ReadAll(io.LimitReader(rc, maxEntrySize+1))
+	if err != nil {
+		return nil, fmt.Errorf("epub: read %s: %w", name, err)
+	}
+	if len(data) > maxEntrySize {
+		return nil, fmt.Errorf("epub: %s is larger than %d bytes", name, maxEntrySize)
+	}
+
+	return data, nil
+}
+
+// resolve turns a manifest href into a path inside the zip archive.
+func resolve(baseDir, href string) string {
+	if i := strings.IndexAny(href, "#?"); i >= 0 {
+		href = href[:i]
+	}
+	if unescaped, err := url.PathUnescape(href); err == nil {
+		href = unescaped
+	}
+	return path.Clean(path.Join(baseDir, href))
+}
+
+func first(values []string) string {
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func trimAll(values []string) []string {
+	var out []string
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
@@ -0,0 +1,134 @@
+package epub
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+)
+
+// blockElements end the current paragraph when they open or close.
+var blockElements = map[string]bool{
+	"p": true, "div": true, "br": true, "hr": true, "li": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"blockquote": true, "section": true, "article": true, "pre": true,
+	"tr": true, "table": true, "ul": true, "ol": true, "dd": true, "dt": true,
+}
+
+// skippedElements hold content that is never part of the reading text.
+var skippedElements = map[string]bool{
+	"head": true, "script": true, "style": true, "title": true,
+}
+
+var headingElements = map[string]bool{
+	"h1": true, "h2": true, "h3": true, "h4": true,
+}
+
+// documentText reduces an XHTML spine document to paragraphs separated by a
+// blank line. The first heading (or, failing that, <title>) becomes the
+// chapter title and is not repeated in the text.
+func documentText(data []byte) (title, text string) {
+	var (
+		paragraphs []string
+		current    strings.Builder
+		heading    strings.Builder
+		docTitle   strings.Builder
+		skipDepth  int
+		inHeading  string
+		inTitle    bool
+	)
+
+	flush := func() {
+		if p := collapseSpaces(current.String()); p != "" {
+			paragraphs = append(paragraphs, p)
+		}
+		current.Reset()
+	}
+
+	dec := newDecoder(data)
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			break
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			name := strings.ToLower(t.Name.Local)
+			if name == "title" {
+				inTitle = true
+			}
+			if skippedElements[name] {
+				skipDepth++
+				continue
+			}
+			if blockElements[name] {
+				flush()
+			}
+			if title == "" && inHeading == "" && headingElements[name] {
+				inHeading = name
+				heading.Reset()
+			}
+		case xml.EndElement:
+			name := strings.ToLower(t.Name.Local)
+			if name == "title" {
+				inTitle = false
+			}
+			if skippedElements[name] {
+				if skipDepth > 0 {
+					skipDepth--
+				}
+				continue
+			}
+			if name == inHeading {
+				inHeading = ""
+				if title = collapseSpaces(heading.String()); title != "" {
+					current.Reset()
+					continue
+				}
+			}
+			if blockElements[name] {
+				flush()
+			}
+		case xml.CharData:
+			if inTitle {
+				docTitle.Write(t)
+			}
+			if skipDepth > 0 {
+				continue
+			}
+			if inHeading != "" {
+				heading.Write(t)
+			}
+			current.Write(t)
+		}
+	}
+	flush()
+
+	if title == "" {
+		title = collapseSpaces(docTitle.String())
+	}
+
+	return title, strings.Join(paragraphs, "\n\n")
+}
+
+// htmlToText strips markup from metadata fields that publishers often fill
+// with escaped HTML.
+func htmlToText(s string) string {
+	if !strings.Contains(s, "<") {
+		return strings.TrimSpace(s)
+	}
+	_, text := documentText([]byte("<div>" + s + "</div>"))
+	return text
+}
+
+func newDecoder(data []byte) *xml.Decoder {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	dec.Strict = false
+	dec.AutoClose = xml.HTMLAutoClose
+	dec.Entity = xml.HTMLEntity
+	return dec
+}
+
+func collapseSpaces(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,260 @@
+package epub
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Write packages the book as an EPUB 3 container. A toc.ncx is included as
+// well so older EPUB 2 readers still get a table of contents.
+func Write(w io.Writer, book *Book) error {
+	if len(book.Chapters) == 0 {
+		return ErrEmptyBook
+	}
+
+	zw := zip.NewWriter(w)
+
+	// The mimetype entry must come first and be stored uncompressed.
+	mt, err := zw.CreateHeader(&zip.FileHeader{Name: mimetypePath, Method: zip.Store})
+	if err != nil {
+		return fmt.Errorf("epub: write mimetype: %w", err)
+	}
+	if _, err := io.WriteString(mt, mimetype); err != nil {
+		return fmt.Errorf("epub: write mimetype: %w", err)
+	}
+
+	pkg := newPackageData(book)
+
+	files := []struct {
+		name string
+		tmpl *template.Template
+		data interface{}
+	}{
+		{containerPath, containerTmpl, nil},
+		{"OEBPS/content.opf", opfTmpl, pkg},
+		{"OEBPS/nav.xhtml", navTmpl, pkg},
+		{"OEBPS/toc.ncx", ncxTmpl, pkg},
+	}
+	for i, ch := range pkg.Chapters {
+		files = append(files, struct {
+			name string
+			tmpl *template.Template
+			data interface{}
+		}{"OEBPS/" + ch.Href, chapterTmpl, struct {
+			Language string
+			Chapter  chapterData
+		}{pkg.Language, pkg.Chapters[i]}})
+	}
+
+	for _, f := range files {
+		fw, err := zw.Create(f.name)
+		if err != nil {
+			return fmt.Errorf("epub: create %s: %w", f.name, err)
+		}
+		if _, err := io.WriteString(fw, xml.Header); err != nil {
+			return fmt.Errorf("epub: write %s: %w", f.name, err)
+		}
+		if err := f.tmpl.Execute(fw, f.data); err != nil {
+			return fmt.Errorf("epub: write %s: %w", f.name, err)
+		}
+	}
+
+	if book.Cover != nil {
+		fw, err := zw.Create("OEBPS/" + pkg.CoverHref)
+		if err != nil {
+			return fmt.Errorf("epub: create cover: %w", err)
+		}
+		if _, err := fw.Write(book.Cover.Data); err != nil {
+			return fmt.Errorf("epub: write cover: %w", err)
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("epub: close container: %w", err)
+	}
+
+	return nil
+}
+
+type packageData struct {
+	*Book
+	Identifier     string
+	Modified       string
+	CoverHref      string
+	CoverMediaType string
+	Chapters       []chapterData
+}
+
+type chapterData struct {
+	ID         string
+	Href       string
+	Order      int
+	Title      string
+	Paragraphs []string
+}
+
+func newPackageData(book *Book) packageData {
+	pkg := packageData{
+		Book:       book,
+		Identifier: book.Identifier,
+		Modified:   time.Now().UTC().Format("2006-01-02T15:04:05Z"),
+	}
+	if pkg.Identifier == "" {
+		pkg.Identifier = "urn:fast-reading:" + strings.Join(strings.Fields(strings.ToLower(book.Title)), "-")
+	}
+	if pkg.Language == "" {
+		pkg.Language = "en"
+	}
+
+	if book.Cover != nil {
+		ext := book.Cover.Ext
+		if ext == "" {
+			ext = ".jpeg"
+		}
+		pkg.CoverHref = "images/cover" + ext
+		pkg.CoverMediaType = book.Cover.MediaType
+		if pkg.CoverMediaType == "" {
+			pkg.CoverMediaType = mime.TypeByExtension(ext)
+		}
+	}
+
+	for i, ch := range book.Chapters {
+		pkg.Chapters = append(pkg.Chapters, chapterData{
+			ID:         fmt.Sprintf("chapter-%d", i+1),
+			Href:       fmt.Sprintf("text/chapter-%d.xhtml", i+1),
+			Order:      i + 1,
+			Title:      ch.Title,
+			Paragraphs: splitParagraphs(ch.Text),
+		})
+	}
+
+	return pkg
+}
+
+// splitParagraphs breaks plain text on blank lines, falling back to single
+// line breaks for texts that were pasted without paragraph spacing.
+func splitParagraphs(text string) []string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+
+	sep := "\n\n"
+	if !strings.Contains(text, sep) {
+		sep = "\n"
+	}
+
+	var out []string
+	for _, p := range strings.Split(text, sep) {
+		if p = collapseSpaces(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// The documents are XML, so the templates are text/template with every value
+// passed through xml: html/template would escape them for HTML and JavaScript.
+var templateFuncs = template.FuncMap{"xml": escapeXML}
+
+func escapeXML(v interface{}) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(fmt.Sprint(v)))
+	return b.String()
+}
+
+var containerTmpl = template.Must(template.New("container").Funcs(templateFuncs).Parse(
+	`<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles>
+    <rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/>
+  </rootfiles>
+</container>
+`))
+
+var opfTmpl = template.Must(template.New("opf").Funcs(templateFuncs).Parse(
+	`<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="book-id" xml:lang="{{.Language | xml}}">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:identifier id="book-id">{{.Identifier | xml}}</dc:identifier>
+    <dc:title>{{.Title | xml}}</dc:title>
+    <dc:language>{{.Language | xml}}</dc:language>
+    {{- if .Author}}
+    <dc:creator>{{.Author | xml}}</dc:creator>
+    {{- end}}
+    {{- if .Description}}
+    <dc:description>{{.Description | xml}}</dc:description>
+    {{- end}}
+    {{- range .Subjects}}
+    <dc:subject>{{. | xml}}</dc:subject>
+    {{- end}}
+    <meta property="dcterms:modified">{{.Modified | xml}}</meta>
+    {{- if .CoverHref}}
+    <meta name="cover" content="cover-image"/>
+    {{- end}}
+  </metadata>
+  <manifest>
+    <item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>
+    <item id="ncx" href="toc.ncx" media-type="application/x-dtbncx+xml"/>
+    {{- if .CoverHref}}
+    <item id="cover-image" href="{{.CoverHref | xml}}" media-type="{{.CoverMediaType | xml}}" properties="cover-image"/>
+    {{- end}}
+    {{- range .Chapters}}
+    <item id="{{.ID | xml}}" href="{{.Href | xml}}" media-type="application/xhtml+xml"/>
+    {{- end}}
+  </manifest>
+  <spine toc="ncx">
+    {{- range .Chapters}}
+    <itemref idref="{{.ID | xml}}"/>
+    {{- end}}
+  </spine>
+</package>
+`))
+
+var navTmpl = template.Must(template.New("nav").Funcs(templateFuncs).Parse(
+	`<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" xml:lang="{{.Language | xml}}">
+<head><title>{{.Title | xml}}</title></head>
+<body>
+  <nav epub:type="toc" id="toc">
+    <h1>{{.Title | xml}}</h1>
+    <ol>
+      {{- range .Chapters}}
+      <li><a href="{{.Href | xml}}">{{.Title | xml}}</a></li>
+      {{- end}}
+    </ol>
+  </nav>
+</body>
+</html>
+`))
+
+var ncxTmpl = template.Must(template.New("ncx").Funcs(templateFuncs).Parse(
+	`<ncx xmlns="http://www.daisy.org/z3986/2005/ncx/" version="2005-1">
+  <head>
+    <meta name="dtb:uid" content="{{.Identifier | xml}}"/>
+  </head>
+  <docTitle><text>{{.Title | xml}}</text></docTitle>
+  <navMap>
+    {{- range .Chapters}}
+    <navPoint id="nav-{{.ID | xml}}" playOrder="{{.Order | xml}}">
+      <navLabel><text>{{.Title | xml}}</text></navLabel>
+      <content src="{{.Href | xml}}"/>
+    </navPoint>
+    {{- end}}
+  </navMap>
+</ncx>
+`))
+
+var chapterTmpl = template.Must(template.New("chapter").Funcs(templateFuncs).Parse(
+	`<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xml:lang="{{.Language | xml}}">
+<head><title>{{.Chapter.Title | xml}}</title></head>
+<body>
+  <h1>{{.Chapter.Title | xml}}</h1>
+  {{- range .Chapter.Paragraphs}}
+  <p>{{. | xml}}</p>
+  {{- end}}
+</body>
+</html>
+`))
@@ -0,0 +1,66 @@
+package epub
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWriteReadRoundTrip(t *testing.T) {
+	book := &Book{
+		Identifier: "urn:isbn:978-0-00-000000-0?edition=2&print=1",
+		Title:      `Tom & Jerry: "Cats" <and> Mice`,
+		Author:     "O'Brien & Sons",
+		// Read takes the description for HTML, so it holds no tags here
+		Description: "A + B isn't always C; it's a 'test' & more.",
+		Language:    "en",
+		Subjects:    []string{"Fiction & Fun", "<Cartoons>"},
+		Cover:       &Cover{Ext: ".png", MediaType: "image/png", Data: []byte("\x89PNG cover")},
+		Chapters: []Chapter{
+			{Title: "One & Only <1>", Text: "He said \"hi\" & left.\n\nAlert('x') </p> javascript:void(0) + 1"},
+			{Title: "Два", Text: "Текст второй главы."},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, book); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := Read(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, book) {
+		t.Errorf("read back\n%+v\nwant\n%+v", got, book)
+	}
+}
+
+func TestWriteEscapesForXML(t *testing.T) {
+	book := &Book{Title: "A + B", Chapters: []Chapter{{Title: "It's", Text: "x + y"}}}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, book); err != nil {
+		t.Fatal(err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(rc)
+		rc.Close()
+		// &#43; is how html/template escapes "+" for HTML
+		if strings.Contains(string(data), "&#43;") {
+			t.Errorf("%s is escaped for HTML: %s", f.Name, data)
+		}
+	}
+}
@@ -17,12 +17,31 @@ require (
 	github.com/gin-gonic/gin v1.11.0
 	github.com/st107853/fast_reading/config v0.0.0-20251024022424-d4caeadd37e6
 	github.com/st107853/fast_reading/controllers v0.0.0-20251024022424-d4caeadd37e6
+	github.com/st107853/fast_reading/mailer v0.0.0-00010101000000-000000000000
 	github.com/st107853/fast_reading/models v0.0.0-20251024022424-d4caeadd37e6
+	github.com/st107853/fast_reading/openapi v0.0.0-00010101000000-000000000000
 	github.com/st107853/fast_reading/routes v0.0.0-20251024022424-d4caeadd37e6
 	github.com/st107853/fast_reading/services v0.0.0-20251024022424-d4caeadd37e6
 	gorm.io/gorm v1.31.0
 )
 
+require (
+	github.com/dustin/go-humanize v1.0.1 // indirect
+	github.com/go-ini/ini v1.67.0 // indirect
+	github.com/google/uuid v1.6.0 // indirect
+	github.com/klauspost/compress v1.18.2 // indirect
+	github.com/klauspost/crc32 v1.3.0 // indirect
+	github.com/minio/crc64nvme v1.1.1 // indirect
+	github.com/minio/md5-simd v1.1.2 // indirect
+	github.com/minio/minio-go/v7 v7.0.98 // indirect
+	github.com/philhofer/fwd v1.2.0 // indirect
+	github.com/rs/xid v1.6.0 // indirect
+	github.com/tinylib/msgp v1.6.1 // indirect
+	golang.org/x/image v0.36.0 // indirect
+	gorm.io/driver/mysql v1.6.0 // indirect
+	gorm.io/driver/sqlite v1.6.0 // indirect
+)
+
 require (
 	filippo.io/edwards25519 v1.1.0 // indirect
 	github.com/bytedance/gopkg v0.1.3 // indirect
@@ -44,13 +63,11 @@ require (
 	github.com/jackc/pgservicefile v0.0.0-20240606120523-5a60cdf6a761 // indirect
 	github.com/jackc/pgx/v5 v5.7.6 // indirect
 	github.com/jackc/puddle/v2 v2.2.2 // indirect
-	github.com/jinzhu/gorm v1.9.16 // indirect
 	github.com/jinzhu/inflection v1.0.0 // indirect
 	github.com/jinzhu/now v1.1.5 // indirect
 	github.com/json-iterator/go v1.1.12 // indirect
 	github.com/klauspost/cpuid/v2 v2.3.0 // indirect
 	github.com/leodido/go-urn v1.4.0 // indirect
-	github.com/lib/pq v1.10.9 // indirect
 	github.com/mattn/go-isatty v0.0.20 // indirect
 	github.com/mattn/go-sqlite3 v1.14.32 // indirect
 	github.com/modern-go/concurrent v0.0.0-20180306012644-bacd9c7ef1dd // indirect
@@ -63,7 +80,12 @@ require (
 	github.com/spf13/cast v1.10.0 // indirect
 	github.com/spf13/pflag v1.0.10 // indirect
 	github.com/spf13/viper v1.21.0 // indirect
+	github.com/st107853/fast_reading/epub v0.0.0-00010101000000-000000000000 // indirect
 	github.com/st107853/fast_reading/middleware v0.0.0-20251024022424-d4caeadd37e6 // indirect
+	github.com/st107853/fast_reading/migrations v0.0.0-00010101000000-000000000000
+	github.com/st107853/fast_reading/reader v0.0.0-00010101000000-000000000000 // indirect
+	github.com/st107853/fast_reading/storage v0.0.0-00010101000000-000000000000
+	github.com/st107853/fast_reading/testutil v0.0.0-00010101000000-000000000000
 	github.com/st107853/fast_reading/utils v0.0.0-20251024022424-d4caeadd37e6 // indirect
 	github.com/subosito/gotenv v1.6.0 // indirect
 	github.com/twitchyliquid64/golang-asm v0.15.1 // indirect
@@ -71,13 +93,13 @@ require (
 	go.uber.org/mock v0.6.0 // indirect
 	go.yaml.in/yaml/v3 v3.0.4 // indirect
 	golang.org/x/arch v0.22.0 // indirect
-	golang.org/x/crypto v0.43.0 // indirect
-	golang.org/x/mod v0.29.0 // indirect
-	golang.org/x/net v0.46.0 // indirect
-	golang.org/x/sync v0.17.0 // indirect
-	golang.org/x/sys v0.37.0 // indirect
-	golang.org/x/text v0.30.0 // indirect
-	golang.org/x/tools v0.38.0 // indirect
+	golang.org/x/crypto v0.47.0 // indirect
+	golang.org/x/mod v0.32.0 // indirect
+	golang.org/x/net v0.49.0 // indirect
+	golang.org/x/sync v0.19.0 // indirect
+	golang.org/x/sys v0.40.0 // indirect
+	golang.org/x/text v0.34.0 // indirect
+	golang.org/x/tools v0.41.0 // indirect
 	google.golang.org/protobuf v1.36.10 // indirect
 	gorm.io/driver/postgres v1.6.0 // indirect
 )
@@ -85,3 +107,17 @@ require (
 replace github.com/st107853/fast_reading/controllers => ./controllers
 
 replace github.com/st107853/fast_reading/models => ./models
+
+replace github.com/st107853/fast_reading/epub => ./epub
+
+replace github.com/st107853/fast_reading/reader => ./reader
+
+replace github.com/st107853/fast_reading/mailer => ./mailer
+
+replace github.com/st107853/fast_reading/openapi => ./openapi
+
+replace github.com/st107853/fast_reading/storage => ./storage
+
+replace github.com/st107853/fast_reading/migrations => ./migrations
+
+replace github.com/st107853/fast_reading/testutil => ./testutil
@@ -0,0 +1,119 @@
+// Package mailer delivers the account emails (verification, password reset)
+// through a pluggable backend.
+package mailer
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/smtp"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Mailer sends a plain-text email.
+type Mailer interface {
+	Send(to, subject, body string) error
+}
+
+// Message is an email handed to a Mailer.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// SMTPMailer sends mail through an SMTP server using PLAIN auth when a
+// username is configured.
+type SMTPMailer struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+func NewSMTPMailer(host, port, username, password, from string) *SMTPMailer {
+	return &SMTPMailer{host, port, username, password, from}
+}
+
+func (m *SMTPMailer) Send(to, subject, body string) error {
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+
+	addr := net.JoinHostPort(m.Host, m.Port)
+	if err := smtp.SendMail(addr, auth, m.From, []string{to}, formatMessage(m.From, to, subject, body)); err != nil {
+		return fmt.Errorf("mailer: send to %s: %w", to, err)
+	}
+
+	return nil
+}
+
+// LogMailer writes every message to w instead of sending it and keeps the
+// messages in memory. It is meant for development and tests.
+type LogMailer struct {
+	mu       sync.Mutex
+	w        io.Writer
+	messages []Message
+}
+
+func NewLogMailer(w io.Writer) *LogMailer {
+	return &LogMailer{w: w}
+}
+
+// NewFileMailer appends messages to the file at path.
+func NewFileMailer(path string) (*LogMailer, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("mailer: open %s: %w", path, err)
+	}
+	return NewLogMailer(f), nil
+}
+
+func (m *LogMailer) Send(to, subject, body string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.messages = append(m.messages, Message{To: to, Subject: subject, Body: body})
+
+	if m.w == nil {
+		return nil
+	}
+	if _, err := fmt.Fprintf(m.w, "%s\n", formatMessage("", to, subject, body)); err != nil {
+		return fmt.Errorf("mailer: write message: %w", err)
+	}
+	return nil
+}
+
+// Messages returns a copy of every message sent so far.
+func (m *LogMailer) Messages() []Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]Message(nil), m.messages...)
+}
+
+func formatMessage(from, to, subject, body string) []byte {
+	var b strings.Builder
+	if from != "" {
+		fmt.Fprintf(&b, "From: %s\r\n", headerValue(from))
+	}
+	fmt.Fprintf(&b, "To: %s\r\n", headerValue(to))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", headerValue(subject)))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+	return []byte(b.String())
+}
+
+// headerValue drops line breaks so values cannot inject extra headers.
+func headerValue(s string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(s)
+}
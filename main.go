@@ -1,101 +1,66 @@
 package main
 
 import (
-	"context"
 	"fmt"
 	"log"
+	"os"
 
-	"github.com/gin-contrib/cors"
 	"github.com/st107853/fast_reading/config"
-	"github.com/st107853/fast_reading/controllers"
+	"github.com/st107853/fast_reading/mailer"
+	"github.com/st107853/fast_reading/migrations"
 	"github.com/st107853/fast_reading/models"
-	"github.com/st107853/fast_reading/routes"
-	"github.com/st107853/fast_reading/services"
-
-	"github.com/gin-gonic/gin"
-	"gorm.io/gorm"
+	"github.com/st107853/fast_reading/storage"
 )
 
-var (
-	server *gin.Engine
-	ctx    context.Context
-	conf   config.Config
-
-	userService         services.UserService
-	UserController      controllers.UserController
-	UserRouteController routes.UserRouteController
-
-	authService         services.AuthService
-	AuthController      controllers.AuthController
-	AuthRouteController routes.AuthRouteController
-
-	bookService         services.BookService
-	db                  *gorm.DB
-	BookRouteController routes.BookRouteController
-)
-
-func init() {
-	ctx = context.TODO()
-	var err error
-
+func main() {
 	fmt.Println("Starting Fast Reading API...")
-	conf, err = config.LoadConfig(".")
+	conf, err := config.LoadConfig(".")
 	if err != nil {
 		log.Fatal("Could not load config", err)
 	}
 
 	// Initialize GORM via models helper (returns *gorm.DB)
-	gdb, err := models.OpenDbConnectionWithConfig(conf.Host, conf.DBname, conf.DBuser, conf.DBpassword)
+	db, err := models.OpenDbConnectionWithConfig(conf)
 	if err != nil {
 		log.Fatalf("models.OpenDbConnection failed: %v", err)
 	}
-	if gdb == nil {
+	if db == nil {
 		log.Fatal("models.OpenDbConnection returned nil *gorm.DB")
 	}
-
-	// Auto-migrate core models (safe no-op if tables exist)
-	if err := gdb.AutoMigrate(&models.Book{}, &models.User{}, &models.ReadingProgress{}); err != nil {
-		log.Fatalf("Failed to migrate models: %v", err)
-	}
-
-	// Wire services with GORM-backed implementations
-	userService = services.NewUserServiceImpl(gdb, ctx)
-	authService = services.NewAuthService(gdb, ctx)
-	bookService = services.NewBookService(gdb, ctx)
-
-	// Create controllers and route controllers
-	AuthController = controllers.NewAuthController(authService, userService)
-	AuthRouteController = routes.NewAuthRouteController(AuthController)
-
-	UserController = controllers.NewUserController(userService, bookService)
-	UserRouteController = routes.NewRouteUserController(UserController)
-
-	BookController := controllers.NewBookController(bookService, userService)
-	BookRouteController = routes.NewBookRouteController(BookController)
-
-	server = gin.New()
-	server.Use(gin.Logger())   // Add Logger middleware explicitly
-	server.Use(gin.Recovery()) // Add Recovery middleware explicitly
-}
-
-func main() {
-
 	defer models.RemoveDb(db)
 
-	server.Static("/static", "./static")
-	server.Static("/covers", "./covers")
+	migrator, err := migrations.New(db)
+	if err != nil {
+		log.Fatalf("Could not load migrations: %v", err)
+	}
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(migrator, os.Args[2:], os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
 
-	corsConfig := cors.DefaultConfig()
-	corsConfig.AllowOrigins = []string{"http://localhost:8080", "http://localhost:3000"}
-	corsConfig.AllowCredentials = true
+	// The schema is changed only by `migrate up`, never on boot, so an
+	// out-of-date database stops the server instead of serving errors.
+	pending, err := migrator.Pending()
+	if err != nil {
+		log.Fatalf("Could not check migrations: %v", err)
+	}
+	if len(pending) > 0 {
+		log.Fatalf("Database has %d pending migrations, run `%s migrate up` first", len(pending), os.Args[0])
+	}
 
-	server.Use(cors.New(corsConfig))
+	mail, err := newMailer(conf)
+	if err != nil {
+		log.Fatalf("Could not set up mailer: %v", err)
+	}
 
-	router := server.Group("/library")
+	blobs, err := newBlobStore(conf)
+	if err != nil {
+		log.Fatalf("Could not set up blob store: %v", err)
+	}
 
-	AuthRouteController.AuthRoute(router, userService)
-	UserRouteController.UserRoute(router, userService)
-	BookRouteController.BookRoute(router, bookService, userService)
+	server := newServer(db, conf, mail, blobs)
 
 	log.Println("Registered routes:")
 	for _, route := range server.Routes() {
@@ -104,3 +69,46 @@ func main() {
 
 	log.Fatal(server.Run(":" + conf.Port))
 }
+
+// newMailer picks the mail transport from config: SMTP in production, a
+// log of messages (MAIL_LOG_PATH or stdout) during development.
+func newMailer(conf config.Config) (mailer.Mailer, error) {
+	switch conf.Mailer {
+	case "smtp":
+		return mailer.NewSMTPMailer(conf.SMTPHost, conf.SMTPPort, conf.SMTPUser, conf.SMTPPassword, conf.EmailFrom), nil
+	case "", "log":
+		if conf.MailLogPath != "" {
+			return mailer.NewFileMailer(conf.MailLogPath)
+		}
+		return mailer.NewLogMailer(os.Stdout), nil
+	default:
+		return nil, fmt.Errorf("unknown MAILER %q", conf.Mailer)
+	}
+}
+
+// newBlobStore picks where uploaded covers are kept: an S3-compatible bucket
+// shared by every replica, or the local disk (BLOB_DIR, the working directory
+// by default) for development.
+func newBlobStore(conf config.Config) (storage.BlobStore, error) {
+	switch conf.BlobStore {
+	case "s3":
+		return storage.NewS3Store(storage.S3Config{
+			Endpoint:  conf.S3Endpoint,
+			Region:    conf.S3Region,
+			Bucket:    conf.S3Bucket,
+			AccessKey: conf.S3AccessKey,
+			SecretKey: conf.S3SecretKey,
+			UseSSL:    conf.S3UseSSL,
+			PathStyle: conf.S3PathStyle,
+			PublicURL: conf.BlobPublicURL,
+		})
+	case "", "local":
+		dir := conf.BlobDir
+		if dir == "" {
+			dir = "."
+		}
+		return storage.NewLocalStore(dir, conf.BlobPublicURL), nil
+	default:
+		return nil, fmt.Errorf("unknown BLOB_STORE %q", conf.BlobStore)
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/st107853/fast_reading/config"
+	"github.com/st107853/fast_reading/openapi"
+	"github.com/st107853/fast_reading/routes"
+)
+
+func TestOpenAPIDescribesEveryRoute(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	server := newServer(nil, config.Config{}, nil, nil)
+
+	spec := routes.OpenAPISpec()
+	registered := map[string]bool{}
+
+	for _, r := range server.Routes() {
+		// The static file server is set up in newServer, not in routes/*.go
+		if strings.HasPrefix(r.Path, "/static/") {
+			continue
+		}
+
+		registered[r.Method+" "+r.Path] = true
+		if !spec.Has(r.Method, r.Path) {
+			t.Errorf("%s %s is registered but missing from routes.Docs", r.Method, r.Path)
+		}
+	}
+
+	for _, d := range routes.Docs {
+		if !registered[d.Method+" "+d.Path] {
+			t.Errorf("%s %s is documented in routes.Docs but not registered", d.Method, d.Path)
+		}
+	}
+}
+
+func TestOpenAPIServed(t *testing.T) {
+	w := newTestApp(t).guest().do(http.MethodGet, "/api/openapi.json", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /api/openapi.json: status %d", w.Code)
+	}
+
+	var doc openapi.Document
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("decode document: %v", err)
+	}
+
+	if doc.OpenAPI != openapi.Version {
+		t.Errorf("openapi = %q, want %q", doc.OpenAPI, openapi.Version)
+	}
+	for _, name := range []string{"Book", "Chapter", "Label", "ReadingProgress", "SignUpInput", "SignInInput"} {
+		if _, ok := doc.Components.Schemas[name]; !ok {
+			t.Errorf("schema %s missing from components", name)
+		}
+	}
+
+	login := doc.Paths["/library/auth/login"]["post"]
+	if login == nil || login.RequestBody == nil {
+		t.Fatal("POST /library/auth/login has no request body")
+	}
+	if ref := login.RequestBody.Content["application/json"].Schema.Ref; ref != "#/components/schemas/SignInInput" {
+		t.Errorf("login body = %q, want SignInInput", ref)
+	}
+}
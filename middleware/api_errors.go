@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+const apiErrorsKey = "apiErrors"
+
+// apiErrorCodes are the codes of the JSON API for the statuses the
+// middleware rejects requests with.
+var apiErrorCodes = map[int]string{
+	http.StatusBadRequest:          "invalid_request",
+	http.StatusUnauthorized:        "unauthorized",
+	http.StatusForbidden:           "forbidden",
+	http.StatusNotFound:            "not_found",
+	http.StatusInternalServerError: "internal_error",
+}
+
+// APIErrors makes the middleware after it reject requests with the error
+// envelope of the JSON API, {"status", "error": {"code", "message"}}, instead
+// of {"status", "message"}. Mount it first on the API group.
+func APIErrors() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.Set(apiErrorsKey, true)
+		ctx.Next()
+	}
+}
+
+// abort ends the request with status and message in the error format of the
+// route group. 4xx responses are the client's fault ("fail"), 5xx the
+// server's ("error").
+func abort(ctx *gin.Context, status int, message string) {
+	result := "fail"
+	if status >= http.StatusInternalServerError {
+		result = "error"
+	}
+
+	if ctx.GetBool(apiErrorsKey) {
+		ctx.AbortWithStatusJSON(status, gin.H{"status": result, "error": gin.H{"code": apiErrorCodes[status], "message": message}})
+		return
+	}
+	ctx.AbortWithStatusJSON(status, gin.H{"status": result, "message": message})
+}
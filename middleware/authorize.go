@@ -0,0 +1,115 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/st107853/fast_reading/models"
+	"github.com/st107853/fast_reading/services"
+)
+
+// currentUser returns the user set by DeserializeUser, or nil for anonymous requests.
+func currentUser(ctx *gin.Context) *models.User {
+	value, _ := ctx.Get("currentUser")
+	user, _ := value.(*models.User)
+	return user
+}
+
+// RequireAuth rejects anonymous requests with 401. It must run after DeserializeUser.
+func RequireAuth() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if currentUser(ctx) == nil {
+			abort(ctx, http.StatusUnauthorized, "You are not logged in")
+			return
+		}
+		ctx.Next()
+	}
+}
+
+// RequireRole lets through only users with one of the given roles.
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		user := currentUser(ctx)
+		if user == nil {
+			abort(ctx, http.StatusUnauthorized, "You are not logged in")
+			return
+		}
+
+		for _, role := range roles {
+			if user.Role == role {
+				ctx.Next()
+				return
+			}
+		}
+
+		abort(ctx, http.StatusForbidden, "You are not allowed to do this")
+	}
+}
+
+// RequireBookOwner lets through the creator of the book in the :book_id
+// parameter and admins.
+func RequireBookOwner(bookService services.BookService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		bookID, err := strconv.ParseUint(ctx.Param("book_id"), 10, 32)
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+			return
+		}
+
+		creatorID, err := bookService.FindBookCreatorID(uint(bookID))
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+
+		authorize(ctx, creatorID)
+	}
+}
+
+// RequireChapterOwner lets through the creator of the book that the chapter
+// in the :chapter_id parameter belongs to and admins. On routes that also have
+// a :book_id parameter, the chapter must belong to that book.
+func RequireChapterOwner(bookService services.BookService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if _, err := strconv.ParseUint(ctx.Param("chapter_id"), 10, 32); err != nil {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+			return
+		}
+
+		chapter, err := bookService.FindChapterByID(ctx.Param("chapter_id"))
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+
+		if bookID := ctx.Param("book_id"); bookID != "" && bookID != strconv.FormatUint(uint64(chapter.BookID), 10) {
+			ctx.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "chapter " + ctx.Param("chapter_id") + " does not belong to book " + bookID})
+			return
+		}
+
+		creatorID, err := bookService.FindBookCreatorID(chapter.BookID)
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+
+		authorize(ctx, creatorID)
+	}
+}
+
+// authorize continues the chain if the current user may manage books of creatorID.
+func authorize(ctx *gin.Context, creatorID uint) {
+	user := currentUser(ctx)
+	if user == nil {
+		abort(ctx, http.StatusUnauthorized, "You are not logged in")
+		return
+	}
+
+	if !user.CanManage(creatorID) {
+		abort(ctx, http.StatusForbidden, "Only the creator of this book or an admin can change it")
+		return
+	}
+
+	ctx.Next()
+}
@@ -11,7 +11,7 @@ import (
 )
 
 // DeserializeUser extracts and validats the access token from either the Cookies object or Authorization header.
-func DeserializeUser(userService services.UserService) gin.HandlerFunc {
+func DeserializeUser(userService services.UserService, conf config.Config) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		var access_token string
 		cookie, err := ctx.Cookie("access_token")
@@ -31,10 +31,9 @@ func DeserializeUser(userService services.UserService) gin.HandlerFunc {
 			return
 		}
 
-		config, _ := config.LoadConfig(".")
-		sub, err := utils.ValidateToken(access_token, config.AccessTokenPublicKey)
+		sub, sessionID, err := utils.ValidateTokenWithID(access_token, conf.AccessTokenPublicKey)
 		if err != nil {
-			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"status": "fail", "message": err.Error()})
+			abort(ctx, http.StatusUnauthorized, err.Error())
 			return
 		}
 
@@ -42,12 +41,33 @@ func DeserializeUser(userService services.UserService) gin.HandlerFunc {
 		user_id_uint := uint(user_id)
 		user, err := userService.FindUserById(user_id_uint)
 		if err != nil {
-			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"status": "fail", "message": "The user belonging to this token no logger exists"})
+			abort(ctx, http.StatusUnauthorized, "The user belonging to this token no logger exists")
+			return
+		}
+
+		if user.BannedAt != nil {
+			abort(ctx, http.StatusForbidden, "This account is suspended")
+			return
+		}
+
+		// Access tokens of a logged out device stop working at once
+		if sessionID == "" {
+			abort(ctx, http.StatusUnauthorized, "This session has ended")
+			return
+		}
+		active, err := userService.HasActiveSession(user_id_uint, sessionID)
+		if err != nil {
+			abort(ctx, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if !active {
+			abort(ctx, http.StatusUnauthorized, "This session has ended")
 			return
 		}
 
 		ctx.Set("currentUser", user)
 		ctx.Set("UserId", user_id_uint)
+		ctx.Set("FamilyID", sessionID)
 		ctx.Next()
 	}
 }
@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireVerified rejects anonymous users and users who have not confirmed
+// their email address. It must run after DeserializeUser.
+func RequireVerified() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		user := currentUser(ctx)
+		if user == nil {
+			abort(ctx, http.StatusUnauthorized, "You are not logged in")
+			return
+		}
+
+		if !user.Verified {
+			abort(ctx, http.StatusForbidden, "Please verify your email address")
+			return
+		}
+
+		ctx.Next()
+	}
+}
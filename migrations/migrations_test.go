@@ -0,0 +1,193 @@
+package migrations
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func openSQLite(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := filepath.Join(t.TempDir(), "test.db") + "?_foreign_keys=on"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestDriversHaveTheSameVersions(t *testing.T) {
+	want, err := Load("postgres")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, driver := range []string{"sqlite", "mysql"} {
+		got, err := Load(driver)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != len(want) {
+			t.Fatalf("%s has %d migrations, postgres has %d", driver, len(got), len(want))
+		}
+		for i := range want {
+			if got[i].Version != want[i].Version || got[i].Name != want[i].Name {
+				t.Errorf("%s migration %d is %04d_%s, postgres has %04d_%s",
+					driver, i, got[i].Version, got[i].Name, want[i].Version, want[i].Name)
+			}
+			if got[i].Down == "" {
+				t.Errorf("%s %04d_%s has no down file", driver, got[i].Version, got[i].Name)
+			}
+		}
+	}
+}
+
+func TestUpDownRoundTrip(t *testing.T) {
+	db := openSQLite(t)
+	m, err := New(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	applied, err := m.Up()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != len(m.migrations) {
+		t.Fatalf("applied %d migrations, want %d", len(applied), len(m.migrations))
+	}
+	if !db.Migrator().HasTable("chapters") || !db.Migrator().HasIndex("chapters", "idx_chapters_book_order") {
+		t.Fatal("chapters table or index missing after up")
+	}
+	if !db.Migrator().HasTable("chapter_revisions") || !db.Migrator().HasTable("chapter_drafts") {
+		t.Fatal("chapter_revisions or chapter_drafts table missing after up")
+	}
+	if !db.Migrator().HasColumn("chapters", "word_count") {
+		t.Fatal("chapters.word_count missing after up")
+	}
+
+	if again, err := m.Up(); err != nil || len(again) != 0 {
+		t.Fatalf("second up applied %d migrations, err %v", len(again), err)
+	}
+
+	reverted, err := m.Down(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reverted) != 1 || reverted[0].Version != m.migrations[len(m.migrations)-1].Version {
+		t.Fatalf("down reverted %+v, want the last migration", reverted)
+	}
+	if db.Migrator().HasColumn("chapters", "word_count") || !db.Migrator().HasTable("chapter_drafts") {
+		t.Fatal("down did not revert only the last migration")
+	}
+
+	if _, err := m.Down(len(m.migrations)); err != nil {
+		t.Fatal(err)
+	}
+	if db.Migrator().HasTable("users") {
+		t.Fatal("users table still exists after reverting everything")
+	}
+
+	statuses, err := m.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range statuses {
+		if s.AppliedAt != nil {
+			t.Errorf("%04d_%s is still applied", s.Version, s.Name)
+		}
+	}
+}
+
+func TestChangedMigrationIsRejected(t *testing.T) {
+	db := openSQLite(t)
+	m, err := New(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Up(); err != nil {
+		t.Fatal(err)
+	}
+
+	db.Model(&schemaMigration{}).Where("version = ?", 1).Update("checksum", "edited")
+
+	if _, err := m.Up(); !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("up after edit: got %v, want ErrChecksumMismatch", err)
+	}
+	statuses, err := m.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !statuses[0].Changed {
+		t.Fatal("status does not report the changed migration")
+	}
+}
+
+func TestUnknownVersionIsRejected(t *testing.T) {
+	db := openSQLite(t)
+	m, err := New(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Up(); err != nil {
+		t.Fatal(err)
+	}
+
+	db.Create(&schemaMigration{Version: 9999, Name: "from_the_future"})
+
+	if _, err := m.Up(); !errors.Is(err, ErrUnknownVersion) {
+		t.Fatalf("got %v, want ErrUnknownVersion", err)
+	}
+}
+
+func TestDownWithoutDownFile(t *testing.T) {
+	db := openSQLite(t)
+	m, err := New(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Up(); err != nil {
+		t.Fatal(err)
+	}
+
+	last := &m.migrations[len(m.migrations)-1]
+	last.Down = ""
+
+	if _, err := m.Down(1); !errors.Is(err, ErrNoDown) {
+		t.Fatalf("got %v, want ErrNoDown", err)
+	}
+	if pending, err := m.Pending(); err != nil || len(pending) != 0 {
+		t.Fatalf("%04d_%s is no longer applied: pending %+v, err %v", last.Version, last.Name, pending, err)
+	}
+}
+
+func TestAutoMigratedDatabaseIsNotAdopted(t *testing.T) {
+	db := openSQLite(t)
+	// A books table as AutoMigrate created it before cover thumbnails
+	db.Exec("CREATE TABLE books (id integer PRIMARY KEY AUTOINCREMENT, name text NOT NULL, author text NOT NULL)")
+
+	m, err := New(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Up(); err == nil {
+		t.Fatal("up recorded the initial schema over an existing books table")
+	}
+	if pending, _ := m.Pending(); len(pending) != len(m.migrations) {
+		t.Errorf("%d migrations pending, want all %d", len(pending), len(m.migrations))
+	}
+}
+
+func TestStatements(t *testing.T) {
+	got := statements("-- comment\nCREATE TABLE a (\n\tid int\n);\n\nDROP TABLE b;\n")
+	if len(got) != 2 || got[0] != "CREATE TABLE a (\n\tid int\n);" || got[1] != "DROP TABLE b;" {
+		t.Fatalf("statements = %q", got)
+	}
+	if got := statements("-- nothing to do here\n"); len(got) != 0 {
+		t.Fatalf("comment-only file gave %q", got)
+	}
+}
@@ -0,0 +1,137 @@
+-- The schema of a new database. It does not adopt one that AutoMigrate
+-- created before migrations existed: those lack columns added since, so the
+-- tables are created without IF NOT EXISTS and such a database fails here.
+-- Indexed strings are varchar(191), the longest utf8mb4 key InnoDB
+-- indexes in full.
+
+CREATE TABLE books (
+	id bigint unsigned AUTO_INCREMENT,
+	name longtext NOT NULL,
+	author longtext NOT NULL,
+	cover_path longtext,
+	cover_thumb_path longtext,
+	release_date datetime(3) NULL,
+	publication_year bigint,
+	released boolean NOT NULL DEFAULT false,
+	description text,
+	creator_user_id bigint unsigned,
+	PRIMARY KEY (id)
+);
+
+CREATE TABLE labels (
+	id bigint unsigned AUTO_INCREMENT,
+	name varchar(191) NOT NULL,
+	PRIMARY KEY (id),
+	CONSTRAINT uni_labels_name UNIQUE (name)
+);
+
+CREATE TABLE book_labels (
+	book_id bigint unsigned,
+	label_id bigint unsigned,
+	PRIMARY KEY (book_id, label_id),
+	CONSTRAINT fk_book_labels_book FOREIGN KEY (book_id) REFERENCES books (id),
+	CONSTRAINT fk_book_labels_label FOREIGN KEY (label_id) REFERENCES labels (id)
+);
+
+CREATE TABLE chapters (
+	id bigint unsigned AUTO_INCREMENT,
+	book_id bigint unsigned,
+	title longtext,
+	text longtext,
+	chapter_order bigint,
+	PRIMARY KEY (id)
+);
+
+CREATE TABLE users (
+	id bigint unsigned AUTO_INCREMENT,
+	created_at datetime(3) NULL,
+	updated_at datetime(3) NULL,
+	deleted_at datetime(3) NULL,
+	name longtext NOT NULL,
+	email varchar(191) NOT NULL,
+	password longtext NOT NULL,
+	role varchar(191) NOT NULL DEFAULT 'user',
+	verified boolean NOT NULL DEFAULT false,
+	banned_at datetime(3) NULL,
+	PRIMARY KEY (id),
+	INDEX idx_users_deleted_at (deleted_at),
+	CONSTRAINT uni_users_email UNIQUE (email)
+);
+
+CREATE TABLE user_favorites (
+	user_id bigint unsigned,
+	book_id bigint unsigned,
+	PRIMARY KEY (user_id, book_id),
+	CONSTRAINT fk_user_favorites_user FOREIGN KEY (user_id) REFERENCES users (id),
+	CONSTRAINT fk_user_favorites_book_base FOREIGN KEY (book_id) REFERENCES books (id)
+);
+
+CREATE TABLE reading_progress (
+	user_id bigint unsigned,
+	book_id bigint unsigned,
+	chapter_id bigint unsigned,
+	last_index bigint unsigned,
+	updated_at datetime(3) NULL,
+	PRIMARY KEY (user_id, book_id),
+	CONSTRAINT fk_users_reading_progress FOREIGN KEY (user_id) REFERENCES users (id)
+);
+
+CREATE TABLE reading_sessions (
+	id bigint unsigned AUTO_INCREMENT,
+	user_id bigint unsigned NOT NULL,
+	book_id bigint unsigned NOT NULL,
+	chapter_id bigint unsigned NOT NULL,
+	started_at datetime(3) NOT NULL,
+	ended_at datetime(3) NOT NULL,
+	words_read bigint NOT NULL,
+	wpm bigint NOT NULL,
+	created_at datetime(3) NULL,
+	PRIMARY KEY (id),
+	INDEX idx_reading_sessions_user_started (user_id, started_at)
+);
+
+CREATE TABLE user_preferences (
+	user_id bigint unsigned,
+	wpm bigint NOT NULL,
+	chunk_size bigint NOT NULL,
+	font_family longtext NOT NULL,
+	font_size longtext NOT NULL,
+	theme longtext NOT NULL,
+	colour longtext NOT NULL,
+	clause_pause double NOT NULL,
+	sentence_pause double NOT NULL,
+	paragraph_pause double NOT NULL,
+	pivot_highlight boolean NOT NULL,
+	updated_at datetime(3) NULL,
+	PRIMARY KEY (user_id),
+	CONSTRAINT fk_users_preferences FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE TABLE sessions (
+	id bigint unsigned AUTO_INCREMENT,
+	user_id bigint unsigned NOT NULL,
+	token_id varchar(191) NOT NULL,
+	family_id varchar(191) NOT NULL,
+	device longtext,
+	created_at datetime(3) NULL,
+	last_used_at datetime(3) NULL,
+	expires_at datetime(3) NULL,
+	revoked_at datetime(3) NULL,
+	PRIMARY KEY (id),
+	INDEX idx_sessions_user_id (user_id),
+	UNIQUE INDEX idx_sessions_token_id (token_id),
+	INDEX idx_sessions_family_id (family_id)
+);
+
+CREATE TABLE user_tokens (
+	id bigint unsigned AUTO_INCREMENT,
+	user_id bigint unsigned NOT NULL,
+	purpose longtext NOT NULL,
+	token_hash varchar(191) NOT NULL,
+	expires_at datetime(3) NOT NULL,
+	used_at datetime(3) NULL,
+	created_at datetime(3) NULL,
+	PRIMARY KEY (id),
+	INDEX idx_user_tokens_user_id (user_id),
+	UNIQUE INDEX idx_user_tokens_token_hash (token_hash)
+);
@@ -0,0 +1 @@
+-- Full-text search columns are Postgres only. MySQL searches by substring.
@@ -0,0 +1 @@
+DROP INDEX idx_chapters_book_order ON chapters;
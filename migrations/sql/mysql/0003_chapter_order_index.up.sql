@@ -0,0 +1,2 @@
+-- Chapters are always listed and looked up by book in reading order.
+CREATE INDEX idx_chapters_book_order ON chapters (book_id, chapter_order);
@@ -0,0 +1,13 @@
+-- Every update of a chapter keeps the full text it was saved with.
+CREATE TABLE chapter_revisions (
+	id bigint unsigned AUTO_INCREMENT,
+	chapter_id bigint unsigned NOT NULL,
+	user_id bigint unsigned NULL,
+	title longtext,
+	text longtext,
+	restored_from bigint unsigned NULL,
+	created_at datetime(3) NULL,
+	PRIMARY KEY (id),
+	INDEX idx_chapter_revisions_chapter (chapter_id, created_at),
+	CONSTRAINT fk_chapter_revisions_chapter FOREIGN KEY (chapter_id) REFERENCES chapters (id) ON DELETE CASCADE
+);
@@ -0,0 +1,15 @@
+-- Autosaved editor content, one row per user and chapter. chapter_id 0 is
+-- the chapter being added to the book.
+CREATE TABLE chapter_drafts (
+	id bigint unsigned AUTO_INCREMENT,
+	user_id bigint unsigned NOT NULL,
+	book_id bigint unsigned NOT NULL,
+	chapter_id bigint unsigned NOT NULL DEFAULT 0,
+	title longtext,
+	text longtext,
+	updated_at datetime(3) NULL,
+	PRIMARY KEY (id),
+	UNIQUE INDEX idx_chapter_drafts_slot (user_id, book_id, chapter_id),
+	CONSTRAINT fk_chapter_drafts_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+	CONSTRAINT fk_chapter_drafts_book FOREIGN KEY (book_id) REFERENCES books (id) ON DELETE CASCADE
+);
@@ -0,0 +1,3 @@
+-- Words per chapter for the reading statistics, which would otherwise load
+-- every text to count them. Chapters written before stay NULL until counted.
+ALTER TABLE chapters ADD COLUMN word_count bigint;
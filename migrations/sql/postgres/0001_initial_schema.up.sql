@@ -0,0 +1,127 @@
+-- The schema of a new database. It does not adopt one that AutoMigrate
+-- created before migrations existed: those lack columns added since, so the
+-- tables are created without IF NOT EXISTS and such a database fails here.
+
+CREATE TABLE books (
+	id bigserial PRIMARY KEY,
+	name text NOT NULL,
+	author text NOT NULL,
+	cover_path text,
+	cover_thumb_path text,
+	release_date timestamptz,
+	publication_year bigint,
+	released boolean NOT NULL DEFAULT false,
+	description text,
+	creator_user_id bigint
+);
+
+CREATE TABLE labels (
+	id bigserial PRIMARY KEY,
+	name text NOT NULL,
+	CONSTRAINT uni_labels_name UNIQUE (name)
+);
+
+CREATE TABLE book_labels (
+	book_id bigint,
+	label_id bigint,
+	PRIMARY KEY (book_id, label_id),
+	CONSTRAINT fk_book_labels_book FOREIGN KEY (book_id) REFERENCES books (id),
+	CONSTRAINT fk_book_labels_label FOREIGN KEY (label_id) REFERENCES labels (id)
+);
+
+CREATE TABLE chapters (
+	id bigserial PRIMARY KEY,
+	book_id bigint,
+	title text,
+	text text,
+	chapter_order bigint
+);
+
+CREATE TABLE users (
+	id bigserial PRIMARY KEY,
+	created_at timestamptz,
+	updated_at timestamptz,
+	deleted_at timestamptz,
+	name text NOT NULL,
+	email text NOT NULL,
+	password text NOT NULL,
+	role text NOT NULL DEFAULT 'user',
+	verified boolean NOT NULL DEFAULT false,
+	banned_at timestamptz,
+	CONSTRAINT uni_users_email UNIQUE (email)
+);
+CREATE INDEX idx_users_deleted_at ON users (deleted_at);
+
+CREATE TABLE user_favorites (
+	user_id bigint,
+	book_id bigint,
+	PRIMARY KEY (user_id, book_id),
+	CONSTRAINT fk_user_favorites_user FOREIGN KEY (user_id) REFERENCES users (id),
+	CONSTRAINT fk_user_favorites_book_base FOREIGN KEY (book_id) REFERENCES books (id)
+);
+
+CREATE TABLE reading_progress (
+	user_id bigint,
+	book_id bigint,
+	chapter_id bigint,
+	last_index bigint,
+	updated_at timestamptz,
+	PRIMARY KEY (user_id, book_id),
+	CONSTRAINT fk_users_reading_progress FOREIGN KEY (user_id) REFERENCES users (id)
+);
+
+CREATE TABLE reading_sessions (
+	id bigserial PRIMARY KEY,
+	user_id bigint NOT NULL,
+	book_id bigint NOT NULL,
+	chapter_id bigint NOT NULL,
+	started_at timestamptz NOT NULL,
+	ended_at timestamptz NOT NULL,
+	words_read bigint NOT NULL,
+	wpm bigint NOT NULL,
+	created_at timestamptz
+);
+CREATE INDEX idx_reading_sessions_user_started ON reading_sessions (user_id, started_at);
+
+CREATE TABLE user_preferences (
+	user_id bigint PRIMARY KEY,
+	wpm bigint NOT NULL,
+	chunk_size bigint NOT NULL,
+	font_family text NOT NULL,
+	font_size text NOT NULL,
+	theme text NOT NULL,
+	colour text NOT NULL,
+	clause_pause decimal NOT NULL,
+	sentence_pause decimal NOT NULL,
+	paragraph_pause decimal NOT NULL,
+	pivot_highlight boolean NOT NULL,
+	updated_at timestamptz,
+	CONSTRAINT fk_users_preferences FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE TABLE sessions (
+	id bigserial PRIMARY KEY,
+	user_id bigint NOT NULL,
+	token_id text NOT NULL,
+	family_id text NOT NULL,
+	device text,
+	created_at timestamptz,
+	last_used_at timestamptz,
+	expires_at timestamptz,
+	revoked_at timestamptz
+);
+CREATE INDEX idx_sessions_family_id ON sessions (family_id);
+CREATE UNIQUE INDEX idx_sessions_token_id ON sessions (token_id);
+CREATE INDEX idx_sessions_user_id ON sessions (user_id);
+
+CREATE TABLE user_tokens (
+	id bigserial PRIMARY KEY,
+	user_id bigint NOT NULL,
+	purpose text NOT NULL,
+	token_hash text NOT NULL,
+	expires_at timestamptz NOT NULL,
+	used_at timestamptz,
+	created_at timestamptz
+);
+CREATE UNIQUE INDEX idx_user_tokens_token_hash ON user_tokens (token_hash);
+CREATE INDEX idx_user_tokens_user_id ON user_tokens (user_id);
@@ -0,0 +1,17 @@
+-- Generated tsvector columns, so Postgres keeps them up to date on every
+-- insert and update of a book or chapter. Weights rank a match in the title
+-- above one in the author, description or chapter text. The configuration
+-- must match models.SearchConfig.
+
+ALTER TABLE books ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (
+	setweight(to_tsvector('russian', coalesce(name, '')), 'A') ||
+	setweight(to_tsvector('russian', coalesce(author, '')), 'B') ||
+	setweight(to_tsvector('russian', coalesce(description, '')), 'C')
+) STORED;
+CREATE INDEX IF NOT EXISTS books_search_vector_idx ON books USING GIN (search_vector);
+
+ALTER TABLE chapters ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (
+	setweight(to_tsvector('russian', coalesce(title, '')), 'A') ||
+	setweight(to_tsvector('russian', coalesce(text, '')), 'D')
+) STORED;
+CREATE INDEX IF NOT EXISTS chapters_search_vector_idx ON chapters USING GIN (search_vector);
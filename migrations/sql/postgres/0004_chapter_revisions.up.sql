@@ -0,0 +1,12 @@
+-- Every update of a chapter keeps the full text it was saved with.
+CREATE TABLE chapter_revisions (
+	id bigserial PRIMARY KEY,
+	chapter_id bigint NOT NULL,
+	user_id bigint,
+	title text,
+	text text,
+	restored_from bigint,
+	created_at timestamptz,
+	CONSTRAINT fk_chapter_revisions_chapter FOREIGN KEY (chapter_id) REFERENCES chapters (id) ON DELETE CASCADE
+);
+CREATE INDEX idx_chapter_revisions_chapter ON chapter_revisions (chapter_id, created_at);
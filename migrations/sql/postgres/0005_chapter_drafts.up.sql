@@ -0,0 +1,14 @@
+-- Autosaved editor content, one row per user and chapter. chapter_id 0 is
+-- the chapter being added to the book.
+CREATE TABLE chapter_drafts (
+	id bigserial PRIMARY KEY,
+	user_id bigint NOT NULL,
+	book_id bigint NOT NULL,
+	chapter_id bigint NOT NULL DEFAULT 0,
+	title text,
+	text text,
+	updated_at timestamptz,
+	CONSTRAINT fk_chapter_drafts_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+	CONSTRAINT fk_chapter_drafts_book FOREIGN KEY (book_id) REFERENCES books (id) ON DELETE CASCADE
+);
+CREATE UNIQUE INDEX idx_chapter_drafts_slot ON chapter_drafts (user_id, book_id, chapter_id);
@@ -0,0 +1,11 @@
+DROP TABLE IF EXISTS user_tokens;
+DROP TABLE IF EXISTS sessions;
+DROP TABLE IF EXISTS user_preferences;
+DROP TABLE IF EXISTS reading_sessions;
+DROP TABLE IF EXISTS reading_progress;
+DROP TABLE IF EXISTS user_favorites;
+DROP TABLE IF EXISTS users;
+DROP TABLE IF EXISTS chapters;
+DROP TABLE IF EXISTS book_labels;
+DROP TABLE IF EXISTS labels;
+DROP TABLE IF EXISTS books;
@@ -0,0 +1,127 @@
+-- The schema of a new database. It does not adopt one that AutoMigrate
+-- created before migrations existed: those lack columns added since, so the
+-- tables are created without IF NOT EXISTS and such a database fails here.
+
+CREATE TABLE books (
+	id integer PRIMARY KEY AUTOINCREMENT,
+	name text NOT NULL,
+	author text NOT NULL,
+	cover_path text,
+	cover_thumb_path text,
+	release_date datetime,
+	publication_year integer,
+	released numeric NOT NULL DEFAULT false,
+	description text,
+	creator_user_id integer
+);
+
+CREATE TABLE labels (
+	id integer PRIMARY KEY AUTOINCREMENT,
+	name text NOT NULL,
+	CONSTRAINT uni_labels_name UNIQUE (name)
+);
+
+CREATE TABLE book_labels (
+	book_id integer,
+	label_id integer,
+	PRIMARY KEY (book_id, label_id),
+	CONSTRAINT fk_book_labels_book FOREIGN KEY (book_id) REFERENCES books (id),
+	CONSTRAINT fk_book_labels_label FOREIGN KEY (label_id) REFERENCES labels (id)
+);
+
+CREATE TABLE chapters (
+	id integer PRIMARY KEY AUTOINCREMENT,
+	book_id integer,
+	title text,
+	text text,
+	chapter_order integer
+);
+
+CREATE TABLE users (
+	id integer PRIMARY KEY AUTOINCREMENT,
+	created_at datetime,
+	updated_at datetime,
+	deleted_at datetime,
+	name text NOT NULL,
+	email text NOT NULL,
+	password text NOT NULL,
+	role text NOT NULL DEFAULT 'user',
+	verified numeric NOT NULL DEFAULT false,
+	banned_at datetime,
+	CONSTRAINT uni_users_email UNIQUE (email)
+);
+CREATE INDEX idx_users_deleted_at ON users (deleted_at);
+
+CREATE TABLE user_favorites (
+	user_id integer,
+	book_id integer,
+	PRIMARY KEY (user_id, book_id),
+	CONSTRAINT fk_user_favorites_user FOREIGN KEY (user_id) REFERENCES users (id),
+	CONSTRAINT fk_user_favorites_book_base FOREIGN KEY (book_id) REFERENCES books (id)
+);
+
+CREATE TABLE reading_progress (
+	user_id integer,
+	book_id integer,
+	chapter_id integer,
+	last_index integer,
+	updated_at datetime,
+	PRIMARY KEY (user_id, book_id),
+	CONSTRAINT fk_users_reading_progress FOREIGN KEY (user_id) REFERENCES users (id)
+);
+
+CREATE TABLE reading_sessions (
+	id integer PRIMARY KEY AUTOINCREMENT,
+	user_id integer NOT NULL,
+	book_id integer NOT NULL,
+	chapter_id integer NOT NULL,
+	started_at datetime NOT NULL,
+	ended_at datetime NOT NULL,
+	words_read integer NOT NULL,
+	wpm integer NOT NULL,
+	created_at datetime
+);
+CREATE INDEX idx_reading_sessions_user_started ON reading_sessions (user_id, started_at);
+
+CREATE TABLE user_preferences (
+	user_id integer PRIMARY KEY,
+	wpm integer NOT NULL,
+	chunk_size integer NOT NULL,
+	font_family text NOT NULL,
+	font_size text NOT NULL,
+	theme text NOT NULL,
+	colour text NOT NULL,
+	clause_pause real NOT NULL,
+	sentence_pause real NOT NULL,
+	paragraph_pause real NOT NULL,
+	pivot_highlight numeric NOT NULL,
+	updated_at datetime,
+	CONSTRAINT fk_users_preferences FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE TABLE sessions (
+	id integer PRIMARY KEY AUTOINCREMENT,
+	user_id integer NOT NULL,
+	token_id text NOT NULL,
+	family_id text NOT NULL,
+	device text,
+	created_at datetime,
+	last_used_at datetime,
+	expires_at datetime,
+	revoked_at datetime
+);
+CREATE INDEX idx_sessions_family_id ON sessions (family_id);
+CREATE UNIQUE INDEX idx_sessions_token_id ON sessions (token_id);
+CREATE INDEX idx_sessions_user_id ON sessions (user_id);
+
+CREATE TABLE user_tokens (
+	id integer PRIMARY KEY AUTOINCREMENT,
+	user_id integer NOT NULL,
+	purpose text NOT NULL,
+	token_hash text NOT NULL,
+	expires_at datetime NOT NULL,
+	used_at datetime,
+	created_at datetime
+);
+CREATE UNIQUE INDEX idx_user_tokens_token_hash ON user_tokens (token_hash);
+CREATE INDEX idx_user_tokens_user_id ON user_tokens (user_id);
@@ -0,0 +1 @@
+-- Full-text search columns are Postgres only. SQLite searches by substring.
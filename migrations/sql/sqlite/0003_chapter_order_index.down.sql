@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_chapters_book_order;
@@ -0,0 +1,12 @@
+-- Every update of a chapter keeps the full text it was saved with.
+CREATE TABLE chapter_revisions (
+	id integer PRIMARY KEY AUTOINCREMENT,
+	chapter_id integer NOT NULL,
+	user_id integer,
+	title text,
+	text text,
+	restored_from integer,
+	created_at datetime,
+	CONSTRAINT fk_chapter_revisions_chapter FOREIGN KEY (chapter_id) REFERENCES chapters (id) ON DELETE CASCADE
+);
+CREATE INDEX idx_chapter_revisions_chapter ON chapter_revisions (chapter_id, created_at);
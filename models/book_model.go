@@ -1,9 +1,11 @@
 package models
 
 import (
-	"encoding/json"
 	"html/template"
+	"strings"
 	"time"
+
+	"gorm.io/gorm"
 )
 
 const StaticCoversPath = "/covers/"
@@ -13,6 +15,24 @@ type BookBase struct {
 	Name      string       `json:"name" form:"name" gorm:"not null"`
 	Author    string       `json:"author" form:"author" gorm:"not null"`
 	CoverPath template.URL `json:"cover_path" gorm:"column:cover_path"`
+	// CoverThumbPath is the small variant of the cover for book grids.
+	CoverThumbPath template.URL `json:"cover_thumb_path" gorm:"column:cover_thumb_path"`
+}
+
+// ThumbPath is the cover to show in book grids: the thumbnail, or the full
+// cover for books uploaded before thumbnails existed.
+func (b BookBase) ThumbPath() template.URL {
+	if b.CoverThumbPath != "" {
+		return b.CoverThumbPath
+	}
+	return b.CoverPath
+}
+
+// WithCoverURLs returns the book with its stored cover names replaced by URLs.
+func (b BookBase) WithCoverURLs() BookBase {
+	b.CoverThumbPath = CoverURL(b.ThumbPath())
+	b.CoverPath = CoverURL(b.CoverPath)
+	return b
 }
 
 type Book struct {
@@ -40,14 +60,15 @@ func (BookBase) TableName() string {
 type GetBook struct {
 	BookBase
 
-	Description     string          `json:"description"`
-	PublicationYear int             `json:"publication_year"`
-	Chapters        []*Chapter      `json:"chapters" gorm:"foreignKey:BookID;references:BookID"`
-	CreatorUserID   uint            `json:"creator_user_id"`
-	IsFavorited     bool            `json:"is_favorited" gorm:"-"`
-	IsCreator       bool            `json:"is_creator" gorm:"-"`
-	BookLabels      []*Label        `json:"book_labels" gorm:"many2many:book_labels;joinForeignKey:book_id;joinReferences:label_id"`
-	Progress        ReadingProgress `json:"progress" gorm:"-"`
+	Description     string           `json:"description"`
+	PublicationYear int              `json:"publication_year"`
+	Chapters        []*Chapter       `json:"chapters" gorm:"foreignKey:BookID;references:BookID"`
+	CreatorUserID   uint             `json:"creator_user_id"`
+	IsFavorited     bool             `json:"is_favorited" gorm:"-"`
+	IsCreator       bool             `json:"is_creator" gorm:"-"`
+	BookLabels      []*Label         `json:"book_labels" gorm:"many2many:book_labels;joinForeignKey:book_id;joinReferences:label_id"`
+	Progress        ReadingProgress  `json:"progress" gorm:"-"`
+	Preferences     *UserPreferences `json:"-" gorm:"-"`
 }
 
 type Chapter struct {
@@ -57,11 +78,164 @@ type Chapter struct {
 	Title        string `json:"title" gorm:"column:title"`
 	Text         string `json:"text" gorm:"column:text"`
 	ChapterOrder int    `json:"chapter_order" gorm:"column:chapter_order"`
+	// WordCount is the number of words of Text, nil for chapters written
+	// before it was stored.
+	WordCount *int `json:"-" gorm:"column:word_count"`
+}
+
+// CountWords counts the whitespace-separated words of a chapter text.
+func CountWords(text string) int {
+	return len(strings.Fields(text))
+}
+
+// BeforeCreate stores the word count of new chapters. Updates that change
+// the text set word_count along with it.
+func (c *Chapter) BeforeCreate(tx *gorm.DB) error {
+	words := CountWords(c.Text)
+	c.WordCount = &words
+	return nil
+}
+
+// Filter codes of a book listing.
+const (
+	FilterReleased  = "0"
+	FilterStarted   = "1"
+	FilterCreated   = "2"
+	FilterFavourite = "3"
+)
+
+// Sort orders of a book listing.
+const (
+	SortName            = "name"
+	SortAuthor          = "author"
+	SortReleaseDate     = "release_date"
+	SortPublicationYear = "publication_year"
+	SortPopularity      = "popularity"
+)
+
+// BookQuery selects one page of a book listing. Code is one of the Filter
+// constants; an empty code lists every book. Cursor is the NextCursor of the
+// previous page.
+type BookQuery struct {
+	Keyword  string `form:"keyword"`
+	LabelIDs []uint `form:"-"`
+	Code     string `form:"code"`
+	Sort     string `form:"sort"`
+	Order    string `form:"order"`
+	Cursor   string `form:"cursor"`
+	Limit    int    `form:"limit"`
+	UserID   uint   `form:"-"`
+}
+
+// BookPage is one page of a book listing. NextCursor is empty on the last page.
+type BookPage struct {
+	Books      []BookBase `json:"books"`
+	NextCursor string     `json:"next_cursor,omitempty"`
+	Total      int64      `json:"total"`
+}
+
+// SearchQuery selects one page of full-text search results. Query accepts
+// web search syntax: "quoted phrases", -excluded words and OR.
+type SearchQuery struct {
+	Query   string `form:"q" binding:"required"`
+	Page    int    `form:"page"`
+	PerPage int    `form:"per_page"`
+}
+
+// SearchHit is a book that matched a full-text search, with the passages of
+// the chapters that matched best. Snippets mark matched words with <mark>
+// and are otherwise HTML-escaped.
+type SearchHit struct {
+	Book     BookBase        `json:"book"`
+	Rank     float64         `json:"rank"`
+	Snippets []SearchSnippet `json:"snippets"`
+}
+
+// SearchSnippet is a highlighted passage of a chapter. ChapterID is the
+// chapter order, like in reader URLs.
+type SearchSnippet struct {
+	ChapterID    int    `json:"chapter_id"`
+	ChapterTitle string `json:"chapter_title"`
+	Text         string `json:"text"`
+}
+
+// SearchPage is one page of full-text search results, best match first.
+type SearchPage struct {
+	Hits    []SearchHit `json:"hits"`
+	Page    int         `json:"page"`
+	PerPage int         `json:"per_page"`
+	Total   int64       `json:"total"`
+}
+
+// ChapterSummary lists a chapter without its text.
+type ChapterSummary struct {
+	ChapterID    uint   `json:"id"`
+	Title        string `json:"title"`
+	ChapterOrder int    `json:"chapter_order"`
+}
+
+// BookDetail is a book as the JSON API returns it: metadata, table of
+// contents and the state of the book for the current user.
+type BookDetail struct {
+	BookBase
+
+	Description     string           `json:"description"`
+	PublicationYear int              `json:"publication_year"`
+	CreatorUserID   uint             `json:"creator_user_id"`
+	Labels          []*Label         `json:"labels"`
+	Chapters        []ChapterSummary `json:"chapters"`
+	IsFavorited     bool             `json:"is_favorited"`
+	IsCreator       bool             `json:"is_creator"`
+	Progress        ReadingProgress  `json:"progress"`
+}
+
+// NewBookDetail converts a book loaded with its chapters and labels.
+func NewBookDetail(book GetBook) BookDetail {
+	detail := BookDetail{
+		BookBase:        book.BookBase,
+		Description:     book.Description,
+		PublicationYear: book.PublicationYear,
+		CreatorUserID:   book.CreatorUserID,
+		Labels:          book.BookLabels,
+		Chapters:        make([]ChapterSummary, 0, len(book.Chapters)),
+		IsFavorited:     book.IsFavorited,
+		IsCreator:       book.IsCreator,
+		Progress:        book.Progress,
+	}
+	if detail.Labels == nil {
+		detail.Labels = []*Label{}
+	}
+
+	for _, ch := range book.Chapters {
+		detail.Chapters = append(detail.Chapters, ChapterSummary{
+			ChapterID:    ch.ChapterID,
+			Title:        ch.Title,
+			ChapterOrder: ch.ChapterOrder,
+		})
+	}
+
+	return detail
+}
+
+// CoverURL returns the public URL of a stored cover, or the default cover.
+func CoverURL(path template.URL) template.URL {
+	if path == "" || path == "null" {
+		return FormatCoverURL("")
+	}
+
+	return template.URL(StaticCoversPath + string(path))
+}
+
+// ChapterURI addresses a chapter by its book and its position in the book.
+type ChapterURI struct {
+	BookID    uint `uri:"book_id" binding:"required"`
+	ChapterID uint `uri:"chapter_id" binding:"required"`
 }
 
 type ChapterResponse struct {
 	BookBase
-	Chapter Chapter `json:"chapter"`
+	Chapter     Chapter          `json:"chapter"`
+	Preferences *UserPreferences `json:"-" gorm:"-"`
 }
 
 type Label struct {
@@ -69,6 +243,11 @@ type Label struct {
 	Name    string `json:"name" gorm:"unique;not null"`
 }
 
+// LabelInput names a label created or renamed by an admin.
+type LabelInput struct {
+	Name string `json:"name" binding:"required"`
+}
+
 func FormatCoverURL(path string) template.URL {
 	if path == "" || path == "null" {
 		return template.URL("/static/default_cover.png")
@@ -77,9 +256,11 @@ func FormatCoverURL(path string) template.URL {
 	return template.URL(path)
 }
 
-func MarshalBookList(books []BookBase) ([]byte, error) {
-	for i := range books {
-		books[i].CoverPath = FormatCoverURL(string(books[i].CoverPath))
-	}
-	return json.Marshal(books)
+// ReaderQuery tunes how the RSVP reader presents a chapter. Chunk defaults
+// to the user's words per flash; Ramp is the number of flashes it takes to
+// reach the target speed from RampFrom of it (0.5 by default), 0 for none.
+type ReaderQuery struct {
+	Chunk    int     `form:"chunk" binding:"omitempty,min=1,max=5"`
+	Ramp     int     `form:"ramp" binding:"omitempty,min=0,max=200"`
+	RampFrom float64 `form:"ramp_from" binding:"omitempty,gt=0,lt=1"`
 }
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// ChapterDraft is the unsaved state of the chapter editor, autosaved while
+// a user types. Each user has one draft per chapter, and one per book for
+// the chapter they are adding (ChapterID 0). Saving the chapter discards it.
+type ChapterDraft struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	UserID    uint      `json:"user_id" gorm:"not null;uniqueIndex:idx_chapter_drafts_slot"`
+	BookID    uint      `json:"book_id" gorm:"not null;uniqueIndex:idx_chapter_drafts_slot"`
+	ChapterID uint      `json:"chapter_id" gorm:"not null;default:0;uniqueIndex:idx_chapter_drafts_slot"`
+	Title     string    `json:"title"`
+	Text      string    `json:"text" gorm:"type:text"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// DraftInput is the editor content sent on autosave.
+type DraftInput struct {
+	Title string `json:"title"`
+	Text  string `json:"text"`
+}
+
+// DraftURI addresses a draft. ChapterID is 0 for a new chapter of the book.
+type DraftURI struct {
+	BookID    uint `uri:"book_id" binding:"required"`
+	ChapterID uint `uri:"chapter_id"`
+}
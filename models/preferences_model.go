@@ -0,0 +1,91 @@
+package models
+
+import "time"
+
+// UserPreferences are the reader settings of a user, shared by every device
+// they sign in on. Users without a row get DefaultPreferences.
+type UserPreferences struct {
+	UserID uint `json:"-" gorm:"primaryKey;autoIncrement:false"`
+
+	WPM int `json:"wpm" gorm:"column:wpm;not null"`
+	// ChunkSize is the number of words shown per flash.
+	ChunkSize  int    `json:"chunk_size" gorm:"not null"`
+	FontFamily string `json:"font_family" gorm:"not null"`
+	FontSize   string `json:"font_size" gorm:"not null"`
+	// Theme is "light", "dark" or "system" to follow the device setting.
+	Theme  string `json:"theme" gorm:"not null"`
+	Colour string `json:"colour" gorm:"not null"`
+	// Pause multipliers scale the extra display time after a clause, a
+	// sentence and a paragraph.
+	ClausePause    float64 `json:"clause_pause" gorm:"not null"`
+	SentencePause  float64 `json:"sentence_pause" gorm:"not null"`
+	ParagraphPause float64 `json:"paragraph_pause" gorm:"not null"`
+	PivotHighlight bool    `json:"pivot_highlight" gorm:"not null"`
+
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// DefaultPreferences returns the settings of users who never changed them.
+func DefaultPreferences() UserPreferences {
+	return UserPreferences{
+		WPM:            600,
+		ChunkSize:      1,
+		FontFamily:     "Inter, sans-serif",
+		FontSize:       "1.1rem",
+		Theme:          "system",
+		Colour:         "#1e3d59",
+		ClausePause:    1,
+		SentencePause:  1,
+		ParagraphPause: 1,
+		PivotHighlight: true,
+	}
+}
+
+// PreferencesInput changes some of the reader settings. Omitted fields keep
+// their value.
+type PreferencesInput struct {
+	WPM            *int     `json:"wpm" binding:"omitempty,min=100,max=5000"`
+	ChunkSize      *int     `json:"chunk_size" binding:"omitempty,min=1,max=5"`
+	FontFamily     *string  `json:"font_family" binding:"omitempty,max=100"`
+	FontSize       *string  `json:"font_size" binding:"omitempty,oneof=1.0rem 1.1rem 1.3rem 1.5rem"`
+	Theme          *string  `json:"theme" binding:"omitempty,oneof=light dark system"`
+	Colour         *string  `json:"colour" binding:"omitempty,hexcolor"`
+	ClausePause    *float64 `json:"clause_pause" binding:"omitempty,min=0,max=5"`
+	SentencePause  *float64 `json:"sentence_pause" binding:"omitempty,min=0,max=5"`
+	ParagraphPause *float64 `json:"paragraph_pause" binding:"omitempty,min=0,max=5"`
+	PivotHighlight *bool    `json:"pivot_highlight"`
+}
+
+// Apply copies the fields set in input into p.
+func (input PreferencesInput) Apply(p *UserPreferences) {
+	if input.WPM != nil {
+		p.WPM = *input.WPM
+	}
+	if input.ChunkSize != nil {
+		p.ChunkSize = *input.ChunkSize
+	}
+	if input.FontFamily != nil {
+		p.FontFamily = *input.FontFamily
+	}
+	if input.FontSize != nil {
+		p.FontSize = *input.FontSize
+	}
+	if input.Theme != nil {
+		p.Theme = *input.Theme
+	}
+	if input.Colour != nil {
+		p.Colour = *input.Colour
+	}
+	if input.ClausePause != nil {
+		p.ClausePause = *input.ClausePause
+	}
+	if input.SentencePause != nil {
+		p.SentencePause = *input.SentencePause
+	}
+	if input.ParagraphPause != nil {
+		p.ParagraphPause = *input.ParagraphPause
+	}
+	if input.PivotHighlight != nil {
+		p.PivotHighlight = *input.PivotHighlight
+	}
+}
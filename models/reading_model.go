@@ -0,0 +1,70 @@
+package models
+
+import "time"
+
+// ReadingSession is one uninterrupted run of the RSVP reader at a single
+// speed. ChapterID is the chapter order, like in ReadingProgress.
+type ReadingSession struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	UserID    uint      `json:"user_id" gorm:"not null;index:idx_reading_sessions_user_started"`
+	BookID    uint      `json:"book_id" gorm:"not null"`
+	ChapterID uint      `json:"chapter_id" gorm:"not null"`
+	StartedAt time.Time `json:"started_at" gorm:"not null;index:idx_reading_sessions_user_started"`
+	EndedAt   time.Time `json:"ended_at" gorm:"not null"`
+	WordsRead int       `json:"words_read" gorm:"not null"`
+	WPM       int       `json:"wpm" gorm:"column:wpm;not null"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ReadingSessionInput is a session reported by the reader.
+type ReadingSessionInput struct {
+	BookID    uint      `json:"book_id" binding:"required"`
+	ChapterID uint      `json:"chapter_id" binding:"required"`
+	StartedAt time.Time `json:"started_at" binding:"required"`
+	EndedAt   time.Time `json:"ended_at" binding:"required"`
+	WordsRead int       `json:"words_read" binding:"required,min=1"`
+	WPM       int       `json:"wpm" binding:"required,min=1,max=5000"`
+}
+
+// StatsQuery selects the period of the reading statistics: the last Days
+// days, split into calendar days of the IANA time zone TZ (UTC by default).
+type StatsQuery struct {
+	Days int    `form:"days"`
+	TZ   string `form:"tz"`
+}
+
+// ReadingStats summarises how a user reads. Averages are weighted by the
+// words read at each speed.
+type ReadingStats struct {
+	From          string         `json:"from"`
+	To            string         `json:"to"`
+	TimeZone      string         `json:"time_zone"`
+	Days          []DailyReading `json:"days"`
+	Sessions      int            `json:"sessions"`
+	TotalWords    int            `json:"total_words"`
+	TotalMinutes  float64        `json:"total_minutes"`
+	AverageWPM    float64        `json:"average_wpm"`
+	MaxWPM        int            `json:"max_wpm"`
+	CurrentStreak int            `json:"current_streak"`
+	LongestStreak int            `json:"longest_streak"`
+	Books         []BookEstimate `json:"books"`
+}
+
+// DailyReading is the reading of one calendar day.
+type DailyReading struct {
+	Date       string  `json:"date"`
+	Words      int     `json:"words"`
+	Minutes    float64 `json:"minutes"`
+	AverageWPM float64 `json:"average_wpm"`
+	MaxWPM     int     `json:"max_wpm"`
+}
+
+// BookEstimate is a started book with the time it takes to finish at the
+// user's average speed. MinutesLeft is 0 when the user has no sessions yet.
+type BookEstimate struct {
+	Book        BookBase `json:"book"`
+	TotalWords  int      `json:"total_words"`
+	WordsRead   int      `json:"words_read"`
+	Percent     float64  `json:"percent"`
+	MinutesLeft float64  `json:"minutes_left"`
+}
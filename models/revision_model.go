@@ -0,0 +1,65 @@
+package models
+
+import "time"
+
+// ChapterRevision is a saved state of a chapter: one is written every time
+// the chapter is updated or restored. UserID is nil for the text a chapter
+// had before its first tracked update.
+type ChapterRevision struct {
+	ID        uint   `json:"id" gorm:"primaryKey"`
+	ChapterID uint   `json:"chapter_id" gorm:"not null;index:idx_chapter_revisions_chapter"`
+	UserID    *uint  `json:"user_id"`
+	Title     string `json:"title"`
+	Text      string `json:"text" gorm:"type:text"`
+	// RestoredFrom is the revision this one brought back, if any.
+	RestoredFrom *uint     `json:"restored_from"`
+	CreatedAt    time.Time `json:"created_at" gorm:"index:idx_chapter_revisions_chapter"`
+}
+
+// RevisionSummary lists a revision without its text.
+type RevisionSummary struct {
+	ID           uint      `json:"id"`
+	UserID       *uint     `json:"user_id"`
+	UserName     string    `json:"user_name"`
+	Title        string    `json:"title"`
+	Words        int       `json:"words"`
+	RestoredFrom *uint     `json:"restored_from"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// RevisionURI addresses one revision of a chapter.
+type RevisionURI struct {
+	ChapterURI
+	RevisionID uint `uri:"revision_id" binding:"required"`
+}
+
+// RevisionQuery picks the two revisions of a diff, older first.
+type RevisionQuery struct {
+	From uint `form:"from" binding:"required"`
+	To   uint `form:"to" binding:"required"`
+}
+
+// Operations of a DiffOp.
+const (
+	DiffEqual  = "equal"
+	DiffInsert = "insert"
+	DiffDelete = "delete"
+)
+
+// DiffOp is a run of text that both revisions share, or that only the newer
+// (insert) or the older one (delete) has. Joining the equal and delete runs
+// gives the older text, the equal and insert runs the newer one.
+type DiffOp struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+// RevisionDiff is the word-level difference between the texts of two
+// revisions. Inserted and Deleted count words.
+type RevisionDiff struct {
+	From     RevisionSummary `json:"from"`
+	To       RevisionSummary `json:"to"`
+	Ops      []DiffOp        `json:"ops"`
+	Inserted int             `json:"inserted"`
+	Deleted  int             `json:"deleted"`
+}
@@ -0,0 +1,8 @@
+package models
+
+// SearchConfig is the text search configuration of the search columns. The
+// built-in "russian" configuration stems Cyrillic words with the Russian and
+// Latin words with the English Snowball stemmer, so it covers both languages
+// of the library. The columns themselves are added by the full_text_search
+// migration, which has to use the same configuration.
+const SearchConfig = "russian"
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// Session is one refresh token issued to a device. Every refresh revokes the
+// presented session and creates the next one in the same family, so a family
+// is one logged-in device and only its newest session is active.
+type Session struct {
+	ID         uint       `json:"id" gorm:"primaryKey"`
+	UserID     uint       `json:"-" gorm:"not null;index"`
+	TokenID    string     `json:"-" gorm:"uniqueIndex;not null"`
+	FamilyID   string     `json:"-" gorm:"index;not null"`
+	Device     string     `json:"device"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt time.Time  `json:"last_used_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	RevokedAt  *time.Time `json:"-"`
+
+	Current bool `json:"current" gorm:"-"`
+}
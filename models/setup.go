@@ -3,13 +3,21 @@ package models
 import (
 	"fmt"
 	"log"
+	"net/url"
+	"strings"
 
+	"github.com/st107853/fast_reading/config"
+	"gorm.io/driver/mysql"
 	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
+)
 
-	_ "github.com/jinzhu/gorm/dialects/mysql"
-	_ "github.com/jinzhu/gorm/dialects/postgres"
-	_ "github.com/jinzhu/gorm/dialects/sqlite"
+// Database drivers selectable with DB_DRIVER.
+const (
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+	DriverMySQL    = "mysql"
 )
 
 type Database struct {
@@ -19,10 +27,11 @@ type Database struct {
 var DB *gorm.DB
 
 // Opening a database and save the reference to `Database` struct.
-func OpenDbConnectionWithConfig(host, dbname, user, password string) (*gorm.DB, error) {
-
-	dsn := fmt.Sprintf("host=%s dbname=%s user=%s password=%s",
-		host, dbname, user, password)
+func OpenDbConnectionWithConfig(conf config.Config) (*gorm.DB, error) {
+	dialector, err := Dialector(conf)
+	if err != nil {
+		return nil, err
+	}
 
 	// 2. Настраиваем GORM Logger (лучше, чем fmt.Println)
 	// newLogger := logger.New(
@@ -35,7 +44,7 @@ func OpenDbConnectionWithConfig(host, dbname, user, password string) (*gorm.DB,
 	// )
 
 	// 3. Открываем соединение
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
+	db, err := gorm.Open(dialector, &gorm.Config{
 		Logger: nil, // Используем настроенный логгер
 	})
 
@@ -44,16 +53,56 @@ func OpenDbConnectionWithConfig(host, dbname, user, password string) (*gorm.DB,
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
 
-	// 4. Запускаем AutoMigrate
-	// (Используем новую модель User)
-
-	log.Println("Database connection successful and migrated.")
+	log.Println("Database connection successful.")
 	DB = db
 
 	// 5. Возвращаем 'db', а не ошибку
 	return db, nil
 }
 
+// Dialector returns the GORM dialector of the configured driver. SQLite
+// opens the file named by DBname, which may carry its own DSN options; the
+// others connect to Host and DBPort.
+func Dialector(conf config.Config) (gorm.Dialector, error) {
+	switch conf.DBDriver {
+	case "", DriverPostgres:
+		dsn := fmt.Sprintf("host=%s dbname=%s user=%s password=%s",
+			conf.Host, conf.DBname, conf.DBuser, conf.DBpassword)
+		if conf.DBPort != "" {
+			dsn += " port=" + conf.DBPort
+		}
+		return postgres.Open(dsn), nil
+	case DriverSQLite:
+		return sqlite.Open(sqliteDSN(conf.DBname)), nil
+	case DriverMySQL:
+		port := conf.DBPort
+		if port == "" {
+			port = "3306"
+		}
+		dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=UTC",
+			conf.DBuser, conf.DBpassword, conf.Host, port, conf.DBname)
+		return mysql.Open(dsn), nil
+	default:
+		return nil, fmt.Errorf("unknown database driver %q", conf.DBDriver)
+	}
+}
+
+// sqliteDSN turns foreign keys on for every connection, which SQLite leaves
+// off unless asked, keeping the other options of dsn. A DSN that sets the
+// option itself is left alone.
+func sqliteDSN(dsn string) string {
+	name, query, hasQuery := strings.Cut(dsn, "?")
+	if !hasQuery {
+		return name + "?_foreign_keys=on"
+	}
+
+	values, err := url.ParseQuery(query)
+	if err == nil && (values.Has("_foreign_keys") || values.Has("_fk")) {
+		return dsn
+	}
+	return dsn + "&_foreign_keys=on"
+}
+
 // Delete the database after running testing cases.
 func RemoveDb(db *gorm.DB) error {
 	sqlDB, err := db.DB() // Получаем *sql.DB из *gorm.DB
@@ -13,16 +13,105 @@ type User struct {
 	Password string `json:"-" gorm:"not null"`
 	Role     string `json:"role" gorm:"default:'user';not null"`
 	Verified bool   `json:"verified" gorm:"default:false;not null"`
+	// BannedAt is set while an admin has suspended the account.
+	BannedAt *time.Time `json:"banned_at"`
 
 	FavoriteBooks   []*BookBase        `json:"favorite_books" gorm:"many2many:user_favorites;joinForeignKey:user_id;joinReferences:book_id"`
 	ReadingProgress []*ReadingProgress `json:"reading_progress" gorm:"foreignKey:UserID;joinForeignKey:user_id;joinReferences:book_id"`
+	Preferences     *UserPreferences   `json:"-" gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+}
+
+// User roles.
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+// IsAdmin reports whether the user has the admin role.
+func (u *User) IsAdmin() bool {
+	return u.Role == RoleAdmin
+}
+
+// CanManage reports whether the user may edit, release or delete a book
+// created by creatorUserID and its chapters.
+func (u *User) CanManage(creatorUserID uint) bool {
+	return u.IsAdmin() || u.ID == creatorUserID
+}
+
+// UserQuery selects a page of users in the admin console.
+type UserQuery struct {
+	Search  string `form:"q"`
+	Page    int    `form:"page"`
+	PerPage int    `form:"per_page"`
+}
+
+// UserSummary is a user as admins see it.
+type UserSummary struct {
+	ID        uint       `json:"id"`
+	Name      string     `json:"name"`
+	Email     string     `json:"email"`
+	Role      string     `json:"role"`
+	Verified  bool       `json:"verified"`
+	BannedAt  *time.Time `json:"banned_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// UserPage is one page of a user listing.
+type UserPage struct {
+	Users   []UserSummary `json:"users"`
+	Page    int           `json:"page"`
+	PerPage int           `json:"per_page"`
+	Total   int64         `json:"total"`
 }
 
 type ReadingProgress struct {
-	UserID    uint `gorm:"primaryKey"`
-	BookID    uint `gorm:"primaryKey" uri:"book_id" binding:"required"`
-	ChapterID uint `uri:"chapter_id"`
-	LastIndex uint `uri:"last_index"`
+	UserID    uint `json:"user_id" gorm:"primaryKey"`
+	BookID    uint `json:"book_id" gorm:"primaryKey" uri:"book_id" binding:"required"`
+	ChapterID uint `json:"chapter_id" uri:"chapter_id"`
+	LastIndex uint `json:"last_index" uri:"last_index"`
+	// UpdatedAt is when the reader was at this position, which for
+	// bookmarks synced from offline clients is earlier than when it was saved.
+	UpdatedAt *time.Time `json:"updated_at"`
+}
+
+// BookmarkUpdate is a reading position a client saved while offline.
+type BookmarkUpdate struct {
+	BookID    uint      `json:"book_id" binding:"required"`
+	ChapterID uint      `json:"chapter_id" binding:"required"`
+	LastIndex uint      `json:"last_index"`
+	UpdatedAt time.Time `json:"updated_at" binding:"required"`
+}
+
+// SyncInput is the queue of bookmarks of an offline client, in any order.
+type SyncInput struct {
+	Bookmarks []BookmarkUpdate `json:"bookmarks" binding:"required,max=500,dive"`
+}
+
+// SyncResult is the reading progress of the synced books after the merge.
+// Applied counts the bookmarks newer than the stored ones; bookmarks of
+// books that no longer exist are listed in Rejected.
+type SyncResult struct {
+	Applied  int               `json:"applied"`
+	Rejected []uint            `json:"rejected"`
+	Progress []ReadingProgress `json:"progress"`
+}
+
+// Purposes of a UserToken.
+const (
+	TokenPurposeVerify = "verify"
+	TokenPurposeReset  = "reset"
+)
+
+// UserToken is a single-use token sent to a user by email. Only the SHA-256
+// hash of the token is stored.
+type UserToken struct {
+	ID        uint      `gorm:"primaryKey"`
+	UserID    uint      `gorm:"not null;index"`
+	Purpose   string    `gorm:"not null"`
+	TokenHash string    `gorm:"uniqueIndex;not null"`
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
 }
 
 func NewReadingProgress() *ReadingProgress {
@@ -35,6 +124,18 @@ func (ReadingProgress) TableName() string {
 	return "reading_progress"
 }
 
+// ForgotPasswordInput is the email a password reset link is sent to.
+type ForgotPasswordInput struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// ResetPasswordInput sets a new password using an emailed reset token.
+type ResetPasswordInput struct {
+	Token           string `json:"token" binding:"required"`
+	Password        string `json:"password" binding:"required,min=8"`
+	PasswordConfirm string `json:"passwordConfirm" binding:"required"`
+}
+
 // SignUpInput specify the fields required to register a new user.
 type SignUpInput struct {
 	Name            string    `json:"name" gorm:"name"`
@@ -51,6 +152,15 @@ type SignUpInput struct {
 type SignInInput struct {
 	Email    string `json:"email" gorm:"email" binding:"required"`
 	Password string `json:"password" gorm:"password" binding:"required"`
+	// Device describes the client (its User-Agent) in the session list.
+	Device string `json:"-" gorm:"-"`
+}
+
+// SignInResponse holds the signed in user and the tokens issued for them.
+type SignInResponse struct {
+	User         UserResponse `json:"user"`
+	AccessToken  string       `json:"access_token"`
+	RefreshToken string       `json:"refresh_token"`
 }
 
 type DBResponse struct {
@@ -0,0 +1,192 @@
+package openapi
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Security scheme names used by Route.Auth.
+const (
+	CookieAuth = "cookieAuth"
+	BearerAuth = "bearerAuth"
+)
+
+// Route documents one registered route.
+type Route struct {
+	Method string
+	// Path is the full route path in Gin syntax, e.g. /library/book/:book_id.
+	Path    string
+	Tag     string
+	Summary string
+	// Auth marks routes that need a logged in user.
+	Auth  bool
+	Query []Param
+	// JSON is the type of the JSON request body.
+	JSON interface{}
+	// Form is the type of a multipart form body, read through its form tags;
+	// Files names its file fields.
+	Form  interface{}
+	Files []string
+	// Status is the success status code, 200 when zero.
+	Status int
+	// Response is the type of the success response body. ContentType
+	// defaults to application/json; HTML pages use text/html.
+	Response    interface{}
+	ContentType string
+}
+
+// Param documents a query parameter.
+type Param struct {
+	Name        string
+	Type        string // string when empty
+	Description string
+	Required    bool
+}
+
+// Build creates the document for the given routes. Extra values are added to
+// the components so models can be documented even when no route returns
+// them directly.
+func Build(info Info, routes []Route, extra ...interface{}) *Document {
+	g := NewGenerator()
+	for _, v := range extra {
+		g.Schema(v)
+	}
+
+	doc := &Document{
+		OpenAPI: Version,
+		Info:    info,
+		Paths:   map[string]PathItem{},
+		Components: Components{
+			Schemas: g.Schemas,
+			SecuritySchemes: map[string]*SecurityScheme{
+				CookieAuth: {Type: "apiKey", In: "cookie", Name: "access_token"},
+				BearerAuth: {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+			},
+		},
+	}
+
+	for _, r := range routes {
+		path, params := PathFromGin(r.Path)
+		item, ok := doc.Paths[path]
+		if !ok {
+			item = PathItem{}
+			doc.Paths[path] = item
+		}
+		item[strings.ToLower(r.Method)] = g.operation(r, params)
+	}
+
+	return doc
+}
+
+// Has reports whether the document describes a Gin route.
+func (d *Document) Has(method, ginPath string) bool {
+	path, _ := PathFromGin(ginPath)
+	_, ok := d.Paths[path][strings.ToLower(method)]
+	return ok
+}
+
+// PathFromGin converts :param and *param segments to OpenAPI {param}
+// templates and returns the parameter names.
+func PathFromGin(path string) (string, []string) {
+	var params []string
+
+	segments := strings.Split(path, "/")
+	for i, seg := range segments {
+		if strings.HasPrefix(seg, ":") || strings.HasPrefix(seg, "*") {
+			params = append(params, seg[1:])
+			segments[i] = "{" + seg[1:] + "}"
+		}
+	}
+
+	return strings.Join(segments, "/"), params
+}
+
+func (g *Generator) operation(r Route, pathParams []string) *Operation {
+	op := &Operation{
+		Summary:     r.Summary,
+		OperationID: operationID(r.Method, r.Path),
+		Responses:   map[string]Response{},
+	}
+	if r.Tag != "" {
+		op.Tags = []string{r.Tag}
+	}
+	if r.Auth {
+		op.Security = []map[string][]string{{CookieAuth: {}}, {BearerAuth: {}}}
+	}
+
+	for _, name := range pathParams {
+		op.Parameters = append(op.Parameters, Parameter{Name: name, In: "path", Required: true, Schema: &Schema{Type: "string"}})
+	}
+	for _, q := range r.Query {
+		typ := q.Type
+		if typ == "" {
+			typ = "string"
+		}
+		op.Parameters = append(op.Parameters, Parameter{Name: q.Name, In: "query", Description: q.Description, Required: q.Required, Schema: &Schema{Type: typ}})
+	}
+
+	switch {
+	case r.JSON != nil:
+		op.RequestBody = &RequestBody{Required: true, Content: map[string]MediaType{
+			"application/json": {Schema: g.Schema(r.JSON)},
+		}}
+	case r.Form != nil || len(r.Files) > 0:
+		form := &Schema{Type: "object", Properties: map[string]*Schema{}}
+		if r.Form != nil {
+			form = g.FormSchema(r.Form)
+		}
+		for _, name := range r.Files {
+			form.Properties[name] = &Schema{Type: "string", Format: "binary"}
+		}
+		sort.Strings(form.Required)
+		op.RequestBody = &RequestBody{Required: true, Content: map[string]MediaType{
+			"multipart/form-data": {Schema: form},
+		}}
+	}
+
+	status := r.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	contentType := r.ContentType
+	if contentType == "" {
+		contentType = "application/json"
+	}
+
+	media := MediaType{}
+	if r.Response != nil {
+		media.Schema = g.Schema(r.Response)
+	} else if contentType == "application/json" {
+		media.Schema = &Schema{Type: "object"}
+	} else if contentType == "text/html" {
+		media.Schema = &Schema{Type: "string"}
+	} else {
+		media.Schema = &Schema{Type: "string", Format: "binary"}
+	}
+
+	op.Responses[strconv.Itoa(status)] = Response{
+		Description: http.StatusText(status),
+		Content:     map[string]MediaType{contentType: media},
+	}
+	op.Responses["default"] = Response{
+		Description: "Error",
+		Content:     map[string]MediaType{"application/json": {Schema: &Schema{Type: "object"}}},
+	}
+
+	return op
+}
+
+// operationID derives a stable identifier such as get_library_book_book_id.
+func operationID(method, path string) string {
+	id := strings.ToLower(method)
+	for _, seg := range strings.Split(path, "/") {
+		seg = strings.TrimLeft(seg, ":*")
+		seg = strings.NewReplacer(".", "_", "-", "_").Replace(seg)
+		if seg != "" {
+			id += "_" + seg
+		}
+	}
+	return id
+}
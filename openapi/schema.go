@@ -0,0 +1,162 @@
+package openapi
+
+import (
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+)
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// Generator derives schemas from Go types. Named structs are added to
+// Schemas once and referenced with $ref afterwards.
+type Generator struct {
+	Schemas map[string]*Schema
+}
+
+func NewGenerator() *Generator {
+	return &Generator{Schemas: map[string]*Schema{}}
+}
+
+// Schema returns the JSON schema of v's type, described by its json tags.
+func (g *Generator) Schema(v interface{}) *Schema {
+	return g.schemaOf(reflect.TypeOf(v))
+}
+
+// FormSchema returns an inline schema of a struct's form fields, described by
+// its form tags, as Gin binds multipart and query forms.
+func (g *Generator) FormSchema(v interface{}) *Schema {
+	t := reflect.TypeOf(v)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	g.fields(t, "form", s)
+	return s
+}
+
+func (g *Generator) schemaOf(t reflect.Type) *Schema {
+	if t == nil {
+		return &Schema{}
+	}
+
+	if t.Kind() == reflect.Ptr {
+		s := g.schemaOf(t.Elem())
+		if s.Ref != "" {
+			return s
+		}
+		c := *s
+		c.Nullable = true
+		return &c
+	}
+
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t.Implements(jsonMarshalerType), reflect.PointerTo(t).Implements(jsonMarshalerType):
+		// Custom encodings (gorm.DeletedAt and the like) cannot be inspected.
+		return &Schema{}
+	case t.Implements(textMarshalerType):
+		return &Schema{Type: "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: g.schemaOf(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schemaOf(t.Elem())}
+	case reflect.Struct:
+		return g.structSchema(t)
+	default:
+		return &Schema{}
+	}
+}
+
+func (g *Generator) structSchema(t reflect.Type) *Schema {
+	name := t.Name()
+	if name == "" {
+		s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+		g.fields(t, "json", s)
+		return s
+	}
+
+	// Unexported documentation-only types still get conventional names.
+	name = strings.ToUpper(name[:1]) + name[1:]
+
+	ref := &Schema{Ref: "#/components/schemas/" + name}
+	if _, ok := g.Schemas[name]; ok {
+		return ref
+	}
+
+	// Register before walking the fields so recursive types terminate.
+	s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	g.Schemas[name] = s
+	g.fields(t, "json", s)
+
+	return ref
+}
+
+// fields adds the exported fields of t to s. Anonymous struct fields without
+// a name in the tag are flattened, as encoding/json does.
+func (g *Generator) fields(t reflect.Type, tagKey string, s *Schema) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() && !f.Anonymous {
+			continue
+		}
+
+		name, opts := parseTag(f.Tag.Get(tagKey))
+		if name == "-" && opts == "" {
+			continue
+		}
+
+		ft := f.Type
+		if f.Anonymous && name == "" {
+			for ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct && ft != timeType {
+				g.fields(ft, tagKey, s)
+				continue
+			}
+		}
+
+		if name == "" {
+			if tagKey != "json" {
+				continue
+			}
+			name = f.Name
+		}
+
+		s.Properties[name] = g.schemaOf(ft)
+		if strings.Contains(f.Tag.Get("binding"), "required") {
+			s.Required = append(s.Required, name)
+		}
+	}
+}
+
+func parseTag(tag string) (name, opts string) {
+	if i := strings.IndexByte(tag, ','); i >= 0 {
+		return tag[:i], tag[i+1:]
+	}
+	return tag, ""
+}
@@ -0,0 +1,126 @@
+package reader
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+// MaxChunkWords is the largest number of words shown in one flash.
+const MaxChunkWords = 5
+
+// joinerLetters is the length up to which a word is taken for a preposition,
+// article or conjunction and kept with the word that follows it.
+const joinerLetters = 2
+
+// joiners are longer function words that are kept with the next word too.
+var joiners = map[string]bool{
+	"the": true, "and": true, "for": true, "but": true, "nor": true, "from": true, "with": true,
+	"для": true, "или": true, "как": true, "что": true, "над": true, "под": true, "без": true, "при": true,
+}
+
+// Chunk is a group of consecutive words shown together in one flash.
+type Chunk struct {
+	Text string `json:"text"`
+	// Pivot is the rune index inside Text of the letter aligned to the
+	// centre of the word box: the pivot of the middle word.
+	Pivot int `json:"pivot"`
+	// Start is the index of the first word in the chapter tokens.
+	Start int `json:"start"`
+	Words int `json:"words"`
+	// Delay multiplies the base display time (60s / WPM) for this chunk: the
+	// sum of the delays of its words.
+	Delay        float64 `json:"delay"`
+	ParagraphEnd bool    `json:"paragraph_end,omitempty"`
+}
+
+// Chunks groups tokens into flashes of at most size words. A chunk ends
+// early at the end of a clause, sentence or paragraph, and a function word
+// at the end of a full chunk moves on to the next one with the word it
+// belongs to.
+func Chunks(tokens []Token, size int) []Chunk {
+	size = max(1, min(size, MaxChunkWords))
+
+	var chunks []Chunk
+	for start := 0; start < len(tokens); {
+		end := start + 1
+		for end < len(tokens) && end-start < size && !phraseEnd(tokens[end-1]) {
+			end++
+		}
+		if end-start == size && end < len(tokens) && size > 1 &&
+			isJoiner(tokens[end-1]) && !isJoiner(tokens[end-2]) {
+			end--
+		}
+		chunks = append(chunks, newChunk(tokens[start:end], start))
+		start = end
+	}
+
+	return chunks
+}
+
+func newChunk(tokens []Token, start int) Chunk {
+	chunk := Chunk{
+		Start:        start,
+		Words:        len(tokens),
+		ParagraphEnd: tokens[len(tokens)-1].ParagraphEnd,
+	}
+
+	words := make([]string, len(tokens))
+	middle := len(tokens) / 2
+	for i, t := range tokens {
+		words[i] = t.Word
+		chunk.Delay += t.Delay
+		if i < middle {
+			chunk.Pivot += len([]rune(t.Word)) + 1
+		}
+	}
+	chunk.Text = strings.Join(words, " ")
+	chunk.Pivot += tokens[middle].Pivot
+	chunk.Delay = math.Round(chunk.Delay*100) / 100
+
+	return chunk
+}
+
+// phraseEnd reports whether a chunk may not continue past t.
+func phraseEnd(t Token) bool {
+	return t.ParagraphEnd || lastMark(t.Word) != 0
+}
+
+// isJoiner reports whether t is a function word without punctuation, such
+// as "a", "of", "the" or "и", that reads better with the word after it.
+func isJoiner(t Token) bool {
+	if t.ParagraphEnd {
+		return false
+	}
+	letters := 0
+	for _, r := range t.Word {
+		if !unicode.IsLetter(r) {
+			return false
+		}
+		letters++
+	}
+	return letters <= joinerLetters || joiners[strings.ToLower(t.Word)]
+}
+
+// Ramp eases into the target speed when playback starts or resumes: the
+// first flash runs at From of the target speed, rising evenly to full speed
+// over Steps flashes. The zero Ramp is off.
+type Ramp struct {
+	From  float64 `json:"from"`
+	Steps int     `json:"steps"`
+}
+
+// Delays returns the delay multiplier of each of the first Steps flashes
+// after playback starts. Later flashes run at the target speed.
+func (r Ramp) Delays() []float64 {
+	if r.Steps <= 0 || r.From <= 0 || r.From >= 1 {
+		return []float64{}
+	}
+
+	delays := make([]float64, r.Steps)
+	for i := range delays {
+		speed := r.From + (1-r.From)*float64(i)/float64(r.Steps)
+		delays[i] = math.Round(100/speed) / 100
+	}
+	return delays
+}
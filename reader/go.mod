@@ -0,0 +1,3 @@
+module github.com/st107853/fast_reading/reader
+
+go 1.24.2
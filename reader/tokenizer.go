@@ -0,0 +1,182 @@
+// Package reader prepares chapter text for rapid serial visual presentation
+// (RSVP): one word at a time in a fixed place on screen.
+package reader
+
+import (
+	"math"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Token is a single word of a chapter as the RSVP reader shows it.
+type Token struct {
+	Word string `json:"word"`
+	// Pivot is the rune index inside Word of the optimal recognition point,
+	// the letter the reader aligns to the centre of the word box.
+	Pivot int `json:"pivot"`
+	// Delay multiplies the base display time (60s / WPM) for this word.
+	Delay float64 `json:"delay"`
+	// Offset is the rune offset of Word in the chapter text.
+	Offset int `json:"offset"`
+	// ParagraphEnd is set on the last word before a blank line. A single
+	// line break, as in hard-wrapped text, does not end a paragraph.
+	ParagraphEnd bool `json:"paragraph_end,omitempty"`
+}
+
+// Display-time multipliers. A word's delay is 1 plus every bonus that applies.
+const (
+	longWordLetters   = 8    // words longer than this get a per-letter bonus
+	longWordBonus     = 0.08 // per letter above longWordLetters
+	maxLongWordBonus  = 0.6
+	clauseBonus       = 0.5 // , ; : and dashes
+	sentenceBonus     = 1.0 // . ! ? …
+	paragraphBonus    = 1.5
+	sentenceEndMarks  = ".!?…"
+	clauseBreakMarks  = ",;:—–-"
+	closingQuoteMarks = "\"'»”’)]"
+)
+
+// Pauses scales the clause, sentence and paragraph bonuses of the display
+// delay: 0 drops the pause, 2 doubles it.
+type Pauses struct {
+	Clause    float64
+	Sentence  float64
+	Paragraph float64
+}
+
+// DefaultPauses keeps the bonuses as they are.
+var DefaultPauses = Pauses{Clause: 1, Sentence: 1, Paragraph: 1}
+
+// Tokenize splits text on whitespace and computes the pivot letter, display
+// delay and offset of every word.
+func Tokenize(text string) []Token {
+	return TokenizeWith(text, DefaultPauses)
+}
+
+// TokenizeWith is Tokenize with the pauses scaled by p.
+func TokenizeWith(text string, p Pauses) []Token {
+	var (
+		tokens   []Token
+		start    = -1 // byte offset of the current word
+		runes    int  // runes seen so far
+		begin    int  // rune offset of the current word
+		newlines int  // line breaks since the last word
+	)
+
+	for i, r := range text {
+		if unicode.IsSpace(r) {
+			if start >= 0 {
+				tokens = append(tokens, newToken(text[start:i], begin, p))
+				start = -1
+			}
+			if r == '\n' {
+				newlines++
+			}
+			if newlines >= 2 && len(tokens) > 0 && !tokens[len(tokens)-1].ParagraphEnd {
+				last := &tokens[len(tokens)-1]
+				last.ParagraphEnd = true
+				last.Delay = math.Round((last.Delay+paragraphBonus*p.Paragraph)*100) / 100
+			}
+		} else if start < 0 {
+			start = i
+			begin = runes
+			newlines = 0
+		}
+		runes++
+	}
+	if start >= 0 {
+		tokens = append(tokens, newToken(text[start:], begin, p))
+	}
+
+	return tokens
+}
+
+func newToken(word string, offset int, p Pauses) Token {
+	return Token{
+		Word:   word,
+		Pivot:  Pivot(word),
+		Delay:  delay(word, p),
+		Offset: offset,
+	}
+}
+
+// Pivot returns the rune index of the optimal recognition point: slightly
+// left of the middle of the letters, ignoring surrounding punctuation.
+func Pivot(word string) int {
+	lead, letters := 0, 0
+	seenLetter := false
+	for _, r := range word {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			seenLetter = true
+			letters++
+		} else if !seenLetter {
+			lead++
+		}
+	}
+
+	var pivot int
+	switch {
+	case letters <= 1:
+		pivot = 0
+	case letters <= 5:
+		pivot = 1
+	case letters <= 9:
+		pivot = 2
+	case letters <= 13:
+		pivot = 3
+	default:
+		pivot = 4
+	}
+
+	if !seenLetter {
+		return 0
+	}
+	return lead + pivot
+}
+
+// Delay returns the display-time multiplier of a word without paragraph
+// context: long words and words that end a clause or sentence stay longer.
+func Delay(word string) float64 {
+	return delay(word, DefaultPauses)
+}
+
+func delay(word string, p Pauses) float64 {
+	delay := 1.0
+
+	letters := 0
+	for _, r := range word {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			letters++
+		}
+	}
+	if letters > longWordLetters {
+		delay += min(float64(letters-longWordLetters)*longWordBonus, maxLongWordBonus)
+	}
+
+	switch last := lastMark(word); {
+	case last == 0:
+	case strings.ContainsRune(sentenceEndMarks, last):
+		delay += sentenceBonus * p.Sentence
+	case strings.ContainsRune(clauseBreakMarks, last):
+		delay += clauseBonus * p.Clause
+	}
+
+	return math.Round(delay*100) / 100
+}
+
+// lastMark returns the final punctuation rune of a word, looking through
+// closing quotes and brackets, or 0 if the word ends in a letter.
+func lastMark(word string) rune {
+	for len(word) > 0 {
+		r, size := utf8.DecodeLastRuneInString(word)
+		if !strings.ContainsRune(closingQuoteMarks, r) {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) {
+				return 0
+			}
+			return r
+		}
+		word = word[:len(word)-size]
+	}
+	return 0
+}
@@ -0,0 +1,33 @@
+package reader
+
+import "testing"
+
+func TestTokenizeHardWrappedText(t *testing.T) {
+	// A Gutenberg-style paragraph wrapped at a fixed width, then a blank line
+	text := "It was the best of times, it was\nthe worst of times, it was the age\nof wisdom.\r\n\r\nIt was the age of foolishness."
+
+	tokens := Tokenize(text)
+	var ends []string
+	for _, tok := range tokens {
+		if tok.ParagraphEnd {
+			ends = append(ends, tok.Word)
+		}
+	}
+	if len(ends) != 1 || ends[0] != "wisdom." {
+		t.Errorf("paragraph ends at %q, want only at \"wisdom.\"", ends)
+	}
+
+	was := tokens[7]
+	if was.Word != "was" || was.Delay != Delay("was") {
+		t.Errorf("word before a wrapped line = %+v, want no paragraph pause", was)
+	}
+
+	// Wrapped lines do not force a chunk break either
+	spans := false
+	for _, c := range Chunks(tokens, 5) {
+		spans = spans || c.Text == "it was the worst"
+	}
+	if !spans {
+		t.Errorf("chunks = %+v, want one across the wrapped line", Chunks(tokens, 5))
+	}
+}
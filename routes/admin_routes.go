@@ -0,0 +1,36 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/st107853/fast_reading/config"
+	"github.com/st107853/fast_reading/controllers"
+	"github.com/st107853/fast_reading/middleware"
+	"github.com/st107853/fast_reading/models"
+	"github.com/st107853/fast_reading/services"
+)
+
+type AdminRouteController struct {
+	adminController controllers.AdminController
+}
+
+func NewAdminRouteController(adminController controllers.AdminController) AdminRouteController {
+	return AdminRouteController{adminController}
+}
+
+func (ac *AdminRouteController) AdminRoute(rg *gin.RouterGroup, userService services.UserService, conf config.Config) {
+	router := rg.Group("/admin")
+	router.Use(middleware.DeserializeUser(userService, conf), middleware.RequireRole(models.RoleAdmin))
+
+	router.GET("/users", ac.adminController.ListUsers)
+	router.PUT("/users/:user_id/role", ac.adminController.SetUserRole)
+	router.PUT("/users/:user_id/verified", ac.adminController.SetUserVerified)
+	router.PUT("/users/:user_id/banned", ac.adminController.SetUserBanned)
+
+	router.PUT("/books/:book_id/unrelease", ac.adminController.UnreleaseBook)
+	router.DELETE("/books/:book_id", ac.adminController.DeleteBook)
+
+	router.POST("/labels", ac.adminController.CreateLabel)
+	router.PUT("/labels/:label_id", ac.adminController.RenameLabel)
+	router.POST("/labels/:label_id/merge", ac.adminController.MergeLabel)
+	router.DELETE("/labels/:label_id", ac.adminController.DeleteLabel)
+}
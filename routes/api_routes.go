@@ -0,0 +1,34 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/st107853/fast_reading/config"
+	"github.com/st107853/fast_reading/controllers"
+	"github.com/st107853/fast_reading/middleware"
+	"github.com/st107853/fast_reading/services"
+)
+
+type APIRouteController struct {
+	apiController controllers.APIController
+}
+
+func NewAPIRouteController(apiController controllers.APIController) APIRouteController {
+	return APIRouteController{apiController}
+}
+
+// APIRoute registers the JSON API on a versioned group such as /api/v1.
+func (ac *APIRouteController) APIRoute(rg *gin.RouterGroup, userService services.UserService, conf config.Config) {
+	rg.Use(middleware.APIErrors(), middleware.DeserializeUser(userService, conf))
+
+	rg.GET("/catalog", ac.apiController.Catalog)
+	rg.GET("/books", ac.apiController.SearchBooks)
+	rg.GET("/books/:book_id", ac.apiController.GetBook)
+	rg.GET("/books/:book_id/bundle", ac.apiController.GetBundle)
+	rg.GET("/books/:book_id/chapters/:chapter_id", ac.apiController.GetChapter)
+	rg.GET("/books/:book_id/chapters/:chapter_id/tokens", ac.apiController.ChapterTokens)
+	rg.GET("/search", ac.apiController.Search)
+	rg.GET("/labels", ac.apiController.ListLabels)
+	rg.GET("/continue", ac.apiController.ContinueReading)
+	rg.POST("/progress/sync", ac.apiController.SyncProgress)
+	rg.GET("/users/me", ac.apiController.GetMe)
+}
@@ -2,6 +2,7 @@ package routes
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/st107853/fast_reading/config"
 	"github.com/st107853/fast_reading/controllers"
 	"github.com/st107853/fast_reading/middleware"
 	"github.com/st107853/fast_reading/services"
@@ -15,12 +16,19 @@ func NewAuthRouteController(authController controllers.AuthController) AuthRoute
 	return AuthRouteController{authController}
 }
 
-func (rc *AuthRouteController) AuthRoute(rg *gin.RouterGroup, userService services.UserService) {
+func (rc *AuthRouteController) AuthRoute(rg *gin.RouterGroup, userService services.UserService, conf config.Config) {
 	router := rg.Group("/auth")
 
 	router.POST("/register", rc.authController.SignUpUser)
 	router.POST("/login", rc.authController.SignInUser)
 	router.GET("/refresh", rc.authController.RefreshAccessToken)
-	router.GET("/logout", middleware.DeserializeUser(userService), rc.authController.LogoutUser)
+	router.GET("/logout", middleware.DeserializeUser(userService, conf), rc.authController.LogoutUser)
 	router.GET("/login", rc.authController.LoginPage)
+	router.GET("/verify/:token", rc.authController.VerifyEmail)
+	router.POST("/verify/resend", rc.authController.ResendVerification)
+	router.POST("/forgot-password", rc.authController.ForgotPassword)
+	router.POST("/reset-password", rc.authController.ResetPassword)
+	router.GET("/sessions", middleware.DeserializeUser(userService, conf), rc.authController.ListSessions)
+	router.DELETE("/sessions", middleware.DeserializeUser(userService, conf), rc.authController.RevokeSessions)
+	router.DELETE("/sessions/:session_id", middleware.DeserializeUser(userService, conf), rc.authController.RevokeSessions)
 }
@@ -2,8 +2,10 @@ package routes
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/st107853/fast_reading/config"
 	"github.com/st107853/fast_reading/controllers"
 	"github.com/st107853/fast_reading/middleware"
+	"github.com/st107853/fast_reading/models"
 	"github.com/st107853/fast_reading/services"
 )
 
@@ -15,26 +17,44 @@ func NewBookRouteController(bookController controllers.BookController) BookRoute
 	return BookRouteController{bookController}
 }
 
-func (bc *BookRouteController) BookRoute(rg *gin.RouterGroup, bookService services.BookService, userService services.UserService) {
-	rg.Use(middleware.DeserializeUser(userService))
-	rg.POST("/", bc.bookController.CreateBook)
-	rg.PUT("/:book_id", bc.bookController.UpdateBook)
-	rg.PUT("/:book_id/:chapter_id/:last_index", bc.bookController.BookMark)
-	rg.PUT("/release/:book_id", bc.bookController.ReleaseBook)
-	rg.DELETE("/:book_id", bc.bookController.DeleteBook)
-	rg.DELETE("/", bc.bookController.DeleteAllBooks)
-	rg.DELETE("/chapter/:chapter_id", bc.bookController.DeleteChapter)
+func (bc *BookRouteController) BookRoute(rg *gin.RouterGroup, bookService services.BookService, userService services.UserService, conf config.Config) {
+	rg.Use(middleware.DeserializeUser(userService, conf))
+
+	auth := middleware.RequireAuth()
+	bookOwner := middleware.RequireBookOwner(bookService)
+	chapterOwner := middleware.RequireChapterOwner(bookService)
+
+	rg.POST("/", auth, bc.bookController.CreateBook)
+	rg.POST("/import/epub", auth, bc.bookController.ImportEpub)
+	rg.POST("/import/text", auth, bc.bookController.ImportText)
+	rg.PUT("/:book_id", bookOwner, bc.bookController.UpdateBook)
+	rg.PUT("/:book_id/:chapter_id/:last_index", auth, bc.bookController.BookMark)
+	rg.PUT("/release/:book_id", middleware.RequireVerified(), bookOwner, bc.bookController.ReleaseBook)
+	rg.DELETE("/:book_id", bookOwner, bc.bookController.DeleteBook)
+	rg.DELETE("/", middleware.RequireRole(models.RoleAdmin), bc.bookController.DeleteAllBooks)
+	rg.DELETE("/chapter/:chapter_id", chapterOwner, bc.bookController.DeleteChapter)
 	rg.GET("/", bc.bookController.AllBooks)
 	rg.GET("/continue", bc.bookController.ContinueReading)
 	rg.GET("/book/:book_id", bc.bookController.GetBook)
+	rg.GET("/book/:book_id/export.epub", bc.bookController.ExportEpub)
 	rg.GET("/book/:book_id/:chapter_id/:last_index", bc.bookController.GetChapter)
-	rg.POST("/book/:book_id/favourite", bc.bookController.BookFavourite)
-	rg.GET("/addbook", bc.bookController.AddBook)
-	rg.GET("/addbook/:book_id", bc.bookController.EditBook)
-	rg.GET("/addbook/:book_id/chapter", bc.bookController.AddBookChapter)
-	rg.POST("/addbook/:book_id/chapter", bc.bookController.CreateChapter)
-	rg.GET("/addbook/:book_id/chapter/:chapter_id", bc.bookController.EditBookChapter)
-	rg.PUT("/addbook/:book_id/chapter/:chapter_id", bc.bookController.UpdateBookChapter)
-	rg.PUT("/book/:book_id/labels", bc.bookController.AddLabel)
+	rg.GET("/book/:book_id/:chapter_id/tokens", bc.bookController.ChapterTokens)
+	rg.POST("/book/:book_id/favourite", auth, bc.bookController.BookFavourite)
+	rg.GET("/addbook", auth, bc.bookController.AddBook)
+	rg.GET("/addbook/:book_id", bookOwner, bc.bookController.EditBook)
+	rg.GET("/addbook/:book_id/chapter", bookOwner, bc.bookController.AddBookChapter)
+	rg.POST("/addbook/:book_id/chapter", bookOwner, bc.bookController.CreateChapter)
+	rg.GET("/addbook/:book_id/chapter/draft", bookOwner, bc.bookController.ChapterDraft)
+	rg.PUT("/addbook/:book_id/chapter/draft", bookOwner, bc.bookController.SaveChapterDraft)
+	rg.DELETE("/addbook/:book_id/chapter/draft", bookOwner, bc.bookController.DiscardChapterDraft)
+	rg.GET("/addbook/:book_id/chapter/:chapter_id", chapterOwner, bc.bookController.EditBookChapter)
+	rg.PUT("/addbook/:book_id/chapter/:chapter_id", chapterOwner, bc.bookController.UpdateBookChapter)
+	rg.GET("/addbook/:book_id/chapter/:chapter_id/revisions", chapterOwner, bc.bookController.ChapterRevisions)
+	rg.GET("/addbook/:book_id/chapter/:chapter_id/revisions/diff", chapterOwner, bc.bookController.ChapterRevisionDiff)
+	rg.POST("/addbook/:book_id/chapter/:chapter_id/revisions/:revision_id/restore", chapterOwner, bc.bookController.RestoreChapterRevision)
+	rg.GET("/addbook/:book_id/chapter/:chapter_id/draft", chapterOwner, bc.bookController.ChapterDraft)
+	rg.PUT("/addbook/:book_id/chapter/:chapter_id/draft", chapterOwner, bc.bookController.SaveChapterDraft)
+	rg.DELETE("/addbook/:book_id/chapter/:chapter_id/draft", chapterOwner, bc.bookController.DiscardChapterDraft)
+	rg.PUT("/book/:book_id/labels", bookOwner, bc.bookController.AddLabel)
 	rg.GET("/filter/", bc.bookController.ListAllBooks)
 }
@@ -0,0 +1,19 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/st107853/fast_reading/controllers"
+)
+
+type CoverRouteController struct {
+	coverController controllers.CoverController
+}
+
+func NewCoverRouteController(coverController controllers.CoverController) CoverRouteController {
+	return CoverRouteController{coverController}
+}
+
+// CoverRoute serves covers under /covers/, where templates link them.
+func (cc *CoverRouteController) CoverRoute(rg *gin.RouterGroup) {
+	rg.GET("/covers/*name", cc.coverController.GetCover)
+}
@@ -0,0 +1,267 @@
+package routes
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/st107853/fast_reading/controllers"
+	"github.com/st107853/fast_reading/models"
+	"github.com/st107853/fast_reading/openapi"
+)
+
+// Docs describes every route registered by the route controllers. Add an
+// entry here when adding a route; the route test fails otherwise.
+var Docs = []openapi.Route{
+	// Auth
+	{Method: "POST", Path: "/library/auth/register", Tag: "auth", Summary: "Create an unverified account and email a verification link",
+		JSON: models.SignUpInput{}, Status: http.StatusCreated, Response: envelope("user", models.UserResponse{})},
+	{Method: "POST", Path: "/library/auth/login", Tag: "auth", Summary: "Log in and set the access and refresh token cookies",
+		JSON: models.SignInInput{}, Response: struct {
+			Status      string `json:"status"`
+			AccessToken string `json:"access_token"`
+		}{}},
+	{Method: "GET", Path: "/library/auth/login", Tag: "auth", Summary: "Login and sign up page", ContentType: "text/html"},
+	{Method: "GET", Path: "/library/auth/refresh", Tag: "auth", Summary: "Rotate the refresh token cookie and issue a new access token"},
+	{Method: "GET", Path: "/library/auth/logout", Tag: "auth", Summary: "Log out of the current device"},
+	{Method: "GET", Path: "/library/auth/verify/:token", Tag: "auth", Summary: "Confirm an email address"},
+	{Method: "POST", Path: "/library/auth/verify/resend", Tag: "auth", Summary: "Email a new verification link",
+		JSON: models.ForgotPasswordInput{}},
+	{Method: "POST", Path: "/library/auth/forgot-password", Tag: "auth", Summary: "Email a password reset link",
+		JSON: models.ForgotPasswordInput{}},
+	{Method: "POST", Path: "/library/auth/reset-password", Tag: "auth", Summary: "Set a new password and log out everywhere",
+		JSON: models.ResetPasswordInput{}},
+	{Method: "GET", Path: "/library/auth/sessions", Tag: "auth", Summary: "List the devices the user is logged in on", Auth: true,
+		Response: envelope("sessions", []models.Session{})},
+	{Method: "DELETE", Path: "/library/auth/sessions", Tag: "auth", Summary: "Log out of every other device", Auth: true},
+	{Method: "DELETE", Path: "/library/auth/sessions/:session_id", Tag: "auth", Summary: "Log out of one device", Auth: true},
+
+	// Users
+	{Method: "GET", Path: "/library/users/me", Tag: "users", Summary: "Profile page with created and favourite books", Auth: true, ContentType: "text/html"},
+	{Method: "POST", Path: "/library/users/me/reading-sessions", Tag: "users", Summary: "Record a reading session of the RSVP reader", Auth: true,
+		JSON: models.ReadingSessionInput{}, Status: http.StatusCreated, Response: envelopeData(models.ReadingSession{})},
+	{Method: "GET", Path: "/library/users/me/stats", Tag: "users", Summary: "Reading statistics: daily words and speed, streaks, time to finish", Auth: true,
+		Query: []openapi.Param{
+			{Name: "days", Type: "integer", Description: "Length of the period, 30 by default and at most 365"},
+			{Name: "tz", Description: "IANA time zone the days are counted in, UTC by default"},
+		},
+		Response: envelopeData(models.ReadingStats{})},
+	{Method: "GET", Path: "/library/users/me/preferences", Tag: "users", Summary: "Reader settings: speed, words per flash, font, theme, pauses", Auth: true,
+		Response: envelopeData(models.UserPreferences{})},
+	{Method: "PUT", Path: "/library/users/me/preferences", Tag: "users", Summary: "Change some of the reader settings", Auth: true,
+		JSON: models.PreferencesInput{}, Response: envelopeData(models.UserPreferences{})},
+
+	// Books
+	{Method: "GET", Path: "/library/", Tag: "books", Summary: "Main page", ContentType: "text/html"},
+	{Method: "GET", Path: "/library/continue", Tag: "books", Summary: "Books the user has started", ContentType: "text/html"},
+	{Method: "GET", Path: "/library/filter/", Tag: "books", Summary: "Search books, one page at a time",
+		Query:    bookFilterQuery,
+		Response: models.BookPage{}},
+	{Method: "POST", Path: "/library/", Tag: "books", Summary: "Create a book", Auth: true,
+		Form: models.Book{}, Files: []string{"cover_image"}, Status: http.StatusCreated, Response: createdBook{}},
+	{Method: "POST", Path: "/library/import/epub", Tag: "books", Summary: "Import a book with its chapters from an .epub file", Auth: true,
+		Files: []string{"file"}, Status: http.StatusCreated, Response: createdBook{}},
+	{Method: "POST", Path: "/library/import/text", Tag: "books", Summary: "Import a book from a .txt or .md file, split into chapters", Auth: true,
+		Form: textImportForm{}, Files: []string{"file"}, Status: http.StatusCreated, Response: createdBook{}},
+	{Method: "PUT", Path: "/library/:book_id", Tag: "books", Summary: "Update a book", Auth: true,
+		Form: models.Book{}, Files: []string{"cover_image"}, Response: models.Book{}},
+	{Method: "PUT", Path: "/library/release/:book_id", Tag: "books", Summary: "Release or hide a book (verified users only)", Auth: true},
+	{Method: "DELETE", Path: "/library/:book_id", Tag: "books", Summary: "Delete a book", Auth: true},
+	{Method: "DELETE", Path: "/library/", Tag: "books", Summary: "Delete every book (admins only)", Auth: true},
+	{Method: "GET", Path: "/library/book/:book_id", Tag: "books", Summary: "Book page", ContentType: "text/html"},
+	{Method: "GET", Path: "/library/book/:book_id/export.epub", Tag: "books", Summary: "Download a book as .epub", ContentType: "application/epub+zip"},
+	{Method: "POST", Path: "/library/book/:book_id/favourite", Tag: "books", Summary: "Add a book to or remove it from favourites", Auth: true,
+		Status: http.StatusCreated},
+	{Method: "PUT", Path: "/library/book/:book_id/labels", Tag: "books", Summary: "Replace the labels of a book", Auth: true,
+		JSON: controllers.UpdateLabelsRequest{}},
+	{Method: "PUT", Path: "/library/:book_id/:chapter_id/:last_index", Tag: "books", Summary: "Save the reading position", Auth: true},
+	{Method: "GET", Path: "/library/addbook", Tag: "editor", Summary: "New book page", Auth: true, ContentType: "text/html"},
+	{Method: "GET", Path: "/library/addbook/:book_id", Tag: "editor", Summary: "Book editor page", Auth: true, ContentType: "text/html"},
+
+	// Chapters
+	{Method: "GET", Path: "/library/book/:book_id/:chapter_id/:last_index", Tag: "chapters", Summary: "Reader page for a chapter", ContentType: "text/html",
+		Query: readerQuery},
+	{Method: "GET", Path: "/library/book/:book_id/:chapter_id/tokens", Tag: "chapters", Summary: "Chapter split into RSVP tokens and flashes",
+		Query: readerQuery, Response: controllers.ReaderPlan{}},
+	{Method: "GET", Path: "/library/addbook/:book_id/chapter", Tag: "editor", Summary: "New chapter page", Auth: true, ContentType: "text/html"},
+	{Method: "POST", Path: "/library/addbook/:book_id/chapter", Tag: "chapters", Summary: "Add a chapter to a book", Auth: true,
+		JSON: models.Chapter{}, Status: http.StatusCreated},
+	{Method: "GET", Path: "/library/addbook/:book_id/chapter/:chapter_id", Tag: "editor", Summary: "Chapter editor page", Auth: true, ContentType: "text/html"},
+	{Method: "PUT", Path: "/library/addbook/:book_id/chapter/:chapter_id", Tag: "chapters", Summary: "Update a chapter", Auth: true,
+		JSON: models.Chapter{}, Response: models.Chapter{}},
+	{Method: "GET", Path: "/library/addbook/:book_id/chapter/:chapter_id/revisions", Tag: "chapters", Summary: "Revisions of a chapter, newest first", Auth: true,
+		Response: envelope("revisions", []models.RevisionSummary{})},
+	{Method: "GET", Path: "/library/addbook/:book_id/chapter/:chapter_id/revisions/diff", Tag: "chapters", Summary: "Word diff between two revisions", Auth: true,
+		Query: []openapi.Param{
+			{Name: "from", Type: "integer", Required: true, Description: "ID of the older revision"},
+			{Name: "to", Type: "integer", Required: true, Description: "ID of the newer revision"},
+		},
+		Response: models.RevisionDiff{}},
+	{Method: "POST", Path: "/library/addbook/:book_id/chapter/:chapter_id/revisions/:revision_id/restore", Tag: "chapters", Summary: "Restore a revision of a chapter", Auth: true,
+		Response: models.Chapter{}},
+	{Method: "GET", Path: "/library/addbook/:book_id/chapter/draft", Tag: "chapters", Summary: "Draft of the new chapter of a book", Auth: true,
+		Response: models.ChapterDraft{}},
+	{Method: "PUT", Path: "/library/addbook/:book_id/chapter/draft", Tag: "chapters", Summary: "Autosave the new chapter of a book", Auth: true,
+		JSON: models.DraftInput{}, Response: models.ChapterDraft{}},
+	{Method: "DELETE", Path: "/library/addbook/:book_id/chapter/draft", Tag: "chapters", Summary: "Discard the draft of the new chapter", Auth: true},
+	{Method: "GET", Path: "/library/addbook/:book_id/chapter/:chapter_id/draft", Tag: "chapters", Summary: "Draft of a chapter", Auth: true,
+		Response: models.ChapterDraft{}},
+	{Method: "PUT", Path: "/library/addbook/:book_id/chapter/:chapter_id/draft", Tag: "chapters", Summary: "Autosave a chapter", Auth: true,
+		JSON: models.DraftInput{}, Response: models.ChapterDraft{}},
+	{Method: "DELETE", Path: "/library/addbook/:book_id/chapter/:chapter_id/draft", Tag: "chapters", Summary: "Discard the draft of a chapter", Auth: true},
+	{Method: "DELETE", Path: "/library/chapter/:chapter_id", Tag: "chapters", Summary: "Delete a chapter", Auth: true},
+
+	// Admin
+	{Method: "GET", Path: "/library/admin/users", Tag: "admin", Summary: "List and search users", Auth: true,
+		Query: []openapi.Param{
+			{Name: "q", Description: "Substring of the name or email"},
+			{Name: "page", Type: "integer"},
+			{Name: "per_page", Type: "integer"},
+		},
+		Response: envelopeData(models.UserPage{})},
+	{Method: "PUT", Path: "/library/admin/users/:user_id/role", Tag: "admin", Summary: "Change the role of a user", Auth: true,
+		JSON: struct {
+			Role string `json:"role" binding:"required"`
+		}{}},
+	{Method: "PUT", Path: "/library/admin/users/:user_id/verified", Tag: "admin", Summary: "Mark a user verified or not", Auth: true,
+		JSON: struct {
+			Verified bool `json:"verified" binding:"required"`
+		}{}},
+	{Method: "PUT", Path: "/library/admin/users/:user_id/banned", Tag: "admin", Summary: "Suspend or restore an account", Auth: true,
+		JSON: struct {
+			Banned bool `json:"banned" binding:"required"`
+		}{}},
+	{Method: "PUT", Path: "/library/admin/books/:book_id/unrelease", Tag: "admin", Summary: "Hide any book from the catalogue", Auth: true},
+	{Method: "DELETE", Path: "/library/admin/books/:book_id", Tag: "admin", Summary: "Delete any book", Auth: true},
+	{Method: "POST", Path: "/library/admin/labels", Tag: "admin", Summary: "Create a label", Auth: true,
+		JSON: models.LabelInput{}, Status: http.StatusCreated, Response: envelope("label", models.Label{})},
+	{Method: "PUT", Path: "/library/admin/labels/:label_id", Tag: "admin", Summary: "Rename a label", Auth: true,
+		JSON: models.LabelInput{}, Response: envelope("label", models.Label{})},
+	{Method: "POST", Path: "/library/admin/labels/:label_id/merge", Tag: "admin", Summary: "Move the books of a label to another label and delete it", Auth: true,
+		JSON: struct {
+			Into uint `json:"into" binding:"required"`
+		}{}},
+	{Method: "DELETE", Path: "/library/admin/labels/:label_id", Tag: "admin", Summary: "Delete a label", Auth: true},
+
+	// Files
+	{Method: "GET", Path: "/covers/*name", Tag: "books", Summary: "Cover image, or a redirect to its public URL", ContentType: "image/*"},
+
+	// JSON API
+	{Method: "GET", Path: "/api/openapi.json", Tag: "api", Summary: "This document"},
+	{Method: "GET", Path: "/api/v1/catalog", Tag: "api", Summary: "Released books, labels and latest releases",
+		Response: envelope("books", []models.BookBase{}, "labels", []models.Label{}, "last_released", []models.Book{})},
+	{Method: "GET", Path: "/api/v1/books", Tag: "api", Summary: "Search books, one page at a time",
+		Query:    bookFilterQuery,
+		Response: envelopeData(models.BookPage{})},
+	{Method: "GET", Path: "/api/v1/books/:book_id", Tag: "api", Summary: "Book with labels, chapters and reading progress",
+		Response: envelope("book", models.BookDetail{})},
+	{Method: "GET", Path: "/api/v1/books/:book_id/bundle", Tag: "api", Summary: "Book with every chapter, its RSVP plan and the reading progress, for offline reading",
+		Query: readerQuery, Response: envelopeData(controllers.BookBundle{})},
+	{Method: "GET", Path: "/api/v1/books/:book_id/chapters/:chapter_id", Tag: "api", Summary: "Chapter text",
+		Response: envelope("book", models.BookBase{}, "chapter", models.Chapter{})},
+	{Method: "GET", Path: "/api/v1/books/:book_id/chapters/:chapter_id/tokens", Tag: "api", Summary: "Chapter split into RSVP tokens and flashes",
+		Query: readerQuery, Response: envelopeData(controllers.ReaderPlan{})},
+	{Method: "GET", Path: "/api/v1/search", Tag: "api", Summary: "Full-text search with highlighted chapter snippets",
+		Query: []openapi.Param{
+			{Name: "q", Required: true, Description: `Words, "quoted phrases", -excluded words and OR`},
+			{Name: "page", Type: "integer"},
+			{Name: "per_page", Type: "integer", Description: "20 by default and at most 50"},
+		},
+		Response: envelopeData(models.SearchPage{})},
+	{Method: "GET", Path: "/api/v1/labels", Tag: "api", Summary: "All labels",
+		Response: envelope("labels", []models.Label{})},
+	{Method: "GET", Path: "/api/v1/continue", Tag: "api", Summary: "Books the user has started, one page at a time", Auth: true,
+		Query:    bookFilterQuery[3:],
+		Response: envelopeData(models.BookPage{})},
+	{Method: "POST", Path: "/api/v1/progress/sync", Tag: "api", Summary: "Merge bookmarks saved offline; the latest one of each book wins", Auth: true,
+		JSON: models.SyncInput{}, Response: envelopeData(models.SyncResult{})},
+	{Method: "GET", Path: "/api/v1/users/me", Tag: "api", Summary: "Profile with created and favourite books", Auth: true,
+		Response: envelope("user", models.UserResponse{}, "verified", false,
+			"created_books", []models.BookBase{}, "created_labels", []models.Label{},
+			"favourite_books", []models.BookBase{}, "favourite_labels", []models.Label{})},
+}
+
+// Models documented in the components even where no route embeds them.
+var docModels = []interface{}{
+	models.Book{}, models.Chapter{}, models.Label{}, models.ReadingProgress{},
+	models.SignUpInput{}, models.SignInInput{},
+}
+
+var bookFilterQuery = []openapi.Param{
+	{Name: "keyword", Description: "Substring of the book name"},
+	{Name: "labels", Description: "Comma separated label IDs; books must have all of them"},
+	{Name: "code", Description: "0 released, 1 started, 2 created, 3 favourite"},
+	{Name: "sort", Description: "name, author, release_date, publication_year or popularity"},
+	{Name: "order", Description: "asc or desc; each sort has its own default"},
+	{Name: "cursor", Description: "next_cursor of the previous page"},
+	{Name: "limit", Type: "integer", Description: "Page size, 20 by default and at most 100"},
+}
+
+var readerQuery = []openapi.Param{
+	{Name: "chunk", Type: "integer", Description: "Words per flash, 1 to 5; the user's setting by default"},
+	{Name: "ramp", Type: "integer", Description: "Flashes it takes to reach the target speed after starting or resuming, 0 (default) for none"},
+	{Name: "ramp_from", Type: "number", Description: "Starting speed as a fraction of the target, 0.5 by default"},
+}
+
+type createdBook struct {
+	Message string `json:"message"`
+	BookID  uint   `json:"book_id"`
+}
+
+type textImportForm struct {
+	models.Book
+	Split        string `form:"split"`
+	HeadingLevel int    `form:"heading_level"`
+	Words        int    `form:"words"`
+}
+
+var (
+	specOnce sync.Once
+	spec     *openapi.Document
+)
+
+// OpenAPISpec returns the OpenAPI document of all routes in Docs.
+func OpenAPISpec() *openapi.Document {
+	specOnce.Do(func() {
+		spec = openapi.Build(openapi.Info{
+			Title:       "Fast Reading API",
+			Version:     "1.0.0",
+			Description: "HTML pages and JSON endpoints of the Fast Reading library.",
+		}, Docs, docModels...)
+	})
+	return spec
+}
+
+// OpenAPIRoute serves the document at /openapi.json of the group.
+func OpenAPIRoute(rg *gin.RouterGroup) {
+	rg.GET("/openapi.json", func(c *gin.Context) {
+		c.JSON(http.StatusOK, OpenAPISpec())
+	})
+}
+
+// envelope documents a {"status": "success", "data": {...}} response whose
+// data holds the given key, example value pairs.
+func envelope(pairs ...interface{}) interface{} {
+	var fields []reflect.StructField
+	for i := 0; i+1 < len(pairs); i += 2 {
+		key := pairs[i].(string)
+		fields = append(fields, reflect.StructField{
+			Name: "F" + strings.NewReplacer("_", "").Replace(key),
+			Type: reflect.TypeOf(pairs[i+1]),
+			Tag:  reflect.StructTag(`json:"` + key + `"`),
+		})
+	}
+
+	return envelopeData(reflect.New(reflect.StructOf(fields)).Elem().Interface())
+}
+
+// envelopeData documents a success envelope whose data is the value itself.
+func envelopeData(data interface{}) interface{} {
+	t := reflect.StructOf([]reflect.StructField{
+		{Name: "Status", Type: reflect.TypeOf(""), Tag: `json:"status"`},
+		{Name: "Data", Type: reflect.TypeOf(data), Tag: `json:"data"`},
+	})
+	return reflect.New(t).Elem().Interface()
+}
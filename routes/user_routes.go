@@ -2,6 +2,7 @@ package routes
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/st107853/fast_reading/config"
 	"github.com/st107853/fast_reading/controllers"
 	"github.com/st107853/fast_reading/middleware"
 	"github.com/st107853/fast_reading/services"
@@ -15,9 +16,13 @@ func NewRouteUserController(userController controllers.UserController) UserRoute
 	return UserRouteController{userController}
 }
 
-func (uc *UserRouteController) UserRoute(rg *gin.RouterGroup, userService services.UserService) {
+func (uc *UserRouteController) UserRoute(rg *gin.RouterGroup, userService services.UserService, conf config.Config) {
 
 	router := rg.Group("users")
-	router.Use(middleware.DeserializeUser(userService))
+	router.Use(middleware.DeserializeUser(userService, conf))
 	router.GET("/me", uc.userController.GetMe)
+	router.POST("/me/reading-sessions", middleware.RequireAuth(), uc.userController.RecordSession)
+	router.GET("/me/stats", middleware.RequireAuth(), uc.userController.GetStats)
+	router.GET("/me/preferences", middleware.RequireAuth(), uc.userController.GetPreferences)
+	router.PUT("/me/preferences", middleware.RequireAuth(), uc.userController.UpdatePreferences)
 }
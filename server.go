@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+	"github.com/st107853/fast_reading/config"
+	"github.com/st107853/fast_reading/controllers"
+	"github.com/st107853/fast_reading/mailer"
+	"github.com/st107853/fast_reading/routes"
+	"github.com/st107853/fast_reading/services"
+	"github.com/st107853/fast_reading/storage"
+	"gorm.io/gorm"
+)
+
+// newServer wires services, controllers and routes on top of a database
+// connection and returns the engine with every route registered.
+func newServer(gdb *gorm.DB, conf config.Config, mail mailer.Mailer, blobs storage.BlobStore) *gin.Engine {
+	ctx := context.TODO()
+
+	// Wire services with GORM-backed implementations
+	userService := services.NewUserServiceImpl(gdb, ctx)
+	authService := services.NewAuthService(gdb, ctx, conf, mail)
+	bookService := services.NewBookService(gdb, ctx, blobs)
+
+	// Create controllers and route controllers
+	AuthController := controllers.NewAuthController(authService, userService, conf)
+	AuthRouteController := routes.NewAuthRouteController(AuthController)
+
+	UserController := controllers.NewUserController(userService, bookService)
+	UserRouteController := routes.NewRouteUserController(UserController)
+
+	BookController := controllers.NewBookController(bookService, userService)
+	BookRouteController := routes.NewBookRouteController(BookController)
+
+	AdminController := controllers.NewAdminController(userService, bookService)
+	AdminRouteController := routes.NewAdminRouteController(AdminController)
+
+	APIController := controllers.NewAPIController(bookService, userService)
+	APIRouteController := routes.NewAPIRouteController(APIController)
+
+	CoverController := controllers.NewCoverController(blobs)
+	CoverRouteController := routes.NewCoverRouteController(CoverController)
+
+	server := gin.New()
+	server.Use(gin.Logger())   // Add Logger middleware explicitly
+	server.Use(gin.Recovery()) // Add Recovery middleware explicitly
+
+	server.Static("/static", "./static")
+	CoverRouteController.CoverRoute(&server.RouterGroup)
+
+	corsConfig := cors.DefaultConfig()
+	corsConfig.AllowOrigins = []string{"http://localhost:8080", "http://localhost:3000"}
+	corsConfig.AllowCredentials = true
+
+	server.Use(cors.New(corsConfig))
+
+	router := server.Group("/library")
+
+	AuthRouteController.AuthRoute(router, userService, conf)
+	UserRouteController.UserRoute(router, userService, conf)
+	AdminRouteController.AdminRoute(router, userService, conf)
+	BookRouteController.BookRoute(router, bookService, userService, conf)
+
+	api := server.Group("/api")
+	routes.OpenAPIRoute(api)
+	APIRouteController.APIRoute(api.Group("/v1"), userService, conf)
+
+	return server
+}
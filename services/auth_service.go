@@ -1,8 +1,36 @@
 package services
 
-import "github.com/st107853/fast_reading/models"
+import (
+	"errors"
+
+	"github.com/st107853/fast_reading/models"
+)
+
+// Errors returned by AuthService.
+var (
+	ErrUserNotFound    = errors.New("user not found")
+	ErrInvalidPassword = errors.New("invalid password")
+	// ErrUserNotVerified is also returned by BookService.ReleaseBook.
+	ErrUserNotVerified = errors.New("account is not verified")
+	ErrUserBanned      = errors.New("account is suspended")
+	ErrInvalidToken    = errors.New("invalid or expired refresh token")
+	ErrTokenReused     = errors.New("refresh token was already used; all sessions of this device were revoked")
+	ErrSessionNotFound = errors.New("session not found")
+	// ErrInvalidUserToken is returned for unknown, expired or already used
+	// verification and password reset tokens.
+	ErrInvalidUserToken = errors.New("invalid, expired or already used token")
+)
 
 type AuthService interface {
 	SignUpUser(*models.SignUpInput) (*models.DBResponse, error)
-	SignInUser(*models.SignInInput) (*models.DBResponse, error)
+	SignInUser(*models.SignInInput) (*models.SignInResponse, error)
+	RefreshTokens(refreshToken, device string) (*models.SignInResponse, error)
+	LogoutUser(refreshToken string) error
+	ListSessions(userID uint, familyID string) ([]models.Session, error)
+	RevokeSession(userID, sessionID uint) error
+	RevokeOtherSessions(userID uint, familyID string) error
+	VerifyEmail(token string) error
+	ResendVerification(email string) error
+	ForgotPassword(email string) error
+	ResetPassword(*models.ResetPasswordInput) error
 }
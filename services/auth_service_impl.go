@@ -2,10 +2,14 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log"
 	"strings"
 	"time"
 
+	"github.com/st107853/fast_reading/config"
+	"github.com/st107853/fast_reading/mailer"
 	"github.com/st107853/fast_reading/models"
 	"github.com/st107853/fast_reading/utils"
 	"gorm.io/gorm"
@@ -15,21 +19,29 @@ import (
 type AuthServiceImpl struct {
 	collection *gorm.DB
 	ctx        context.Context
+	config     config.Config
+	mailer     mailer.Mailer
 }
 
 // NewAuthService creates a new instance of AuthServiceImpl.
-func NewAuthService(collection *gorm.DB, ctx context.Context) AuthService {
-	return &AuthServiceImpl{collection, ctx}
+func NewAuthService(collection *gorm.DB, ctx context.Context, config config.Config, mailer mailer.Mailer) AuthService {
+	return &AuthServiceImpl{collection, ctx, config, mailer}
 }
 
-// SignUpUser registers a new user in the database.
-// It hashes the user's password, sets default values, and ensures the email is unique.
+const (
+	defaultVerificationTokenTTL = 48 * time.Hour
+	defaultPasswordResetTTL     = 30 * time.Minute
+)
+
+// SignUpUser registers a new, unverified user in the database.
+// It hashes the user's password, sets default values, ensures the email is unique
+// and emails a verification link.
 func (uc *AuthServiceImpl) SignUpUser(user *models.SignUpInput) (*models.DBResponse, error) {
 	// Normalize and prepare data
 	user.Email = strings.ToLower(user.Email)
 	user.PasswordConfirm = ""
-	user.Verified = true
-	user.Role = "user"
+	user.Verified = false
+	user.Role = models.RoleUser
 	user.CreatedAt = time.Now()
 	user.UpdatedAt = user.CreatedAt
 
@@ -66,8 +78,22 @@ func (uc *AuthServiceImpl) SignUpUser(user *models.SignUpInput) (*models.DBRespo
 		Verified: user.Verified,
 	}
 
-	if err := uc.collection.WithContext(uc.ctx).Create(&newUser).Error; err != nil {
-		return nil, fmt.Errorf("failed to create user: %w", err)
+	var token string
+	err = uc.collection.WithContext(uc.ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&newUser).Error; err != nil {
+			return fmt.Errorf("failed to create user: %w", err)
+		}
+
+		token, err = uc.createUserToken(tx, newUser.ID, models.TokenPurposeVerify, uc.verificationTTL())
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// The account exists even if the mail cannot be sent; the user can ask to resend it
+	if err := uc.sendVerificationEmail(&newUser, token); err != nil {
+		log.Printf("auth: %v", err)
 	}
 
 	// Prepare response (no password)
@@ -82,8 +108,465 @@ func (uc *AuthServiceImpl) SignUpUser(user *models.SignUpInput) (*models.DBRespo
 	return dbResponse, nil
 }
 
-// SignInUser authenticates a user based on their credentials.
-// (Currently not implemented.)
-func (uc *AuthServiceImpl) SignInUser(*models.SignInInput) (*models.DBResponse, error) {
-	return nil, nil
+// SignInUser authenticates a user by email and password and issues a new
+// access and refresh token pair.
+func (uc *AuthServiceImpl) SignInUser(credentials *models.SignInInput) (*models.SignInResponse, error) {
+	var user models.User
+
+	err := uc.collection.WithContext(uc.ctx).
+		Where("email = ?", strings.ToLower(credentials.Email)).
+		First(&user).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("failed to find user: %w", err)
+	}
+
+	if err := utils.VerifyPassword(user.Password, credentials.Password); err != nil {
+		return nil, ErrInvalidPassword
+	}
+
+	if user.BannedAt != nil {
+		return nil, ErrUserBanned
+	}
+
+	// Drop sessions that can no longer be refreshed
+	uc.collection.WithContext(uc.ctx).
+		Where("user_id = ? AND expires_at < ?", user.ID, time.Now()).
+		Delete(&models.Session{})
+
+	familyID, err := utils.NewTokenID()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	session := models.Session{
+		UserID:     user.ID,
+		FamilyID:   familyID,
+		Device:     credentials.Device,
+		CreatedAt:  now,
+		LastUsedAt: now,
+	}
+
+	return uc.issueTokens(uc.collection.WithContext(uc.ctx), &user, &session)
+}
+
+// RefreshTokens rotates a refresh token: the presented session is revoked and
+// a new one is created in the same family. Presenting an already rotated
+// token revokes the whole family, since either the client or an attacker
+// holds a stolen copy.
+func (uc *AuthServiceImpl) RefreshTokens(refreshToken, device string) (*models.SignInResponse, error) {
+	tokenID, err := uc.refreshTokenID(refreshToken)
+	if err != nil {
+		return nil, err
+	}
+
+	var result *models.SignInResponse
+	var reused bool
+
+	err = uc.collection.WithContext(uc.ctx).Transaction(func(tx *gorm.DB) error {
+		var session models.Session
+		if err := tx.Where("token_id = ?", tokenID).First(&session).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvalidToken
+			}
+			return fmt.Errorf("failed to find session: %w", err)
+		}
+
+		now := time.Now()
+
+		// Revoke only if still active so concurrent refreshes cannot both win
+		revoke := tx.Model(&models.Session{}).
+			Where("id = ? AND revoked_at IS NULL", session.ID).
+			Update("revoked_at", now)
+		if revoke.Error != nil {
+			return fmt.Errorf("failed to revoke session: %w", revoke.Error)
+		}
+		if revoke.RowsAffected == 0 {
+			reused = true
+			return nil
+		}
+
+		var user models.User
+		if err := tx.First(&user, session.UserID).Error; err != nil {
+			return ErrUserNotFound
+		}
+		if user.BannedAt != nil {
+			return ErrUserBanned
+		}
+
+		next := models.Session{
+			UserID:     session.UserID,
+			FamilyID:   session.FamilyID,
+			Device:     session.Device,
+			CreatedAt:  session.CreatedAt,
+			LastUsedAt: now,
+		}
+		if device != "" {
+			next.Device = device
+		}
+
+		result, err = uc.issueTokens(tx, &user, &next)
+		return err
+	})
+
+	if reused {
+		if err := uc.revokeFamily(tokenID); err != nil {
+			return nil, err
+		}
+		return nil, ErrTokenReused
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// LogoutUser revokes the device session the refresh token belongs to.
+func (uc *AuthServiceImpl) LogoutUser(refreshToken string) error {
+	tokenID, err := uc.refreshTokenID(refreshToken)
+	if err != nil {
+		return err
+	}
+
+	return uc.revokeFamily(tokenID)
+}
+
+// ListSessions returns the user's active device sessions, newest first. The
+// session of familyID, the device making the request, is marked as current.
+func (uc *AuthServiceImpl) ListSessions(userID uint, familyID string) ([]models.Session, error) {
+	var sessions []models.Session
+
+	err := uc.collection.WithContext(uc.ctx).
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_used_at DESC").
+		Find(&sessions).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+
+	for i := range sessions {
+		sessions[i].Current = sessions[i].FamilyID == familyID
+	}
+
+	return sessions, nil
+}
+
+// RevokeSession logs out the device the session belongs to.
+func (uc *AuthServiceImpl) RevokeSession(userID, sessionID uint) error {
+	var session models.Session
+
+	err := uc.collection.WithContext(uc.ctx).
+		Where("id = ? AND user_id = ?", sessionID, userID).
+		First(&session).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrSessionNotFound
+		}
+		return fmt.Errorf("failed to find session: %w", err)
+	}
+
+	return uc.revokeFamily(session.TokenID)
+}
+
+// RevokeOtherSessions logs out every device of the user except the one of
+// familyID.
+func (uc *AuthServiceImpl) RevokeOtherSessions(userID uint, familyID string) error {
+	err := uc.collection.WithContext(uc.ctx).
+		Model(&models.Session{}).
+		Where("user_id = ? AND revoked_at IS NULL AND family_id <> ?", userID, familyID).
+		Update("revoked_at", time.Now()).Error
+	if err != nil {
+		return fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+
+	return nil
+}
+
+// VerifyEmail marks the owner of a verification token as verified. Tokens
+// can be used once.
+func (uc *AuthServiceImpl) VerifyEmail(token string) error {
+	return uc.collection.WithContext(uc.ctx).Transaction(func(tx *gorm.DB) error {
+		userToken, err := uc.useUserToken(tx, token, models.TokenPurposeVerify)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Model(&models.User{}).Where("id = ?", userToken.UserID).Update("verified", true).Error; err != nil {
+			return fmt.Errorf("failed to verify user: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// ResendVerification emails a new verification link to an unverified user.
+// Unknown and already verified addresses are ignored so the endpoint cannot
+// be used to probe for accounts.
+func (uc *AuthServiceImpl) ResendVerification(email string) error {
+	var user models.User
+
+	err := uc.collection.WithContext(uc.ctx).Where("email = ?", strings.ToLower(email)).First(&user).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return fmt.Errorf("failed to find user: %w", err)
+	}
+	if user.Verified {
+		return nil
+	}
+
+	token, err := uc.createUserToken(uc.collection.WithContext(uc.ctx), user.ID, models.TokenPurposeVerify, uc.verificationTTL())
+	if err != nil {
+		return err
+	}
+
+	return uc.sendVerificationEmail(&user, token)
+}
+
+// ForgotPassword emails a password reset link. Like ResendVerification it
+// does not report whether the address belongs to an account.
+func (uc *AuthServiceImpl) ForgotPassword(email string) error {
+	var user models.User
+
+	err := uc.collection.WithContext(uc.ctx).Where("email = ?", strings.ToLower(email)).First(&user).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return fmt.Errorf("failed to find user: %w", err)
+	}
+
+	token, err := uc.createUserToken(uc.collection.WithContext(uc.ctx), user.ID, models.TokenPurposeReset, uc.passwordResetTTL())
+	if err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf("Hello %s,\n\n"+
+		"someone asked to reset the password of your Fast Reading account. To choose a new one, open this link:\n\n"+
+		"%s\n\n"+
+		"The link expires in %s and can be used once. If it was not you, ignore this email; your password stays the same.\n",
+		user.Name, uc.link("/library/auth/login?reset_token="+token), humanDuration(uc.passwordResetTTL()))
+
+	if err := uc.mailer.Send(user.Email, "Reset your password", body); err != nil {
+		return fmt.Errorf("failed to send password reset email: %w", err)
+	}
+	return nil
+}
+
+// ResetPassword sets a new password for the owner of a reset token, discards
+// their other reset tokens and logs them out of every device. Access tokens
+// already issued stop working with their sessions.
+func (uc *AuthServiceImpl) ResetPassword(input *models.ResetPasswordInput) error {
+	hashedPassword, err := utils.HashPassword(input.Password)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	return uc.collection.WithContext(uc.ctx).Transaction(func(tx *gorm.DB) error {
+		userToken, err := uc.useUserToken(tx, input.Token, models.TokenPurposeReset)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+
+		// Following the link proves the user owns the address.
+		if err := tx.Model(&models.User{}).Where("id = ?", userToken.UserID).
+			Updates(map[string]interface{}{"password": hashedPassword, "verified": true, "updated_at": now}).Error; err != nil {
+			return fmt.Errorf("failed to update password: %w", err)
+		}
+
+		if err := tx.Model(&models.UserToken{}).
+			Where("user_id = ? AND purpose = ? AND used_at IS NULL", userToken.UserID, models.TokenPurposeReset).
+			Update("used_at", now).Error; err != nil {
+			return fmt.Errorf("failed to discard reset tokens: %w", err)
+		}
+
+		if err := tx.Model(&models.Session{}).
+			Where("user_id = ? AND revoked_at IS NULL", userToken.UserID).
+			Update("revoked_at", now).Error; err != nil {
+			return fmt.Errorf("failed to revoke sessions: %w", err)
+		}
+
+		return nil
+	})
+}
+
+func (uc *AuthServiceImpl) sendVerificationEmail(user *models.User, token string) error {
+	body := fmt.Sprintf("Hello %s,\n\n"+
+		"please confirm your email address for Fast Reading by opening this link:\n\n"+
+		"%s\n\n"+
+		"The link expires in %s. If you did not sign up, ignore this email.\n",
+		user.Name, uc.link("/library/auth/verify/"+token), humanDuration(uc.verificationTTL()))
+
+	if err := uc.mailer.Send(user.Email, "Confirm your email address", body); err != nil {
+		return fmt.Errorf("failed to send verification email: %w", err)
+	}
+	return nil
+}
+
+func (uc *AuthServiceImpl) verificationTTL() time.Duration {
+	if uc.config.VerificationTokenExpiresIn > 0 {
+		return uc.config.VerificationTokenExpiresIn
+	}
+	return defaultVerificationTokenTTL
+}
+
+func (uc *AuthServiceImpl) passwordResetTTL() time.Duration {
+	if uc.config.PasswordResetExpiresIn > 0 {
+		return uc.config.PasswordResetExpiresIn
+	}
+	return defaultPasswordResetTTL
+}
+
+// humanDuration formats link lifetimes for emails ("48 hours", "30 minutes").
+func humanDuration(d time.Duration) string {
+	if d >= time.Hour && d%time.Hour == 0 {
+		if d == time.Hour {
+			return "1 hour"
+		}
+		return fmt.Sprintf("%d hours", d/time.Hour)
+	}
+	minutes := int(d.Round(time.Minute) / time.Minute)
+	if minutes == 1 {
+		return "1 minute"
+	}
+	return fmt.Sprintf("%d minutes", minutes)
+}
+
+// link builds an absolute URL to the application for emails.
+func (uc *AuthServiceImpl) link(path string) string {
+	base := uc.config.AppURL
+	if base == "" {
+		base = "http://localhost:" + uc.config.Port
+	}
+	return strings.TrimRight(base, "/") + path
+}
+
+// createUserToken stores the hash of a new single-use token and returns the
+// token itself.
+func (uc *AuthServiceImpl) createUserToken(tx *gorm.DB, userID uint, purpose string, ttl time.Duration) (string, error) {
+	token, hash, err := utils.NewSecretToken()
+	if err != nil {
+		return "", err
+	}
+
+	userToken := models.UserToken{
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: hash,
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	if err := tx.Create(&userToken).Error; err != nil {
+		return "", fmt.Errorf("failed to store %s token: %w", purpose, err)
+	}
+
+	return token, nil
+}
+
+// useUserToken marks an unused, unexpired token as used and returns it.
+func (uc *AuthServiceImpl) useUserToken(tx *gorm.DB, token, purpose string) (*models.UserToken, error) {
+	var userToken models.UserToken
+
+	err := tx.Where("token_hash = ? AND purpose = ?", utils.HashSecretToken(token), purpose).First(&userToken).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidUserToken
+		}
+		return nil, fmt.Errorf("failed to find %s token: %w", purpose, err)
+	}
+
+	if time.Now().After(userToken.ExpiresAt) {
+		return nil, ErrInvalidUserToken
+	}
+
+	used := tx.Model(&models.UserToken{}).
+		Where("id = ? AND used_at IS NULL", userToken.ID).
+		Update("used_at", time.Now())
+	if used.Error != nil {
+		return nil, fmt.Errorf("failed to use %s token: %w", purpose, used.Error)
+	}
+	if used.RowsAffected == 0 {
+		return nil, ErrInvalidUserToken
+	}
+
+	return &userToken, nil
+}
+
+// issueTokens stores the session under a fresh token ID and signs the
+// access and refresh tokens for it.
+func (uc *AuthServiceImpl) issueTokens(tx *gorm.DB, user *models.User, session *models.Session) (*models.SignInResponse, error) {
+	tokenID, err := utils.NewTokenID()
+	if err != nil {
+		return nil, err
+	}
+
+	session.TokenID = tokenID
+	session.ExpiresAt = time.Now().Add(uc.config.RefreshTokenExpiresIn)
+
+	if err := tx.Create(session).Error; err != nil {
+		return nil, fmt.Errorf("failed to create session: %w", err)
+	}
+
+	// The access token names the device session, so revoking the session
+	// ends the access token too (see UserService.HasActiveSession)
+	accessToken, err := utils.CreateTokenWithID(uc.config.AccessTokenExpiresIn, user.ID, session.FamilyID, uc.config.AccessTokenPrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create access token: %w", err)
+	}
+
+	refreshToken, err := utils.CreateTokenWithID(uc.config.RefreshTokenExpiresIn, user.ID, tokenID, uc.config.RefreshTokenPrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create refresh token: %w", err)
+	}
+
+	return &models.SignInResponse{
+		User: models.UserResponse{
+			ID:        user.ID,
+			Name:      user.Name,
+			Email:     user.Email,
+			Role:      user.Role,
+			CreatedAt: user.CreatedAt,
+			UpdatedAt: user.UpdatedAt,
+		},
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+	}, nil
+}
+
+// refreshTokenID validates a refresh token and returns its "jti" claim.
+func (uc *AuthServiceImpl) refreshTokenID(refreshToken string) (string, error) {
+	_, tokenID, err := utils.ValidateTokenWithID(refreshToken, uc.config.RefreshTokenPublicKey)
+	if err != nil || tokenID == "" {
+		return "", ErrInvalidToken
+	}
+	return tokenID, nil
+}
+
+// revokeFamily revokes every session of the device tokenID was issued to.
+func (uc *AuthServiceImpl) revokeFamily(tokenID string) error {
+	var session models.Session
+
+	err := uc.collection.WithContext(uc.ctx).Where("token_id = ?", tokenID).First(&session).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return fmt.Errorf("failed to find session: %w", err)
+	}
+
+	err = uc.collection.WithContext(uc.ctx).
+		Model(&models.Session{}).
+		Where("family_id = ? AND revoked_at IS NULL", session.FamilyID).
+		Update("revoked_at", time.Now()).Error
+	if err != nil {
+		return fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+
+	return nil
 }
@@ -0,0 +1,268 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/st107853/fast_reading/mailer"
+	"github.com/st107853/fast_reading/models"
+	"github.com/st107853/fast_reading/testutil"
+	"github.com/st107853/fast_reading/utils"
+	"gorm.io/gorm"
+)
+
+func newTestAuthService(t *testing.T) (AuthService, *mailer.LogMailer, *testutil.Fixtures, *gorm.DB) {
+	t.Helper()
+	db, f := setup(t)
+	mail := mailer.NewLogMailer(nil)
+	return NewAuthService(db, context.Background(), testutil.Config(t), mail), mail, f, db
+}
+
+// linkToken returns what follows prefix in the last message sent to to.
+func linkToken(t *testing.T, mail *mailer.LogMailer, to, prefix string) string {
+	t.Helper()
+
+	messages := mail.Messages()
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].To != to {
+			continue
+		}
+		_, rest, found := strings.Cut(messages[i].Body, prefix)
+		if !found {
+			t.Fatalf("message to %s has no %s link:\n%s", to, prefix, messages[i].Body)
+		}
+		token, _, _ := strings.Cut(rest, "\n")
+		return token
+	}
+	t.Fatalf("no message was sent to %s", to)
+	return ""
+}
+
+func signIn(t *testing.T, as AuthService, email string) *models.SignInResponse {
+	t.Helper()
+	res, err := as.SignInUser(&models.SignInInput{Email: email, Password: testutil.Password, Device: "test"})
+	if err != nil {
+		t.Fatalf("sign in %s: %v", email, err)
+	}
+	return res
+}
+
+// familyOf returns the session family the access token of res belongs to,
+// as DeserializeUser finds it.
+func familyOf(t *testing.T, res *models.SignInResponse) (uint, string) {
+	t.Helper()
+	sub, familyID, err := utils.ValidateTokenWithID(res.AccessToken, testutil.Config(t).AccessTokenPublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return uint(sub.(float64)), familyID
+}
+
+// accessActive reports whether the access token of res still gets its user in.
+func accessActive(t *testing.T, db *gorm.DB, res *models.SignInResponse) bool {
+	t.Helper()
+	userID, familyID := familyOf(t, res)
+	active, err := NewUserServiceImpl(db, context.Background()).HasActiveSession(userID, familyID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return active
+}
+
+func TestSignUp(t *testing.T) {
+	as, mail, _, _ := newTestAuthService(t)
+
+	user, err := as.SignUpUser(&models.SignUpInput{
+		Name: "Sam", Email: "Sam@Example.com", Password: "secret-pass", PasswordConfirm: "secret-pass",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Email != "sam@example.com" || user.Verified || user.Role != models.RoleUser {
+		t.Errorf("user = %+v", user)
+	}
+
+	token := linkToken(t, mail, "sam@example.com", "http://localhost:8080/library/auth/verify/")
+	if err := as.VerifyEmail(token); err != nil {
+		t.Fatal(err)
+	}
+	if err := as.VerifyEmail(token); !errors.Is(err, ErrInvalidUserToken) {
+		t.Errorf("second verification: got %v", err)
+	}
+
+	_, err = as.SignUpUser(&models.SignUpInput{Email: "reader@example.com", Password: "secret-pass"})
+	if err == nil || !strings.Contains(err.Error(), "email already exist") {
+		t.Errorf("duplicate email: got %v", err)
+	}
+}
+
+func TestSignIn(t *testing.T) {
+	as, _, f, db := newTestAuthService(t)
+
+	res := signIn(t, as, "READER@example.com")
+	if res.User.ID != f.Reader.ID || res.AccessToken == "" || res.RefreshToken == "" {
+		t.Errorf("response = %+v", res)
+	}
+
+	_, err := as.SignInUser(&models.SignInInput{Email: "reader@example.com", Password: "wrong"})
+	if !errors.Is(err, ErrInvalidPassword) {
+		t.Errorf("wrong password: got %v", err)
+	}
+	_, err = as.SignInUser(&models.SignInInput{Email: "nobody@example.com", Password: testutil.Password})
+	if !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("unknown email: got %v", err)
+	}
+
+	db.Model(f.Reader).Update("banned_at", f.Reader.CreatedAt)
+	_, err = as.SignInUser(&models.SignInInput{Email: "reader@example.com", Password: testutil.Password})
+	if !errors.Is(err, ErrUserBanned) {
+		t.Errorf("banned user: got %v", err)
+	}
+}
+
+func TestRefreshTokens(t *testing.T) {
+	as, _, f, _ := newTestAuthService(t)
+
+	first := signIn(t, as, "reader@example.com")
+	second, err := as.RefreshTokens(first.RefreshToken, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.RefreshToken == first.RefreshToken {
+		t.Fatal("refresh token was not rotated")
+	}
+
+	if _, err := as.RefreshTokens(first.RefreshToken, ""); !errors.Is(err, ErrTokenReused) {
+		t.Fatalf("reused token: got %v", err)
+	}
+	// Reuse revokes the whole device, including the rotated token
+	if _, err := as.RefreshTokens(second.RefreshToken, ""); !errors.Is(err, ErrTokenReused) {
+		t.Errorf("token of a revoked device: got %v", err)
+	}
+	if _, err := as.RefreshTokens("not a token", ""); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("garbage token: got %v", err)
+	}
+
+	sessions, err := as.ListSessions(f.Reader.ID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 0 {
+		t.Errorf("%d sessions left after reuse", len(sessions))
+	}
+}
+
+func TestSessions(t *testing.T) {
+	as, _, f, db := newTestAuthService(t)
+
+	phone := signIn(t, as, "reader@example.com")
+	laptop := signIn(t, as, "reader@example.com")
+	tablet := signIn(t, as, "reader@example.com")
+	_, current := familyOf(t, laptop)
+
+	sessions, err := as.ListSessions(f.Reader.ID, current)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 3 {
+		t.Fatalf("%d sessions, want 3", len(sessions))
+	}
+	marked := 0
+	for _, s := range sessions {
+		if s.Current {
+			marked++
+		}
+	}
+	if marked != 1 {
+		t.Errorf("%d sessions are marked current, want 1", marked)
+	}
+
+	if err := as.LogoutUser(phone.RefreshToken); err != nil {
+		t.Fatal(err)
+	}
+	if err := as.RevokeSession(f.Author.ID, sessions[0].ID); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("revoking another user's session: got %v", err)
+	}
+	if err := as.RevokeOtherSessions(f.Reader.ID, current); err != nil {
+		t.Fatal(err)
+	}
+
+	sessions, _ = as.ListSessions(f.Reader.ID, current)
+	if len(sessions) != 1 || !sessions[0].Current {
+		t.Fatalf("sessions = %+v, want only the laptop", sessions)
+	}
+	// Access tokens end with their session
+	if accessActive(t, db, phone) || accessActive(t, db, tablet) || !accessActive(t, db, laptop) {
+		t.Error("access tokens do not follow their sessions")
+	}
+	if _, err := as.RefreshTokens(tablet.RefreshToken, ""); err == nil {
+		t.Error("a revoked device can still refresh")
+	}
+
+	if err := as.RevokeSession(f.Reader.ID, sessions[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	if sessions, _ = as.ListSessions(f.Reader.ID, ""); len(sessions) != 0 {
+		t.Errorf("%d sessions left", len(sessions))
+	}
+}
+
+func TestResendVerification(t *testing.T) {
+	as, mail, f, _ := newTestAuthService(t)
+
+	if err := as.ResendVerification("reader@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if err := as.ResendVerification("nobody@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(mail.Messages()); n != 0 {
+		t.Fatalf("sent %d messages to verified or unknown addresses", n)
+	}
+
+	if err := as.ResendVerification("newcomer@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	token := linkToken(t, mail, f.Newcomer.Email, "/library/auth/verify/")
+	if err := as.VerifyEmail(token); err != nil {
+		t.Fatal(err)
+	}
+	if err := as.VerifyEmail("made-up"); !errors.Is(err, ErrInvalidUserToken) {
+		t.Errorf("unknown token: got %v", err)
+	}
+}
+
+func TestResetPassword(t *testing.T) {
+	as, mail, f, db := newTestAuthService(t)
+
+	session := signIn(t, as, "newcomer@example.com")
+
+	if err := as.ForgotPassword("newcomer@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	token := linkToken(t, mail, f.Newcomer.Email, "/library/auth/login?reset_token=")
+
+	input := &models.ResetPasswordInput{Token: token, Password: "a-new-password", PasswordConfirm: "a-new-password"}
+	if err := as.ResetPassword(input); err != nil {
+		t.Fatal(err)
+	}
+	if err := as.ResetPassword(input); !errors.Is(err, ErrInvalidUserToken) {
+		t.Errorf("second reset: got %v", err)
+	}
+
+	if _, err := as.RefreshTokens(session.RefreshToken, ""); err == nil {
+		t.Error("the reset left an old session active")
+	}
+	if accessActive(t, db, session) {
+		t.Error("the reset left an old access token working")
+	}
+	res, err := as.SignInUser(&models.SignInInput{Email: "newcomer@example.com", Password: "a-new-password"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.User.ID != f.Newcomer.ID {
+		t.Errorf("signed in as %d", res.User.ID)
+	}
+}
@@ -0,0 +1,188 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/st107853/fast_reading/models"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultHitsPerPage = 20
+	maxHitsPerPage     = 50
+
+	// snippetsPerBook limits the chapters quoted for each search hit.
+	snippetsPerBook = 3
+)
+
+// searchTSQuery parses the named argument @q with web search syntax.
+const searchTSQuery = "websearch_to_tsquery('" + models.SearchConfig + "', @q)"
+
+// searchMatch selects books whose metadata or any chapter matches @q.
+const searchMatch = "(books.search_vector @@ " + searchTSQuery +
+	" OR books.id IN (SELECT book_id FROM chapters WHERE chapters.search_vector @@ " + searchTSQuery + "))"
+
+// searchHitsFrom is shared by the count and the page of SearchText: released
+// books matching the query, which is available as "query".
+const searchHitsFrom = `
+FROM books, ` + searchTSQuery + ` AS query
+WHERE books.released = true AND (books.search_vector @@ query OR EXISTS (
+	SELECT 1 FROM chapters WHERE chapters.book_id = books.id AND chapters.search_vector @@ query))`
+
+// A book ranks by its best matching chapter on top of its own metadata.
+const searchHitsSQL = `
+SELECT books.id, books.name, books.author, books.cover_path, books.cover_thumb_path,
+	ts_rank(books.search_vector, query) + coalesce((
+		SELECT max(ts_rank(chapters.search_vector, query)) FROM chapters
+		WHERE chapters.book_id = books.id AND chapters.search_vector @@ query), 0) AS rank` +
+	searchHitsFrom + `
+ORDER BY rank DESC, books.id
+LIMIT @limit OFFSET @offset`
+
+// ts_headline is slow on long texts, so it only runs on the best chapters of
+// each book.
+const searchSnippetsSQL = `
+SELECT book_id, chapter_order, title,
+	ts_headline('` + models.SearchConfig + `', text, query, @options) AS snippet
+FROM (
+	SELECT chapters.book_id, chapters.chapter_order, chapters.title, chapters.text, query,
+		row_number() OVER (PARTITION BY chapters.book_id
+			ORDER BY ts_rank(chapters.search_vector, query) DESC, chapters.chapter_order) AS n
+	FROM chapters, ` + searchTSQuery + ` AS query
+	WHERE chapters.book_id IN @books AND chapters.search_vector @@ query
+) matched
+WHERE n <= @per_book
+ORDER BY book_id, n`
+
+// Matched words are wrapped in private-use characters by ts_headline and
+// turned into <mark> after the snippet is escaped.
+const (
+	highlightStart = "\uE000"
+	highlightStop  = "\uE001"
+)
+
+var headlineOptions = fmt.Sprintf(`StartSel="%s", StopSel="%s", MinWords=10, MaxWords=30, MaxFragments=2, FragmentDelimiter=" … "`,
+	highlightStart, highlightStop)
+
+var highlighter = strings.NewReplacer(highlightStart, "<mark>", highlightStop, "</mark>")
+
+type searchHitRow struct {
+	models.BookBase
+	Rank float64
+}
+
+type searchSnippetRow struct {
+	BookID       uint
+	ChapterOrder int
+	Title        string
+	Snippet      string
+}
+
+// SearchText runs a full-text search over released books: titles, authors,
+// descriptions and chapter text. Hits are ranked by relevance and quote the
+// chapters that matched. Databases other than Postgres only match book names
+// and return no snippets.
+func (bs *BookServiceImpl) SearchText(query models.SearchQuery) (models.SearchPage, error) {
+	page := models.SearchPage{Page: query.Page, PerPage: query.PerPage, Hits: []models.SearchHit{}}
+	if page.Page < 1 {
+		page.Page = 1
+	}
+	if page.PerPage < 1 {
+		page.PerPage = defaultHitsPerPage
+	}
+	if page.PerPage > maxHitsPerPage {
+		page.PerPage = maxHitsPerPage
+	}
+
+	text := strings.TrimSpace(query.Query)
+	if text == "" {
+		return page, ErrEmptySearch
+	}
+
+	if bs.collection.Dialector.Name() != models.DriverPostgres {
+		return bs.searchNames(text, page)
+	}
+
+	db := bs.collection.WithContext(bs.ctx)
+	q := sql.Named("q", text)
+
+	if err := db.Raw("SELECT count(*)"+searchHitsFrom, q).Scan(&page.Total).Error; err != nil {
+		return page, fmt.Errorf("bsi: failed to count search hits: %w", err)
+	}
+
+	var rows []searchHitRow
+	err := db.Raw(searchHitsSQL, q,
+		sql.Named("limit", page.PerPage),
+		sql.Named("offset", (page.Page-1)*page.PerPage)).
+		Scan(&rows).Error
+	if err != nil {
+		return page, fmt.Errorf("bsi: failed to search books: %w", err)
+	}
+	if len(rows) == 0 {
+		return page, nil
+	}
+
+	ids := make([]uint, len(rows))
+	for i, row := range rows {
+		ids[i] = row.BookID
+	}
+
+	var snippets []searchSnippetRow
+	err = db.Raw(searchSnippetsSQL, q,
+		sql.Named("books", ids),
+		sql.Named("options", headlineOptions),
+		sql.Named("per_book", snippetsPerBook)).
+		Scan(&snippets).Error
+	if err != nil {
+		return page, fmt.Errorf("bsi: failed to highlight search hits: %w", err)
+	}
+
+	byBook := make(map[uint][]models.SearchSnippet)
+	for _, s := range snippets {
+		byBook[s.BookID] = append(byBook[s.BookID], models.SearchSnippet{
+			ChapterID:    s.ChapterOrder,
+			ChapterTitle: s.Title,
+			Text:         highlighter.Replace(html.EscapeString(s.Snippet)),
+		})
+	}
+
+	for _, row := range rows {
+		hit := models.SearchHit{Book: row.BookBase, Rank: row.Rank, Snippets: byBook[row.BookID]}
+		if hit.Snippets == nil {
+			hit.Snippets = []models.SearchSnippet{}
+		}
+		page.Hits = append(page.Hits, hit)
+	}
+
+	return page, nil
+}
+
+// searchNames is the SearchText fallback without full-text indexes.
+func (bs *BookServiceImpl) searchNames(text string, page models.SearchPage) (models.SearchPage, error) {
+	db := bs.collection.WithContext(bs.ctx).Model(&models.Book{}).
+		Where("released = ?", true).
+		Scopes(searchScope(text, nil)).
+		Session(&gorm.Session{})
+
+	if err := db.Count(&page.Total).Error; err != nil {
+		return page, fmt.Errorf("bsi: failed to count search hits: %w", err)
+	}
+
+	var books []models.BookBase
+	err := db.Order("name, id").
+		Offset((page.Page - 1) * page.PerPage).
+		Limit(page.PerPage).
+		Find(&books).Error
+	if err != nil {
+		return page, fmt.Errorf("bsi: failed to search books: %w", err)
+	}
+
+	for _, book := range books {
+		page.Hits = append(page.Hits, models.SearchHit{Book: book, Snippets: []models.SearchSnippet{}})
+	}
+
+	return page, nil
+}
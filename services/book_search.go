@@ -0,0 +1,207 @@
+package services
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/st107853/fast_reading/models"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultBooksPerPage = 20
+	maxBooksPerPage     = 100
+)
+
+// popularityColumn counts how many users added a book to their favourites.
+const popularityColumn = "(SELECT COUNT(*) FROM user_favorites WHERE user_favorites.book_id = books.id)"
+
+// bookSorts maps sort names to the SQL expression they order by and whether
+// they run descending by default.
+var bookSorts = map[string]struct {
+	column string
+	desc   bool
+}{
+	models.SortName:            {"books.name", false},
+	models.SortAuthor:          {"books.author", false},
+	models.SortReleaseDate:     {"books.release_date", true},
+	models.SortPublicationYear: {"books.publication_year", true},
+	models.SortPopularity:      {popularityColumn, true},
+}
+
+// bookCursor is the position after the last book of a page: its sort value
+// and ID, which breaks ties. Sort and Desc make sure a cursor is not reused
+// with another order.
+type bookCursor struct {
+	Sort  string      `json:"s"`
+	Desc  bool        `json:"d,omitempty"`
+	Value interface{} `json:"v"`
+	ID    uint        `json:"id"`
+}
+
+// SearchBooks returns one page of books matching the query, ordered by the
+// requested sort and then by ID so the cursor is stable.
+func (bs *BookServiceImpl) SearchBooks(query models.BookQuery) (models.BookPage, error) {
+	page := models.BookPage{Books: []models.BookBase{}}
+
+	if query.Sort == "" {
+		query.Sort = models.SortName
+	}
+	sort, ok := bookSorts[query.Sort]
+	if !ok {
+		return page, ErrInvalidSort
+	}
+	desc := sort.desc
+	switch query.Order {
+	case "asc":
+		desc = false
+	case "desc":
+		desc = true
+	case "":
+	default:
+		return page, ErrInvalidSort
+	}
+
+	limit := query.Limit
+	if limit <= 0 {
+		limit = defaultBooksPerPage
+	}
+	if limit > maxBooksPerPage {
+		limit = maxBooksPerPage
+	}
+
+	filtered := bs.collection.Model(&models.Book{}).
+		Scopes(filterScope(query.Code, query.UserID), searchScope(query.Keyword, query.LabelIDs)).
+		Session(&gorm.Session{})
+
+	if err := filtered.Count(&page.Total).Error; err != nil {
+		return page, fmt.Errorf("bsi: failed to count books: %w", err)
+	}
+
+	direction, compare := "ASC", ">"
+	if desc {
+		direction, compare = "DESC", "<"
+	}
+
+	db := filtered
+	if query.Cursor != "" {
+		cursor, err := decodeBookCursor(query.Cursor, query.Sort, desc)
+		if err != nil {
+			return page, err
+		}
+		db = db.Where(fmt.Sprintf("(%[1]s %[2]s ?) OR (%[1]s = ? AND books.id %[2]s ?)", sort.column, compare),
+			cursor.Value, cursor.Value, cursor.ID)
+	}
+
+	var books []models.Book
+	err := db.Order(fmt.Sprintf("%s %s, books.id %s", sort.column, direction, direction)).
+		Limit(limit + 1).
+		Find(&books).Error
+	if err != nil {
+		return page, fmt.Errorf("bsi: failed to search books: %w", err)
+	}
+
+	if len(books) > limit {
+		books = books[:limit]
+
+		last := books[len(books)-1]
+		value, err := bs.sortValue(query.Sort, last)
+		if err != nil {
+			return page, err
+		}
+		page.NextCursor = encodeBookCursor(bookCursor{Sort: query.Sort, Desc: desc, Value: value, ID: last.BookID})
+	}
+
+	for _, book := range books {
+		page.Books = append(page.Books, book.BookBase)
+	}
+
+	return page, nil
+}
+
+// filterScope restricts a listing by filter code:
+// 0 - released, 1 - continue reading, 2 - created, 3 - favourite.
+func filterScope(code string, userID uint) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		switch code {
+		case models.FilterReleased:
+			return db.Where("released = ?", true)
+		case models.FilterStarted:
+			return db.Where("id IN (?)", db.Session(&gorm.Session{NewDB: true}).
+				Table("reading_progress").Select("book_id").Where("user_id = ?", userID))
+		case models.FilterCreated:
+			return db.Where("creator_user_id = ?", userID)
+		case models.FilterFavourite:
+			return db.Where("id IN (?)", db.Session(&gorm.Session{NewDB: true}).
+				Table("user_favorites").Select("book_id").Where("user_id = ?", userID))
+		}
+		return db
+	}
+}
+
+// sortValue returns the value a book is ordered by, in a form that survives
+// the JSON round trip through a cursor. Times keep their offset because SQLite
+// compares them as text.
+func (bs *BookServiceImpl) sortValue(sort string, book models.Book) (interface{}, error) {
+	switch sort {
+	case models.SortName:
+		return book.Name, nil
+	case models.SortAuthor:
+		return book.Author, nil
+	case models.SortReleaseDate:
+		return book.ReleaseDate.Format(time.RFC3339Nano), nil
+	case models.SortPublicationYear:
+		return book.PublicationYear, nil
+	default:
+		var count int64
+		err := bs.collection.Table("user_favorites").Where("book_id = ?", book.BookID).Count(&count).Error
+		if err != nil {
+			return nil, fmt.Errorf("bsi: failed to count favourites: %w", err)
+		}
+		return count, nil
+	}
+}
+
+func encodeBookCursor(c bookCursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeBookCursor(s, sort string, desc bool) (bookCursor, error) {
+	var c bookCursor
+
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, ErrInvalidCursor
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&c); err != nil || c.Sort != sort || c.Desc != desc {
+		return c, ErrInvalidCursor
+	}
+
+	switch v := c.Value.(type) {
+	case json.Number:
+		n, err := v.Int64()
+		if err != nil {
+			return c, ErrInvalidCursor
+		}
+		c.Value = n
+	case string:
+		if sort == models.SortReleaseDate {
+			t, err := time.Parse(time.RFC3339Nano, v)
+			if err != nil {
+				return c, ErrInvalidCursor
+			}
+			c.Value = t
+		}
+	default:
+		return c, ErrInvalidCursor
+	}
+
+	return c, nil
+}
@@ -1,29 +1,60 @@
 package services
 
 import (
+	"errors"
+	"io"
 	"mime/multipart"
 
 	"github.com/st107853/fast_reading/models"
 )
 
+// Errors returned by the label methods of BookService.
+var (
+	ErrEmptyLabelName       = errors.New("label name must not be empty")
+	ErrLabelExists          = errors.New("a label with that name already exists")
+	ErrMergeLabelIntoItself = errors.New("cannot merge a label into itself")
+)
+
+// Errors returned by SearchBooks and SearchText for malformed queries.
+var (
+	ErrInvalidSort   = errors.New("sort must be one of name, author, release_date, publication_year, popularity")
+	ErrInvalidCursor = errors.New("invalid cursor")
+	ErrEmptySearch   = errors.New("search query is empty")
+)
+
 type BookService interface {
 	InsertBook(input models.Book, file *multipart.FileHeader, creatorUserID uint) (uint, error)
+	ImportEpub(file *multipart.FileHeader, creatorUserID uint) (uint, error)
+	ImportText(input models.Book, file *multipart.FileHeader, opts SplitOptions, creatorUserID uint) (uint, error)
+	ExportEpub(book models.GetBook, w io.Writer) error
 	FindBookByID(bookId uint) (models.GetBook, error)
+	FindBookCreatorID(bookId uint) (uint, error)
 	FindBooksByCreatorID(creatorId uint) ([]models.BookBase, []models.Label, error)
 	FindFavoriteBooksByUserID(userId uint) ([]models.BookBase, []models.Label, error)
 	FindStartedBooks(userID uint) ([]models.BookBase, error)
-	InsertChapter(chapter models.Chapter) (uint, error)
+	InsertChapter(chapter models.Chapter, authorID uint) (uint, error)
 	FindChapterByID(id string) (models.Chapter, error)
 	FindBooksChapterByIDs(bookId, chapterId uint) (models.ChapterResponse, error)
 	DeleteAll() error
 	DeleteBook(bookId uint) error
 	DeleteChapter(chapterId string) error
-	ListAllBooks() ([]models.BookBase, error)
 	ListAllLabels() ([]*models.Label, error)
 	ListLastReleased(n int) ([]models.Book, error)
-	ReleaseBook(bookId uint) error
+	ReleaseBook(bookId, publisherID uint) error
+	UnreleaseBook(bookId uint) error
 	UpdateBook(bookId uint, file *multipart.FileHeader, book models.Book) (models.Book, error)
-	UpdateChapter(chapterId uint, chapter models.Chapter) (models.Chapter, error)
+	UpdateChapter(chapterId uint, chapter models.Chapter, editorID uint) (models.Chapter, error)
+	ListChapterRevisions(chapterId uint) ([]models.RevisionSummary, error)
+	DiffChapterRevisions(chapterId, fromId, toId uint) (models.RevisionDiff, error)
+	RestoreChapterRevision(chapterId, revisionId, userID uint) (models.Chapter, error)
+	SaveChapterDraft(userID, bookID, chapterID uint, input models.DraftInput) (models.ChapterDraft, error)
+	FindChapterDraft(userID, bookID, chapterID uint) (models.ChapterDraft, error)
+	DiscardChapterDraft(userID, bookID, chapterID uint) error
 	AddLabel(bookId uint, labelIds []uint) error
-	SearchBooks(keyword string, labelIds []uint, filterCode string, userID uint) ([]models.BookBase, error)
+	CreateLabel(name string) (models.Label, error)
+	RenameLabel(labelId uint, name string) (models.Label, error)
+	MergeLabels(sourceId, targetId uint) error
+	DeleteLabel(labelId uint) error
+	SearchBooks(query models.BookQuery) (models.BookPage, error)
+	SearchText(query models.SearchQuery) (models.SearchPage, error)
 }
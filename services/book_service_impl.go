@@ -1,51 +1,221 @@
 package services
 
 import (
+	"bytes"
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
-	_ "image/jpeg"
 	"io"
+	"log"
+	"mime"
 	"mime/multipart"
-	"os"
 	"path/filepath"
+	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
+	"github.com/st107853/fast_reading/epub"
 	"github.com/st107853/fast_reading/models"
+	"github.com/st107853/fast_reading/storage"
 	"gorm.io/gorm"
 )
 
 type BookServiceImpl struct {
 	collection *gorm.DB
 	ctx        context.Context
+	blobs      storage.BlobStore
 }
 
-func NewBookService(collection *gorm.DB, ctx context.Context) *BookServiceImpl {
-	return &BookServiceImpl{collection: collection, ctx: ctx}
+func NewBookService(collection *gorm.DB, ctx context.Context, blobs storage.BlobStore) *BookServiceImpl {
+	return &BookServiceImpl{collection: collection, ctx: ctx, blobs: blobs}
 }
 
-// InsertBook inserts a new book into the database and saves the cover file if provided.
+// InsertBook inserts a new book into the database and saves the cover file if
+// provided. An invalid cover is rejected before the book is created.
 func (bs *BookServiceImpl) InsertBook(book models.Book, file *multipart.FileHeader, creatorUserID uint) (uint, error) {
 	book.CreatorUserID = creatorUserID
 
-	// Wrap the entire DB work in a transaction
-	var bookID uint
-	err := bs.collection.Transaction(func(tx *gorm.DB) error {
+	var cover *processedCover
+	if file != nil {
+		var err error
+		if cover, err = openCover(file); err != nil {
+			return 0, err
+		}
+	}
 
-		if err := tx.Create(&book).Error; err != nil {
-			return fmt.Errorf("failed to insert book: %w", err)
+	bookID, err := bs.createBook(&book, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	// Write the cover ONLY after the transaction committed successfully
+	if cover != nil {
+		if err := bs.saveCover(bookID, cover); err != nil {
+			return bookID, fmt.Errorf("book created but cover upload failed: %w", err)
+		}
+	}
+
+	return bookID, nil
+}
+
+// ImportEpub creates a book from an uploaded EPUB file: metadata from the OPF
+// package, one chapter per spine document and the manifest cover image.
+func (bs *BookServiceImpl) ImportEpub(file *multipart.FileHeader, creatorUserID uint) (uint, error) {
+	src, err := file.Open()
+	if err != nil {
+		return 0, fmt.Errorf("bsi: failed to open uploaded file: %w", err)
+	}
+	defer src.Close()
+
+	parsed, err := epub.Read(src, file.Size)
+	if err != nil {
+		return 0, fmt.Errorf("bsi: failed to read epub: %w", err)
+	}
+
+	book := models.Book{
+		BookBase: models.BookBase{
+			Name:   parsed.Title,
+			Author: parsed.Author,
+		},
+		Description:   parsed.Description,
+		CreatorUserID: creatorUserID,
+	}
+	if book.Name == "" {
+		book.Name = strings.TrimSuffix(file.Filename, filepath.Ext(file.Filename))
+	}
+	if book.Author == "" {
+		book.Author = "Unknown"
+	}
+
+	if len(parsed.Subjects) > 0 {
+		if err := bs.collection.Where("LOWER(name) IN ?", lowerAll(parsed.Subjects)).Find(&book.BookLabels).Error; err != nil {
+			return 0, fmt.Errorf("bsi: failed to match labels: %w", err)
+		}
+	}
+
+	chapters := make([]models.Chapter, len(parsed.Chapters))
+	for i, ch := range parsed.Chapters {
+		chapters[i] = models.Chapter{Title: ch.Title, Text: ch.Text}
+	}
+
+	bookID, err := bs.createBook(&book, chapters)
+	if err != nil {
+		return 0, err
+	}
+
+	if parsed.Cover != nil {
+		cover, err := processCover(bytes.NewReader(parsed.Cover.Data))
+		if err == nil {
+			err = bs.saveCover(bookID, cover)
+		}
+		if err != nil {
+			return bookID, fmt.Errorf("book imported but cover upload failed: %w", err)
+		}
+	}
+
+	return bookID, nil
+}
+
+// ImportText creates a book from an uploaded .txt or .md file, splitting it
+// into chapters with SplitText. The book and all chapters are inserted in one
+// transaction.
+func (bs *BookServiceImpl) ImportText(book models.Book, file *multipart.FileHeader, opts SplitOptions, creatorUserID uint) (uint, error) {
+	if file.Size > maxTextImportSize {
+		return 0, fmt.Errorf("bsi: file is larger than %d bytes", maxTextImportSize)
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		return 0, fmt.Errorf("bsi: failed to open uploaded file: %w", err)
+	}
+	defer src.Close()
+
+	data, err := io.ReadAll(io.LimitReader(src, maxTextImportSize))
+	if err != nil {
+		return 0, fmt.Errorf("bsi: failed to read uploaded file: %w", err)
+	}
+	if !utf8.Valid(data) {
+		return 0, fmt.Errorf("bsi: file is not valid UTF-8 text")
+	}
+
+	ext := strings.ToLower(filepath.Ext(file.Filename))
+	opts.Markdown = ext == ".md" || ext == ".markdown"
+
+	chapters := SplitText(string(data), opts)
+	if len(chapters) == 0 {
+		return 0, fmt.Errorf("bsi: file contains no text")
+	}
+
+	book.CreatorUserID = creatorUserID
+	if book.Name == "" {
+		book.Name = strings.TrimSuffix(file.Filename, filepath.Ext(file.Filename))
+	}
+	if book.Author == "" {
+		book.Author = "Unknown"
+	}
+
+	return bs.createBook(&book, chapters)
+}
+
+// ExportEpub writes the book, its chapters in chapter_order, its labels as
+// subjects and the stored cover as an EPUB 3 container.
+func (bs *BookServiceImpl) ExportEpub(book models.GetBook, w io.Writer) error {
+	out := &epub.Book{
+		Identifier:  fmt.Sprintf("urn:fast-reading:book:%d", book.BookID),
+		Title:       book.Name,
+		Author:      book.Author,
+		Description: book.Description,
+	}
+
+	var sample strings.Builder
+	for _, ch := range book.Chapters {
+		out.Chapters = append(out.Chapters, epub.Chapter{Title: ch.Title, Text: ch.Text})
+		if sample.Len() < 4096 {
+			sample.WriteString(ch.Text)
+		}
+	}
+	out.Language = guessLanguage(sample.String())
+
+	for _, label := range book.BookLabels {
+		out.Subjects = append(out.Subjects, label.Name)
+	}
+
+	if coverPath := string(book.CoverPath); coverPath != "" && coverPath != "null" {
+		data, err := bs.readCover(coverPath)
+		if err == nil {
+			ext := strings.ToLower(filepath.Ext(coverPath))
+			out.Cover = &epub.Cover{Ext: ext, MediaType: mime.TypeByExtension(ext), Data: data}
+		} else if !errors.Is(err, storage.ErrNotFound) {
+			return fmt.Errorf("bsi: failed to read cover: %w", err)
 		}
-		bookID = book.BookID
+	}
+
+	if err := epub.Write(w, out); err != nil {
+		return fmt.Errorf("bsi: failed to export epub: %w", err)
+	}
+
+	return nil
+}
 
-		if file == nil {
-			return nil
+// createBook inserts the book and its chapters (numbered in the given order)
+// in one transaction.
+func (bs *BookServiceImpl) createBook(book *models.Book, chapters []models.Chapter) (uint, error) {
+	err := bs.collection.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(book).Error; err != nil {
+			return fmt.Errorf("failed to insert book: %w", err)
 		}
 
-		// Prepare the cover path and update inside the same transaction
-		ext := filepath.Ext(file.Filename)
-		coverFileName := fmt.Sprintf("%d%s", bookID, ext)
+		if len(chapters) > 0 {
+			for i := range chapters {
+				chapters[i].BookID = book.BookID
+				chapters[i].ChapterOrder = i + 1
+			}
 
-		if err := tx.Model(&book).Update("cover_path", coverFileName).Error; err != nil {
-			return fmt.Errorf("failed to update cover_path: %w", err)
+			if err := tx.Create(&chapters).Error; err != nil {
+				return fmt.Errorf("failed to insert chapters: %w", err)
+			}
 		}
 
 		return nil
@@ -55,22 +225,80 @@ func (bs *BookServiceImpl) InsertBook(book models.Book, file *multipart.FileHead
 		return 0, err
 	}
 
-	// Write file ONLY after transaction committed successfully
-	if file != nil {
-		ext := filepath.Ext(file.Filename)
-		coverFileName := fmt.Sprintf("%d%s", bookID, ext)
-		dstPath := filepath.Join("./covers", coverFileName)
+	return book.BookID, nil
+}
 
-		if err := saveFileToDisk(file, dstPath); err != nil {
-			bs.collection.Model(&models.Book{}).
-				Where("id = ?", bookID).
-				Update("cover_path", nil)
+// CoverKey is the blob store key of a cover file name as stored in
+// cover_path. Covers are served under /covers/ with the same name.
+func CoverKey(name string) string {
+	return "covers/" + filepath.Base(name)
+}
 
-			return bookID, fmt.Errorf("book created but cover upload failed: %w", err)
+// openCover reads and processes an uploaded cover file.
+func openCover(file *multipart.FileHeader) (*processedCover, error) {
+	if file.Size > MaxCoverSize {
+		return nil, ErrCoverTooLarge
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open uploaded file: %w", err)
+	}
+	defer src.Close()
+
+	return processCover(src)
+}
+
+// saveCover stores both variants of the cover under new names, points the
+// book at them and then removes the variants it replaced. New blobs are
+// removed again if the book cannot be updated.
+func (bs *BookServiceImpl) saveCover(bookID uint, cover *processedCover) error {
+	var old models.BookBase
+	if err := bs.collection.Select("id", "cover_path", "cover_thumb_path").First(&old, bookID).Error; err != nil {
+		return fmt.Errorf("failed to find book: %w", err)
+	}
+
+	full, thumb := coverNames(bookID)
+	for name, data := range map[string][]byte{full: cover.Full, thumb: cover.Thumb} {
+		if err := bs.blobs.Put(bs.ctx, CoverKey(name), bytes.NewReader(data), int64(len(data)), "image/jpeg"); err != nil {
+			bs.deleteCovers(full, thumb)
+			return err
 		}
 	}
 
-	return bookID, nil
+	err := bs.collection.Model(&models.Book{}).
+		Where("id = ?", bookID).
+		Updates(map[string]interface{}{"cover_path": full, "cover_thumb_path": thumb}).Error
+	if err != nil {
+		bs.deleteCovers(full, thumb)
+		return fmt.Errorf("failed to update cover_path: %w", err)
+	}
+
+	bs.deleteCovers(string(old.CoverPath), string(old.CoverThumbPath))
+	return nil
+}
+
+func (bs *BookServiceImpl) readCover(name string) ([]byte, error) {
+	rc, err := bs.blobs.Get(bs.ctx, CoverKey(name))
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	return io.ReadAll(rc)
+}
+
+// deleteCovers removes stored cover variants; failures only leave an
+// orphaned blob behind, so they are logged rather than returned.
+func (bs *BookServiceImpl) deleteCovers(names ...string) {
+	for _, name := range names {
+		if name == "" || name == "null" {
+			continue
+		}
+		if err := bs.blobs.Delete(bs.ctx, CoverKey(name)); err != nil {
+			log.Printf("bsi: failed to delete cover %s: %v", name, err)
+		}
+	}
 }
 
 // FindBookByID finds and returns book by its ID.
@@ -92,6 +320,18 @@ func (bs *BookServiceImpl) FindBookByID(bookID uint) (models.GetBook, error) {
 	return result, nil
 }
 
+// FindBookCreatorID returns the ID of the user who created a book.
+func (bs *BookServiceImpl) FindBookCreatorID(bookID uint) (uint, error) {
+	var book models.Book
+
+	err := bs.collection.Select("id", "creator_user_id").First(&book, bookID).Error
+	if err != nil {
+		return 0, fmt.Errorf("bsi: failed to find book: %w", err)
+	}
+
+	return book.CreatorUserID, nil
+}
+
 // FindBooksByCreatorID finds and returns books by the creator's ID.
 func (bs *BookServiceImpl) FindBooksByCreatorID(creatorID uint) ([]models.BookBase, []models.Label, error) {
 	var books []models.BookBase
@@ -138,7 +378,8 @@ func (bs *BookServiceImpl) FindStartedBooks(userID uint) ([]models.BookBase, err
 }
 
 // InsertChapter inserts a new chapter into the database and assigns an order if not set.
-func (bs *BookServiceImpl) InsertChapter(chapter models.Chapter) (uint, error) {
+// The new-chapter draft authorID had of the book is discarded.
+func (bs *BookServiceImpl) InsertChapter(chapter models.Chapter, authorID uint) (uint, error) {
 	// If no order provided, calculate next order for the book
 	if chapter.ChapterOrder == 0 {
 		var count int64
@@ -147,8 +388,14 @@ func (bs *BookServiceImpl) InsertChapter(chapter models.Chapter) (uint, error) {
 		}
 	}
 
-	if err := bs.collection.Create(&chapter).Error; err != nil {
-		return 0, fmt.Errorf("bsi: failed to insert chapter: %w", err)
+	err := bs.collection.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&chapter).Error; err != nil {
+			return fmt.Errorf("bsi: failed to insert chapter: %w", err)
+		}
+		return discardDraft(tx, authorID, chapter.BookID, 0)
+	})
+	if err != nil {
+		return 0, err
 	}
 
 	return chapter.ChapterID, nil
@@ -183,40 +430,68 @@ func (bs *BookServiceImpl) FindBooksChapterByIDs(bookId, chapterId uint) (models
 	return chapterResponse, nil
 }
 
+// bookTables are the tables with rows that belong to a book, deleted before
+// the book itself. Reading sessions stay, they are the reader's history.
+// chapter_revisions has no book_id and goes first, through the chapters.
+var bookTables = []string{"book_labels", "user_favorites", "reading_progress", "chapter_drafts", "chapters"}
+
 // DeleteAll deletes all books.
 func (bs *BookServiceImpl) DeleteAll() error {
-	return bs.collection.Exec("DELETE FROM books").Error
+	return bs.collection.Transaction(func(tx *gorm.DB) error {
+		for _, table := range append(append([]string{"chapter_revisions"}, bookTables...), "books") {
+			if err := tx.Exec("DELETE FROM " + table).Error; err != nil {
+				return fmt.Errorf("bsi: failed to delete %s: %w", table, err)
+			}
+		}
+		return nil
+	})
 }
 
-// DeleteBook delete one book by its ID.
+// DeleteBook delete one book by its ID, with its chapters and their
+// revisions and drafts, labels, favourites and bookmarks.
 func (bs *BookServiceImpl) DeleteBook(bookId uint) error {
-	if err := bs.collection.Unscoped().Delete(&models.Book{}, bookId).Error; err != nil {
-		return fmt.Errorf("bsi: failed to hard delete book: %w", err)
+	var book models.BookBase
+	if err := bs.collection.Select("id", "cover_path", "cover_thumb_path").First(&book, bookId).Error; err != nil {
+		return fmt.Errorf("bsi: failed to find book: %w", err)
 	}
-	os.Remove(filepath.Join("covers", fmt.Sprintf("%d.jpeg", bookId)))
-
-	return nil
-}
 
-// DeleteChapter deletes one chapter by its ID.
-func (bs *BookServiceImpl) DeleteChapter(chapterId string) error {
-	if err := bs.collection.Unscoped().Delete(&models.Chapter{}, chapterId).Error; err != nil {
-		return fmt.Errorf("bsi: failed to hard delete chapter: %w", err)
+	err := bs.collection.Transaction(func(tx *gorm.DB) error {
+		err := tx.Exec("DELETE FROM chapter_revisions WHERE chapter_id IN (SELECT id FROM chapters WHERE book_id = ?)", bookId).Error
+		if err != nil {
+			return fmt.Errorf("bsi: failed to delete chapter_revisions of book: %w", err)
+		}
+		for _, table := range bookTables {
+			if err := tx.Exec("DELETE FROM "+table+" WHERE book_id = ?", bookId).Error; err != nil {
+				return fmt.Errorf("bsi: failed to delete %s of book: %w", table, err)
+			}
+		}
+		if err := tx.Unscoped().Delete(&models.Book{}, bookId).Error; err != nil {
+			return fmt.Errorf("bsi: failed to hard delete book: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
 	}
+	bs.deleteCovers(string(book.CoverPath), string(book.CoverThumbPath))
 
 	return nil
 }
 
-// ListAllBooks finds and returns all books.
-func (bs *BookServiceImpl) ListAllBooks() ([]models.BookBase, error) {
-	var books []models.BookBase
-
-	err := bs.collection.Limit(20).Where("released = ?", true).Find(&books).Error
-	if err != nil {
-		return nil, fmt.Errorf("bsi: failed to find all books: %w", err)
-	}
-
-	return books, nil
+// DeleteChapter deletes one chapter by its ID, with its revisions and drafts.
+func (bs *BookServiceImpl) DeleteChapter(chapterId string) error {
+	return bs.collection.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("chapter_id = ?", chapterId).Delete(&models.ChapterRevision{}).Error; err != nil {
+			return fmt.Errorf("bsi: failed to delete revisions of chapter: %w", err)
+		}
+		if err := tx.Where("chapter_id = ?", chapterId).Delete(&models.ChapterDraft{}).Error; err != nil {
+			return fmt.Errorf("bsi: failed to delete drafts of chapter: %w", err)
+		}
+		if err := tx.Unscoped().Delete(&models.Chapter{}, chapterId).Error; err != nil {
+			return fmt.Errorf("bsi: failed to hard delete chapter: %w", err)
+		}
+		return nil
+	})
 }
 
 // ListAllLabels finds and returns all labels.
@@ -254,14 +529,26 @@ func (bs *BookServiceImpl) ListLastReleased(n int) ([]models.Book, error) {
 
 }
 
-// ReleaseBook sets the release status of a book to true.
-func (bs *BookServiceImpl) ReleaseBook(bookId uint) error {
+// ReleaseBook sets the release status of a book to true. It returns
+// ErrUserNotVerified if publisherID has not confirmed their email address.
+func (bs *BookServiceImpl) ReleaseBook(bookId, publisherID uint) error {
 	var book models.Book
 
 	if err := bs.collection.First(&book, bookId).Error; err != nil {
 		return fmt.Errorf("bsi: book not found: %w", err)
 	}
 
+	// Only users who confirmed their email address may publish
+	if !book.Released {
+		var publisher models.User
+		if err := bs.collection.Select("id", "verified").First(&publisher, publisherID).Error; err != nil {
+			return fmt.Errorf("bsi: publisher not found: %w", err)
+		}
+		if !publisher.Verified {
+			return ErrUserNotVerified
+		}
+	}
+
 	updates := map[string]interface{}{
 		"released": !book.Released,
 	}
@@ -277,6 +564,19 @@ func (bs *BookServiceImpl) ReleaseBook(bookId uint) error {
 	return nil
 }
 
+// UnreleaseBook hides a book from the catalogue regardless of its state.
+func (bs *BookServiceImpl) UnreleaseBook(bookId uint) error {
+	result := bs.collection.Model(&models.Book{}).Where("id = ?", bookId).Update("released", false)
+	if result.Error != nil {
+		return fmt.Errorf("bsi: failed to update book status: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("bsi: book not found: %w", gorm.ErrRecordNotFound)
+	}
+
+	return nil
+}
+
 // UpdateBook find and updates a book's fields.
 func (bs *BookServiceImpl) UpdateBook(bookId uint, file *multipart.FileHeader, input models.Book) (models.Book, error) {
 	var existingBook models.Book
@@ -292,50 +592,18 @@ func (bs *BookServiceImpl) UpdateBook(bookId uint, file *multipart.FileHeader, i
 		"description":      input.Description,
 	}
 
-	// Conditionally add CoverPath ONLY if it was set by the controller (i.e., a file was uploaded)
-	if input.CoverPath != "" {
-		updateData["cover_path"] = input.CoverPath
-	}
-
 	if file != nil {
-		ext := filepath.Ext(file.Filename)
-
-		// Cover name = book ID + extension
-		coverFileName := fmt.Sprintf("%d%s", bookId, ext)
-		targetPath := filepath.Join("covers", coverFileName)
-
-		finalDBURL := models.FormatCoverURL(coverFileName)
-
-		// Creation of 'covers' directory if it doesn't exist
-		if _, statErr := os.Stat("covers"); os.IsNotExist(statErr) {
-			if mkdirErr := os.MkdirAll("covers", os.ModePerm); mkdirErr != nil {
-				return existingBook, fmt.Errorf("failed to create storage directory for cover: %w", mkdirErr)
-			}
-		}
-
-		// Copying the file
-		src, openErr := file.Open()
-		if openErr != nil {
-			return existingBook, fmt.Errorf("failed to open uploaded file: %w", openErr)
-		}
-		defer src.Close()
-
-		dst, createErr := os.Create(targetPath)
-		if createErr != nil {
-			return existingBook, fmt.Errorf("failed to create destination file for cover: %w", createErr)
+		cover, err := openCover(file)
+		if err != nil {
+			return existingBook, err
 		}
-		defer dst.Close()
-
-		if _, copyErr := io.Copy(dst, src); copyErr != nil {
-			return existingBook, fmt.Errorf("failed to save file content: %w", copyErr)
+		if err := bs.saveCover(bookId, cover); err != nil {
+			return existingBook, fmt.Errorf("failed to save cover: %w", err)
 		}
 
-		// Updating the book record with the cover path
-		existingBook.CoverPath = finalDBURL
-		// Saving changes to the database
-		if err := bs.collection.Model(&existingBook).Update("CoverPath", finalDBURL).Error; err != nil {
-			// TODO: In case of an update error delete the saved cover file
-			return existingBook, fmt.Errorf("failed to update book with CoverPath: %w", err)
+		// Reload the cover names saveCover stored
+		if err := bs.collection.Select("cover_path", "cover_thumb_path").First(&existingBook, bookId).Error; err != nil {
+			return existingBook, fmt.Errorf("bsi: failed to reload book: %w", err)
 		}
 	}
 
@@ -346,24 +614,36 @@ func (bs *BookServiceImpl) UpdateBook(bookId uint, file *multipart.FileHeader, i
 	return existingBook, nil
 }
 
-// UpdateChapter find and updates a chapter's fields.
-func (bs *BookServiceImpl) UpdateChapter(chapterId uint, chapter models.Chapter) (models.Chapter, error) {
+// UpdateChapter find and updates a chapter's fields and records the new
+// title and text as a revision by editorID, discarding their draft of it.
+func (bs *BookServiceImpl) UpdateChapter(chapterId uint, chapter models.Chapter, editorID uint) (models.Chapter, error) {
 	var existingChapter models.Chapter
-	if err := bs.collection.First(&existingChapter, chapterId).Error; err != nil {
-		return models.Chapter{}, fmt.Errorf("bsi: chapter with id %d not found: %w", chapterId, err)
-	}
+	err := bs.collection.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&existingChapter, chapterId).Error; err != nil {
+			return fmt.Errorf("bsi: chapter with id %d not found: %w", chapterId, err)
+		}
 
-	updateData := map[string]interface{}{
-		"title": chapter.Title,
-		"text":  chapter.Text,
-	}
+		updateData := map[string]interface{}{
+			"title": chapter.Title,
+			"text":  chapter.Text,
+		}
 
-	if chapter.ChapterOrder != 0 {
-		updateData["chapter_order"] = chapter.ChapterOrder
-	}
+		if chapter.ChapterOrder != 0 {
+			updateData["chapter_order"] = chapter.ChapterOrder
+		}
 
-	if err := bs.collection.Model(&existingChapter).Updates(updateData).Error; err != nil {
-		return models.Chapter{}, fmt.Errorf("bsi: failed to update chapter: %w", err)
+		err := reviseChapter(tx, &existingChapter, updateData, models.ChapterRevision{
+			UserID: &editorID,
+			Title:  chapter.Title,
+			Text:   chapter.Text,
+		})
+		if err != nil {
+			return err
+		}
+		return discardDraft(tx, editorID, existingChapter.BookID, chapterId)
+	})
+	if err != nil {
+		return models.Chapter{}, err
 	}
 
 	return existingChapter, nil
@@ -390,11 +670,124 @@ func (bs *BookServiceImpl) AddLabel(bookId uint, labelIds []uint) error {
 	return nil
 }
 
+// CreateLabel adds a new label.
+func (bs *BookServiceImpl) CreateLabel(name string) (models.Label, error) {
+	label := models.Label{Name: strings.TrimSpace(name)}
+	if label.Name == "" {
+		return label, ErrEmptyLabelName
+	}
+
+	if err := bs.checkLabelName(0, label.Name); err != nil {
+		return label, err
+	}
+
+	if err := bs.collection.Create(&label).Error; err != nil {
+		return label, fmt.Errorf("bsi: failed to create label: %w", err)
+	}
+
+	return label, nil
+}
+
+// RenameLabel changes the name of a label.
+func (bs *BookServiceImpl) RenameLabel(labelId uint, name string) (models.Label, error) {
+	var label models.Label
+	if err := bs.collection.First(&label, labelId).Error; err != nil {
+		return label, fmt.Errorf("bsi: label with id %d not found: %w", labelId, err)
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return label, ErrEmptyLabelName
+	}
+
+	if err := bs.checkLabelName(labelId, name); err != nil {
+		return label, err
+	}
+
+	if err := bs.collection.Model(&label).Update("name", name).Error; err != nil {
+		return label, fmt.Errorf("bsi: failed to rename label: %w", err)
+	}
+
+	return label, nil
+}
+
+// MergeLabels moves every book of the source label to the target label and
+// deletes the source.
+func (bs *BookServiceImpl) MergeLabels(sourceId, targetId uint) error {
+	if sourceId == targetId {
+		return ErrMergeLabelIntoItself
+	}
+
+	return bs.collection.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.Label{}).Where("id IN ?", []uint{sourceId, targetId}).Count(&count).Error; err != nil {
+			return fmt.Errorf("bsi: failed to find labels: %w", err)
+		}
+		if count != 2 {
+			return fmt.Errorf("bsi: labels %d and %d: %w", sourceId, targetId, gorm.ErrRecordNotFound)
+		}
+
+		err := tx.Exec(`INSERT INTO book_labels (book_id, label_id)
+			SELECT book_id, ? FROM book_labels
+			WHERE label_id = ? AND book_id NOT IN (SELECT book_id FROM book_labels WHERE label_id = ?)`,
+			targetId, sourceId, targetId).Error
+		if err != nil {
+			return fmt.Errorf("bsi: failed to move books to label %d: %w", targetId, err)
+		}
+
+		return deleteLabel(tx, sourceId)
+	})
+}
+
+// DeleteLabel removes a label from every book and deletes it.
+func (bs *BookServiceImpl) DeleteLabel(labelId uint) error {
+	return bs.collection.Transaction(func(tx *gorm.DB) error {
+		return deleteLabel(tx, labelId)
+	})
+}
+
+func deleteLabel(tx *gorm.DB, labelId uint) error {
+	if err := tx.Exec("DELETE FROM book_labels WHERE label_id = ?", labelId).Error; err != nil {
+		return fmt.Errorf("bsi: failed to detach label %d: %w", labelId, err)
+	}
+
+	result := tx.Delete(&models.Label{}, labelId)
+	if result.Error != nil {
+		return fmt.Errorf("bsi: failed to delete label: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("bsi: label with id %d: %w", labelId, gorm.ErrRecordNotFound)
+	}
+
+	return nil
+}
+
+// checkLabelName fails if another label already has the name, ignoring case.
+func (bs *BookServiceImpl) checkLabelName(labelId uint, name string) error {
+	var count int64
+	err := bs.collection.Model(&models.Label{}).
+		Where("LOWER(name) = ? AND id <> ?", strings.ToLower(name), labelId).
+		Count(&count).Error
+	if err != nil {
+		return fmt.Errorf("bsi: failed to check label name: %w", err)
+	}
+	if count > 0 {
+		return ErrLabelExists
+	}
+
+	return nil
+}
+
 func searchScope(keyword string, labelIDs []uint) func(db *gorm.DB) *gorm.DB {
 	return func(db *gorm.DB) *gorm.DB {
-		// Search by keyword in book name (case-insensitive)
+		// Full-text search in the book and its chapters on Postgres,
+		// case-insensitive search in the book name elsewhere
 		if keyword != "" {
-			db = db.Where("name ILIKE ?", "%"+keyword+"%")
+			if db.Dialector.Name() == models.DriverPostgres {
+				db = db.Where(searchMatch, sql.Named("q", keyword))
+			} else {
+				db = db.Where("LOWER(name) LIKE ? ESCAPE '!'", likePattern(keyword))
+			}
 		}
 
 		// Filter by labels if labelIDs are provided
@@ -414,60 +807,38 @@ func searchScope(keyword string, labelIDs []uint) func(db *gorm.DB) *gorm.DB {
 	}
 }
 
-// Filter code:
-// 0 - regular
-// 1 - continue reading
-// 2 - created
-// 3 - favourite
-
-func (bs *BookServiceImpl) SearchBooks(keyword string, labelIDs []uint, filterCode string, userID uint) ([]models.BookBase, error) {
-	var books []models.BookBase
-
-	query := bs.collection.Model(&models.BookBase{})
-
-	switch filterCode {
-	case "0":
-		query = query.Where("released = ?", true)
-	case "1":
-		query = query.Joins("JOIN reading_progress ON reading_progress.book_id = books.id").
-			Where("reading_progress.user_id = ?", userID).
-			Distinct("books.*")
-	case "2":
-		query = query.
-			Where("creator_user_id = ?", userID).
-			Distinct("books.*")
-	case "3":
-		query = query.Joins("JOIN user_favorites ON user_favorites.book_id = books.id").
-			Where("user_favorites.user_id = ?", userID).
-			Distinct("books.*")
-	}
-
-	err := query.Scopes(searchScope(keyword, labelIDs)).Find(&books).Error
-
-	if err != nil {
-		return nil, fmt.Errorf("bsi: failed to search books: %w", err)
-	}
-
-	return books, nil
+// likePattern matches values containing keyword in any case. LIKE wildcards in
+// the keyword are escaped with "!". SQLite lowers only ASCII
+// letters, so other scripts match case-sensitively there.
+func likePattern(keyword string) string {
+	escaped := strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(strings.ToLower(keyword))
+	return "%" + escaped + "%"
 }
 
-func saveFileToDisk(file *multipart.FileHeader, dstPath string) error {
-	src, err := file.Open()
-	if err != nil {
-		return fmt.Errorf("failed to open uploaded file: %w", err)
+// guessLanguage picks between the two languages of the catalogue by counting
+// Cyrillic letters; EPUB requires a dc:language.
+func guessLanguage(text string) string {
+	var cyrillic, latin int
+	for _, r := range text {
+		switch {
+		case unicode.Is(unicode.Cyrillic, r):
+			cyrillic++
+		case unicode.Is(unicode.Latin, r):
+			latin++
+		}
 	}
-	defer src.Close()
-
-	dst, err := os.Create(dstPath)
-	if err != nil {
-		return fmt.Errorf("failed to create destination file: %w", err)
+	if cyrillic > latin {
+		return "ru"
 	}
-	defer dst.Close()
+	return "en"
+}
 
-	if _, err = io.Copy(dst, src); err != nil {
-		return fmt.Errorf("failed to save file content: %w", err)
+func lowerAll(values []string) []string {
+	out := make([]string, len(values))
+	for i, v := range values {
+		out[i] = strings.ToLower(v)
 	}
-	return nil
+	return out
 }
 
 func getLabels(bs *BookServiceImpl, ids []uint) ([]models.Label, error) {
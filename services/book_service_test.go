@@ -0,0 +1,718 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"mime/multipart"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/st107853/fast_reading/models"
+	"github.com/st107853/fast_reading/storage"
+	"github.com/st107853/fast_reading/testutil"
+	"gorm.io/gorm"
+)
+
+// setup returns a migrated database with the fixtures.
+func setup(t *testing.T) (*gorm.DB, *testutil.Fixtures) {
+	t.Helper()
+	db := testutil.OpenDB(t)
+	return db, testutil.Seed(t, db)
+}
+
+func newTestBookService(t *testing.T) (*BookServiceImpl, *testutil.Fixtures, storage.BlobStore) {
+	t.Helper()
+	db, f := setup(t)
+	blobs := storage.NewLocalStore(t.TempDir(), "")
+	return NewBookService(db, context.Background(), blobs), f, blobs
+}
+
+// fileHeader returns content as an uploaded multipart file.
+func fileHeader(t *testing.T, name string, content []byte) *multipart.FileHeader {
+	t.Helper()
+
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	part, err := w.CreateFormFile("file", name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write(content)
+	w.Close()
+
+	form, err := multipart.NewReader(&body, w.Boundary()).ReadForm(1 << 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { form.RemoveAll() })
+	return form.File["file"][0]
+}
+
+func pngImage(t *testing.T, width, height int) []byte {
+	t.Helper()
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		img.Set(x, x%height, color.RGBA{R: 200, A: 255})
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestFindBookByID(t *testing.T) {
+	bs, f, _ := newTestBookService(t)
+
+	book, err := bs.FindBookByID(f.Fox.BookID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if book.Name != "The Quick Fox" || book.CreatorUserID != f.Author.ID {
+		t.Errorf("got %q by user %d", book.Name, book.CreatorUserID)
+	}
+	if len(book.Chapters) != 2 || book.Chapters[0].Title != "The Jump" || book.Chapters[1].Title != "The Return" {
+		t.Errorf("chapters are not in reading order: %+v", book.Chapters)
+	}
+	if len(book.BookLabels) != 1 || book.BookLabels[0].Name != "Fiction" {
+		t.Errorf("labels = %+v", book.BookLabels)
+	}
+
+	if _, err := bs.FindBookByID(999); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("missing book: got %v", err)
+	}
+
+	creator, err := bs.FindBookCreatorID(f.Poems.BookID)
+	if err != nil || creator != f.Admin.ID {
+		t.Errorf("FindBookCreatorID = %d, %v", creator, err)
+	}
+}
+
+func TestInsertBookWithCover(t *testing.T) {
+	bs, f, blobs := newTestBookService(t)
+
+	input := models.Book{BookBase: models.BookBase{Name: "Covered", Author: "Someone"}}
+	id, err := bs.InsertBook(input, fileHeader(t, "cover.png", pngImage(t, 800, 1200)), f.Reader.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	book, err := bs.FindBookByID(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if book.CreatorUserID != f.Reader.ID || book.CoverPath == "" || book.CoverThumbPath == "" {
+		t.Fatalf("book = %+v", book.BookBase)
+	}
+	for _, name := range []string{string(book.CoverPath), string(book.CoverThumbPath)} {
+		rc, err := blobs.Get(context.Background(), CoverKey(name))
+		if err != nil {
+			t.Fatalf("cover %s was not stored: %v", name, err)
+		}
+		rc.Close()
+	}
+
+	if err := bs.DeleteBook(id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := blobs.Get(context.Background(), CoverKey(string(book.CoverPath))); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("cover still stored after delete: %v", err)
+	}
+}
+
+func TestInsertBookRejectsInvalidCover(t *testing.T) {
+	bs, f, _ := newTestBookService(t)
+
+	input := models.Book{BookBase: models.BookBase{Name: "Not a picture", Author: "Someone"}}
+	_, err := bs.InsertBook(input, fileHeader(t, "cover.png", []byte("plain text")), f.Reader.ID)
+	if !errors.Is(err, ErrInvalidCover) {
+		t.Fatalf("got %v, want ErrInvalidCover", err)
+	}
+
+	page, err := bs.SearchBooks(models.BookQuery{Keyword: "not a picture"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 0 {
+		t.Error("book was created despite the invalid cover")
+	}
+}
+
+func TestChapters(t *testing.T) {
+	bs, f, _ := newTestBookService(t)
+
+	id, err := bs.InsertChapter(models.Chapter{BookID: f.Fox.BookID, Title: "The End", Text: "It ends."}, f.Author.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	third, err := bs.FindBooksChapterByIDs(f.Fox.BookID, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if third.Chapter.ChapterID != id || third.Name != "The Quick Fox" {
+		t.Errorf("third chapter = %+v of %q", third.Chapter, third.Name)
+	}
+
+	updated, err := bs.UpdateChapter(id, models.Chapter{Title: "The Very End", Text: "It really ends."}, f.Author.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Title != "The Very End" || updated.ChapterOrder != 3 {
+		t.Errorf("updated = %+v", updated)
+	}
+
+	if err := bs.DeleteChapter(strconv.FormatUint(uint64(id), 10)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bs.FindChapterByID(strconv.FormatUint(uint64(id), 10)); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("deleted chapter: got %v", err)
+	}
+}
+
+func TestChapterRevisions(t *testing.T) {
+	bs, f, _ := newTestBookService(t)
+	chapter := f.FoxChapters[0]
+	text := strings.Replace(chapter.Text, "brown", "red", 1)
+
+	if _, err := bs.UpdateChapter(chapter.ChapterID, models.Chapter{Title: chapter.Title, Text: text}, f.Author.ID); err != nil {
+		t.Fatal(err)
+	}
+	// Saving the same content again is not a new revision
+	if _, err := bs.UpdateChapter(chapter.ChapterID, models.Chapter{Title: chapter.Title, Text: text}, f.Author.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	revisions, err := bs.ListChapterRevisions(chapter.ChapterID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revisions) != 2 {
+		t.Fatalf("%d revisions, want the original and the update", len(revisions))
+	}
+	edit, original := revisions[0], revisions[1]
+	if original.UserID != nil || edit.UserName != f.Author.Name || edit.Words != len(strings.Fields(text)) {
+		t.Errorf("revisions = %+v", revisions)
+	}
+
+	diff, err := bs.DiffChapterRevisions(chapter.ChapterID, original.ID, edit.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff.Inserted != 1 || diff.Deleted != 1 || diff.From.ID != original.ID {
+		t.Errorf("diff = %+v", diff)
+	}
+	if _, err := bs.DiffChapterRevisions(f.FoxChapters[1].ChapterID, original.ID, edit.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("diff of another chapter's revisions: got %v", err)
+	}
+
+	restored, err := bs.RestoreChapterRevision(chapter.ChapterID, original.ID, f.Admin.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.Text != chapter.Text {
+		t.Errorf("restored text %q, want %q", restored.Text, chapter.Text)
+	}
+	revisions, err = bs.ListChapterRevisions(chapter.ChapterID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revisions) != 3 || revisions[0].RestoredFrom == nil || *revisions[0].RestoredFrom != original.ID {
+		t.Errorf("revisions after restore = %+v", revisions)
+	}
+
+	// Revisions go with their chapter
+	if err := bs.DeleteBook(f.Fox.BookID); err != nil {
+		t.Fatal(err)
+	}
+	if revisions, _ := bs.ListChapterRevisions(chapter.ChapterID); len(revisions) != 0 {
+		t.Errorf("%d revisions left of a deleted chapter", len(revisions))
+	}
+}
+
+func TestChapterDrafts(t *testing.T) {
+	bs, f, _ := newTestBookService(t)
+	chapter := f.FoxChapters[0]
+
+	if _, err := bs.SaveChapterDraft(f.Author.ID, f.Fox.BookID, chapter.ChapterID, models.DraftInput{Title: chapter.Title, Text: "Half"}); err != nil {
+		t.Fatal(err)
+	}
+	draft, err := bs.SaveChapterDraft(f.Author.ID, f.Fox.BookID, chapter.ChapterID, models.DraftInput{Title: chapter.Title, Text: "Half written"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if draft.Text != "Half written" {
+		t.Errorf("draft = %+v", draft)
+	}
+	if _, err := bs.SaveChapterDraft(f.Author.ID, f.Poems.BookID, chapter.ChapterID, models.DraftInput{}); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("draft of a chapter of another book: got %v", err)
+	}
+	if _, err := bs.FindChapterDraft(f.Admin.ID, f.Fox.BookID, chapter.ChapterID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("draft of another user: got %v", err)
+	}
+
+	// Saving the chapter discards the draft
+	if _, err := bs.UpdateChapter(chapter.ChapterID, models.Chapter{Title: chapter.Title, Text: draft.Text}, f.Author.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bs.FindChapterDraft(f.Author.ID, f.Fox.BookID, chapter.ChapterID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("draft after update: got %v", err)
+	}
+
+	// So does adding the new chapter it was a draft of
+	if _, err := bs.SaveChapterDraft(f.Author.ID, f.Fox.BookID, 0, models.DraftInput{Title: "Epilogue"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bs.InsertChapter(models.Chapter{BookID: f.Fox.BookID, Title: "Epilogue"}, f.Author.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bs.FindChapterDraft(f.Author.ID, f.Fox.BookID, 0); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("new chapter draft after insert: got %v", err)
+	}
+
+	if _, err := bs.SaveChapterDraft(f.Author.ID, f.Fox.BookID, 0, models.DraftInput{Title: "Afterword"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := bs.DiscardChapterDraft(f.Author.ID, f.Fox.BookID, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bs.FindChapterDraft(f.Author.ID, f.Fox.BookID, 0); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("discarded draft: got %v", err)
+	}
+}
+
+func TestDiffWords(t *testing.T) {
+	tests := []struct {
+		from, to          string
+		inserted, deleted int
+	}{
+		{"", "", 0, 0},
+		{"", "one two", 2, 0},
+		{"one two three", "one two three", 0, 0},
+		{"the cat sat on the mat", "the dog sat on a mat", 2, 2},
+		{"a b c a b b a", "c b a b a c", 2, 3},
+		{"first line\nsecond line", "first line\n\nsecond, longer line", 2, 1},
+	}
+	for _, tt := range tests {
+		ops, inserted, deleted := diffWords(tt.from, tt.to)
+
+		var from, to strings.Builder
+		for _, op := range ops {
+			if op.Op != models.DiffInsert {
+				from.WriteString(op.Text)
+			}
+			if op.Op != models.DiffDelete {
+				to.WriteString(op.Text)
+			}
+		}
+		if from.String() != tt.from || to.String() != tt.to {
+			t.Errorf("diffWords(%q, %q) = %+v does not join back into both texts", tt.from, tt.to, ops)
+		}
+		if inserted != tt.inserted || deleted != tt.deleted {
+			t.Errorf("diffWords(%q, %q): %d inserted, %d deleted, want %d and %d", tt.from, tt.to, inserted, deleted, tt.inserted, tt.deleted)
+		}
+	}
+}
+
+func TestReleaseBook(t *testing.T) {
+	bs, f, _ := newTestBookService(t)
+
+	if err := bs.ReleaseBook(f.Draft.BookID, f.Newcomer.ID); !errors.Is(err, ErrUserNotVerified) {
+		t.Fatalf("release by an unverified user: got %v", err)
+	}
+	if err := bs.ReleaseBook(f.Draft.BookID, f.Author.ID); err != nil {
+		t.Fatal(err)
+	}
+	last, err := bs.ListLastReleased(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(last) != 1 || last[0].BookID != f.Draft.BookID {
+		t.Fatalf("last released = %+v, want the draft released just now", last)
+	}
+
+	if err := bs.UnreleaseBook(f.Draft.BookID); err != nil {
+		t.Fatal(err)
+	}
+	if err := bs.UnreleaseBook(999); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("unrelease missing book: got %v", err)
+	}
+
+	page, err := bs.SearchBooks(models.BookQuery{Code: models.FilterReleased})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 2 {
+		t.Errorf("%d released books, want 2", page.Total)
+	}
+}
+
+func TestUpdateBook(t *testing.T) {
+	bs, f, _ := newTestBookService(t)
+
+	input := models.Book{
+		BookBase:        models.BookBase{Name: "The Slow Fox", Author: "Anna Author"},
+		PublicationYear: 2024,
+		Description:     "Slower now.",
+	}
+	if _, err := bs.UpdateBook(f.Fox.BookID, nil, input); err != nil {
+		t.Fatal(err)
+	}
+
+	book, _ := bs.FindBookByID(f.Fox.BookID)
+	if book.Name != "The Slow Fox" || book.PublicationYear != 2024 || book.Description != "Slower now." {
+		t.Errorf("book = %+v", book)
+	}
+
+	if _, err := bs.UpdateBook(999, nil, input); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("update missing book: got %v", err)
+	}
+}
+
+func TestDeleteBook(t *testing.T) {
+	bs, f, _ := newTestBookService(t)
+	db := bs.collection
+
+	// Foreign keys off, as with a DSN that turns them off: the deletes must
+	// not rely on ON DELETE CASCADE
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	db.Exec("PRAGMA foreign_keys = OFF")
+
+	// A labelled book that readers favourited and started, with edited chapters
+	db.Exec("INSERT INTO user_favorites (user_id, book_id) VALUES (?, ?)", f.Reader.ID, f.Fox.BookID)
+	db.Exec("INSERT INTO reading_progress (user_id, book_id, chapter_id, last_index) VALUES (?, ?, 1, 3)", f.Reader.ID, f.Fox.BookID)
+	for _, c := range append(f.FoxChapters, f.PoemsChapter, f.DraftChapter) {
+		if _, err := bs.UpdateChapter(c.ChapterID, models.Chapter{Title: c.Title, Text: c.Text + " The end."}, f.Author.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	revisions := func(chapterIDs ...uint) int64 {
+		var count int64
+		db.Model(&models.ChapterRevision{}).Where("chapter_id IN ?", chapterIDs).Count(&count)
+		return count
+	}
+
+	if err := bs.DeleteBook(f.Fox.BookID); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := bs.FindBookByID(f.Fox.BookID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("deleted book: got %v", err)
+	}
+	for _, table := range []string{"chapters", "book_labels", "user_favorites", "reading_progress"} {
+		var count int64
+		db.Table(table).Where("book_id = ?", f.Fox.BookID).Count(&count)
+		if count != 0 {
+			t.Errorf("%d rows of the deleted book left in %s", count, table)
+		}
+	}
+
+	if n := revisions(f.FoxChapters[0].ChapterID, f.FoxChapters[1].ChapterID); n != 0 {
+		t.Errorf("%d revisions of the deleted book left", n)
+	}
+
+	if err := bs.DeleteBook(f.Fox.BookID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("delete twice: got %v", err)
+	}
+
+	if err := bs.DeleteChapter(strconv.FormatUint(uint64(f.DraftChapter.ChapterID), 10)); err != nil {
+		t.Fatal(err)
+	}
+	if n := revisions(f.DraftChapter.ChapterID); n != 0 {
+		t.Errorf("%d revisions of the deleted chapter left", n)
+	}
+
+	if err := bs.DeleteAll(); err != nil {
+		t.Fatal(err)
+	}
+	page, _ := bs.SearchBooks(models.BookQuery{})
+	if page.Total != 0 {
+		t.Errorf("%d books left after DeleteAll", page.Total)
+	}
+	if n := revisions(f.PoemsChapter.ChapterID); n != 0 {
+		t.Errorf("%d revisions left after DeleteAll", n)
+	}
+}
+
+func TestLabels(t *testing.T) {
+	bs, f, _ := newTestBookService(t)
+
+	drama, err := bs.CreateLabel("  Drama ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if drama.Name != "Drama" {
+		t.Errorf("name = %q, want it trimmed", drama.Name)
+	}
+	if _, err := bs.CreateLabel("fiction"); !errors.Is(err, ErrLabelExists) {
+		t.Errorf("duplicate label: got %v", err)
+	}
+	if _, err := bs.CreateLabel(" "); !errors.Is(err, ErrEmptyLabelName) {
+		t.Errorf("empty label: got %v", err)
+	}
+
+	if _, err := bs.RenameLabel(drama.LabelID, "Poetry"); !errors.Is(err, ErrLabelExists) {
+		t.Errorf("rename to an existing name: got %v", err)
+	}
+	if renamed, err := bs.RenameLabel(drama.LabelID, "Plays"); err != nil || renamed.Name != "Plays" {
+		t.Errorf("rename = %+v, %v", renamed, err)
+	}
+
+	if err := bs.AddLabel(f.Fox.BookID, []uint{f.Fiction.LabelID, drama.LabelID}); err != nil {
+		t.Fatal(err)
+	}
+	if err := bs.AddLabel(f.Poems.BookID, []uint{f.Poetry.LabelID, drama.LabelID}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := bs.MergeLabels(drama.LabelID, drama.LabelID); !errors.Is(err, ErrMergeLabelIntoItself) {
+		t.Errorf("merge into itself: got %v", err)
+	}
+	if err := bs.MergeLabels(drama.LabelID, f.Fiction.LabelID); err != nil {
+		t.Fatal(err)
+	}
+	fox, _ := bs.FindBookByID(f.Fox.BookID)
+	poems, _ := bs.FindBookByID(f.Poems.BookID)
+	if len(fox.BookLabels) != 1 || len(poems.BookLabels) != 2 {
+		t.Errorf("after merge: fox %+v, poems %+v", fox.BookLabels, poems.BookLabels)
+	}
+
+	if err := bs.DeleteLabel(f.Fiction.LabelID); err != nil {
+		t.Fatal(err)
+	}
+	if err := bs.DeleteLabel(f.Fiction.LabelID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("delete twice: got %v", err)
+	}
+	labels, _ := bs.ListAllLabels()
+	if len(labels) != 2 {
+		t.Errorf("%d labels left, want Poetry and Science", len(labels))
+	}
+}
+
+func TestSearchBooks(t *testing.T) {
+	bs, f, _ := newTestBookService(t)
+	db := bs.collection
+	db.Exec("INSERT INTO user_favorites (user_id, book_id) VALUES (?, ?)", f.Reader.ID, f.Poems.BookID)
+	db.Exec("INSERT INTO reading_progress (user_id, book_id, chapter_id, last_index) VALUES (?, ?, 1, 0)", f.Reader.ID, f.Fox.BookID)
+
+	tests := []struct {
+		name  string
+		query models.BookQuery
+		want  []uint
+	}{
+		{"all by name", models.BookQuery{}, []uint{f.Poems.BookID, f.Fox.BookID, f.Draft.BookID}},
+		{"released", models.BookQuery{Code: models.FilterReleased}, []uint{f.Poems.BookID, f.Fox.BookID}},
+		{"keyword", models.BookQuery{Keyword: "QUICK"}, []uint{f.Fox.BookID}},
+		{"label", models.BookQuery{LabelIDs: []uint{f.Poetry.LabelID}}, []uint{f.Poems.BookID}},
+		{"created", models.BookQuery{Code: models.FilterCreated, UserID: f.Author.ID}, []uint{f.Fox.BookID, f.Draft.BookID}},
+		{"favourite", models.BookQuery{Code: models.FilterFavourite, UserID: f.Reader.ID}, []uint{f.Poems.BookID}},
+		{"started", models.BookQuery{Code: models.FilterStarted, UserID: f.Reader.ID}, []uint{f.Fox.BookID}},
+		{"popular", models.BookQuery{Code: models.FilterReleased, Sort: models.SortPopularity}, []uint{f.Poems.BookID, f.Fox.BookID}},
+		{"year ascending", models.BookQuery{Sort: models.SortPublicationYear, Order: "asc"}, []uint{f.Draft.BookID, f.Poems.BookID, f.Fox.BookID}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := bs.SearchBooks(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []uint
+			for _, b := range page.Books {
+				got = append(got, b.BookID)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got books %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("got books %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+
+	if _, err := bs.SearchBooks(models.BookQuery{Sort: "colour"}); !errors.Is(err, ErrInvalidSort) {
+		t.Errorf("unknown sort: got %v", err)
+	}
+	if _, err := bs.SearchBooks(models.BookQuery{Cursor: "nonsense"}); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("bad cursor: got %v", err)
+	}
+}
+
+func TestSearchBooksCursor(t *testing.T) {
+	bs, _, _ := newTestBookService(t)
+
+	var names []string
+	query := models.BookQuery{Sort: models.SortReleaseDate, Limit: 1}
+	for {
+		page, err := bs.SearchBooks(query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, b := range page.Books {
+			names = append(names, b.Name)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		query.Cursor = page.NextCursor
+	}
+
+	if len(names) != 3 || names[0] != "Collected Poems" || names[1] != "The Quick Fox" {
+		t.Errorf("pages = %q, want newest release first and every book once", names)
+	}
+}
+
+func TestSearchText(t *testing.T) {
+	bs, _, _ := newTestBookService(t)
+
+	page, err := bs.SearchText(models.SearchQuery{Query: "fox"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 1 || page.Hits[0].Book.Name != "The Quick Fox" {
+		t.Errorf("hits = %+v", page.Hits)
+	}
+
+	// Unreleased books are never found
+	page, _ = bs.SearchText(models.SearchQuery{Query: "notes"})
+	if page.Total != 0 {
+		t.Errorf("found the draft: %+v", page.Hits)
+	}
+
+	if _, err := bs.SearchText(models.SearchQuery{Query: "  "}); !errors.Is(err, ErrEmptySearch) {
+		t.Errorf("empty query: got %v", err)
+	}
+}
+
+func TestImportText(t *testing.T) {
+	bs, f, _ := newTestBookService(t)
+
+	text := "# Part one\n\nIt begins here.\n\n# Part two\n\nIt *ends* here.\n"
+	id, err := bs.ImportText(models.Book{}, fileHeader(t, "story.md", []byte(text)), SplitOptions{HeadingLevel: 1}, f.Reader.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	book, _ := bs.FindBookByID(id)
+	if book.Name != "story" || book.Author != "Unknown" || book.CreatorUserID != f.Reader.ID {
+		t.Errorf("book = %+v", book.BookBase)
+	}
+	if len(book.Chapters) != 2 || book.Chapters[1].Title != "Part two" || book.Chapters[1].Text != "It ends here." {
+		t.Errorf("chapters = %+v", book.Chapters)
+	}
+
+	if _, err := bs.ImportText(models.Book{}, fileHeader(t, "bad.txt", []byte{0xff, 0xfe, 0x00}), SplitOptions{}, f.Reader.ID); err == nil {
+		t.Error("imported a file that is not UTF-8")
+	}
+}
+
+func TestSplitTextChapterLines(t *testing.T) {
+	text := "Chapter One\n\nIt begins.\n\nBook review\n\nNot a chapter.\n\n" +
+		"Part time\n\nNor this.\n\nГлава семьи\n\nИ не это.\n\nChapter Twenty-Two: The End\n\nIt ends.\n"
+
+	chapters := SplitText(text, SplitOptions{})
+	var titles []string
+	for _, ch := range chapters {
+		titles = append(titles, ch.Title)
+	}
+	if len(titles) != 2 || titles[0] != "Chapter One" || titles[1] != "Chapter Twenty-Two: The End" {
+		t.Errorf("chapter titles = %q", titles)
+	}
+
+	// Lines of a keyword and an ordinary word do not switch to chapter lines
+	text = "Book review\n\nThe plot is thin.\n\nPart time\n\nWork is hard.\n"
+	if chapters := SplitText(text, SplitOptions{}); len(chapters) != 1 || chapters[0].Title != "Part 1" {
+		t.Errorf("chapters = %+v, want one word-count part", chapters)
+	}
+
+	for _, line := range []string{"Chapter XIV", "Part IV: Home", "Book MMXXIV", "chapter 12", "Глава первая", "Chapter Twenty-Two"} {
+		if !isChapterLine(line) {
+			t.Errorf("%q is not taken for a chapter line", line)
+		}
+	}
+	// Words made of the letters of Roman numerals are not numbers
+	for _, line := range []string{"Part civil war", "Book dim", "Chapter mix-up", "Part  civil", "Book DIM", "Chapter IIII", "Part mild"} {
+		if isChapterLine(line) {
+			t.Errorf("%q is taken for a chapter line", line)
+		}
+	}
+}
+
+func TestSplitTextWithoutBlankLines(t *testing.T) {
+	sentence := "The fox runs through the field and over the hill. "
+
+	// One line per sentence, as many hard-wrapped files have
+	lines := strings.Repeat(strings.TrimSpace(sentence)+"\n", 40)
+	if chapters := SplitText(lines, SplitOptions{WordsPerChapter: 100}); len(chapters) < 3 {
+		t.Errorf("%d chapters from 400 words of lines, want at least 3", len(chapters))
+	}
+
+	// The whole book on one line
+	chapters := SplitText(strings.Repeat(sentence, 40), SplitOptions{WordsPerChapter: 100})
+	if len(chapters) < 3 {
+		t.Fatalf("%d chapters from 400 words on one line, want at least 3", len(chapters))
+	}
+	for _, ch := range chapters {
+		if !strings.HasSuffix(ch.Text, "hill.") {
+			t.Errorf("chapter %q was not cut at a sentence end", ch.Title)
+		}
+	}
+}
+
+func TestEpubRoundTrip(t *testing.T) {
+	bs, f, _ := newTestBookService(t)
+
+	book, _ := bs.FindBookByID(f.Fox.BookID)
+	var buf bytes.Buffer
+	if err := bs.ExportEpub(book, &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := bs.ImportEpub(fileHeader(t, "fox.epub", buf.Bytes()), f.Reader.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	imported, _ := bs.FindBookByID(id)
+	if imported.Name != book.Name || imported.Author != book.Author || imported.Description != book.Description {
+		t.Errorf("imported %+v, exported %+v", imported.BookBase, book.BookBase)
+	}
+	if len(imported.Chapters) != 2 || imported.Chapters[0].Title != "The Jump" {
+		t.Errorf("chapters = %+v", imported.Chapters)
+	}
+	if len(imported.BookLabels) != 1 || imported.BookLabels[0].LabelID != f.Fiction.LabelID {
+		t.Errorf("labels = %+v, want the subjects matched to Fiction", imported.BookLabels)
+	}
+}
+
+func TestBooksOfUser(t *testing.T) {
+	bs, f, _ := newTestBookService(t)
+	bs.collection.Exec("INSERT INTO user_favorites (user_id, book_id) VALUES (?, ?)", f.Reader.ID, f.Poems.BookID)
+
+	created, labels, err := bs.FindBooksByCreatorID(f.Author.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(created) != 2 || len(labels) != 1 || labels[0].Name != "Fiction" {
+		t.Errorf("created = %+v, labels = %+v", created, labels)
+	}
+
+	favourite, labels, err := bs.FindFavoriteBooksByUserID(f.Reader.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(favourite) != 1 || favourite[0].BookID != f.Poems.BookID || len(labels) != 1 {
+		t.Errorf("favourite = %+v, labels = %+v", favourite, labels)
+	}
+}
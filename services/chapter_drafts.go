@@ -0,0 +1,63 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/st107853/fast_reading/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SaveChapterDraft stores the editor content of a user, replacing their
+// previous draft of the same chapter. chapterID 0 is a new chapter of the book.
+func (bs *BookServiceImpl) SaveChapterDraft(userID, bookID, chapterID uint, input models.DraftInput) (models.ChapterDraft, error) {
+	if chapterID != 0 {
+		var chapter models.Chapter
+		if err := bs.collection.Select("id").Where("book_id = ?", bookID).First(&chapter, chapterID).Error; err != nil {
+			return models.ChapterDraft{}, fmt.Errorf("bsi: chapter %d of book %d not found: %w", chapterID, bookID, err)
+		}
+	}
+
+	draft := models.ChapterDraft{
+		UserID:    userID,
+		BookID:    bookID,
+		ChapterID: chapterID,
+		Title:     input.Title,
+		Text:      input.Text,
+		UpdatedAt: time.Now().UTC(),
+	}
+
+	err := bs.collection.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "book_id"}, {Name: "chapter_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"title", "text", "updated_at"}),
+	}).Create(&draft).Error
+	if err != nil {
+		return models.ChapterDraft{}, fmt.Errorf("bsi: failed to save draft: %w", err)
+	}
+
+	return bs.FindChapterDraft(userID, bookID, chapterID)
+}
+
+// FindChapterDraft returns the draft a user has of a chapter.
+func (bs *BookServiceImpl) FindChapterDraft(userID, bookID, chapterID uint) (models.ChapterDraft, error) {
+	var draft models.ChapterDraft
+	err := bs.collection.Where("user_id = ? AND book_id = ? AND chapter_id = ?", userID, bookID, chapterID).First(&draft).Error
+	if err != nil {
+		return models.ChapterDraft{}, fmt.Errorf("bsi: draft not found: %w", err)
+	}
+	return draft, nil
+}
+
+// DiscardChapterDraft deletes the draft a user has of a chapter, if any.
+func (bs *BookServiceImpl) DiscardChapterDraft(userID, bookID, chapterID uint) error {
+	return discardDraft(bs.collection, userID, bookID, chapterID)
+}
+
+func discardDraft(tx *gorm.DB, userID, bookID, chapterID uint) error {
+	err := tx.Where("user_id = ? AND book_id = ? AND chapter_id = ?", userID, bookID, chapterID).Delete(&models.ChapterDraft{}).Error
+	if err != nil {
+		return fmt.Errorf("bsi: failed to discard draft: %w", err)
+	}
+	return nil
+}
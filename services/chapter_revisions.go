@@ -0,0 +1,161 @@
+package services
+
+import (
+	"fmt"
+
+	"github.com/st107853/fast_reading/models"
+	"gorm.io/gorm"
+)
+
+// reviseChapter applies updates to chapter and records revision, which holds
+// the new title and text. A chapter edited for the first time also gets a
+// revision with the content it had before, so that one can be restored too.
+// Nothing is recorded when the title and text stay the same.
+func reviseChapter(tx *gorm.DB, chapter *models.Chapter, updates map[string]interface{}, revision models.ChapterRevision) error {
+	changed := revision.Title != chapter.Title || revision.Text != chapter.Text
+
+	if changed {
+		var count int64
+		if err := tx.Model(&models.ChapterRevision{}).Where("chapter_id = ?", chapter.ChapterID).Count(&count).Error; err != nil {
+			return fmt.Errorf("bsi: failed to count revisions: %w", err)
+		}
+		if count == 0 {
+			original := models.ChapterRevision{ChapterID: chapter.ChapterID, Title: chapter.Title, Text: chapter.Text}
+			if err := tx.Create(&original).Error; err != nil {
+				return fmt.Errorf("bsi: failed to save the original chapter: %w", err)
+			}
+		}
+	}
+
+	updates["word_count"] = models.CountWords(revision.Text)
+	if err := tx.Model(chapter).Updates(updates).Error; err != nil {
+		return fmt.Errorf("bsi: failed to update chapter: %w", err)
+	}
+
+	if changed {
+		revision.ChapterID = chapter.ChapterID
+		if err := tx.Create(&revision).Error; err != nil {
+			return fmt.Errorf("bsi: failed to save revision: %w", err)
+		}
+	}
+	return nil
+}
+
+// ListChapterRevisions returns the revisions of a chapter, newest first.
+func (bs *BookServiceImpl) ListChapterRevisions(chapterId uint) ([]models.RevisionSummary, error) {
+	var revisions []models.ChapterRevision
+	err := bs.collection.Where("chapter_id = ?", chapterId).Order("created_at DESC, id DESC").Find(&revisions).Error
+	if err != nil {
+		return nil, fmt.Errorf("bsi: failed to list revisions: %w", err)
+	}
+
+	names, err := bs.editorNames(revisions)
+	if err != nil {
+		return nil, err
+	}
+
+	summaries := make([]models.RevisionSummary, len(revisions))
+	for i, r := range revisions {
+		summaries[i] = summarizeRevision(r, names)
+	}
+	return summaries, nil
+}
+
+// DiffChapterRevisions compares the texts of two revisions of a chapter.
+func (bs *BookServiceImpl) DiffChapterRevisions(chapterId, fromId, toId uint) (models.RevisionDiff, error) {
+	from, err := bs.findRevision(chapterId, fromId)
+	if err != nil {
+		return models.RevisionDiff{}, err
+	}
+	to, err := bs.findRevision(chapterId, toId)
+	if err != nil {
+		return models.RevisionDiff{}, err
+	}
+
+	names, err := bs.editorNames([]models.ChapterRevision{from, to})
+	if err != nil {
+		return models.RevisionDiff{}, err
+	}
+
+	diff := models.RevisionDiff{From: summarizeRevision(from, names), To: summarizeRevision(to, names)}
+	diff.Ops, diff.Inserted, diff.Deleted = diffWords(from.Text, to.Text)
+	return diff, nil
+}
+
+// RestoreChapterRevision brings back the title and text of a revision. The
+// restore is itself recorded as a new revision by userID.
+func (bs *BookServiceImpl) RestoreChapterRevision(chapterId, revisionId, userID uint) (models.Chapter, error) {
+	revision, err := bs.findRevision(chapterId, revisionId)
+	if err != nil {
+		return models.Chapter{}, err
+	}
+
+	var chapter models.Chapter
+	err = bs.collection.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&chapter, chapterId).Error; err != nil {
+			return fmt.Errorf("bsi: chapter with id %d not found: %w", chapterId, err)
+		}
+
+		return reviseChapter(tx, &chapter, map[string]interface{}{
+			"title": revision.Title,
+			"text":  revision.Text,
+		}, models.ChapterRevision{
+			UserID:       &userID,
+			Title:        revision.Title,
+			Text:         revision.Text,
+			RestoredFrom: &revision.ID,
+		})
+	})
+	if err != nil {
+		return models.Chapter{}, err
+	}
+	return chapter, nil
+}
+
+func (bs *BookServiceImpl) findRevision(chapterId, revisionId uint) (models.ChapterRevision, error) {
+	var revision models.ChapterRevision
+	err := bs.collection.Where("chapter_id = ?", chapterId).First(&revision, revisionId).Error
+	if err != nil {
+		return models.ChapterRevision{}, fmt.Errorf("bsi: revision %d of chapter %d not found: %w", revisionId, chapterId, err)
+	}
+	return revision, nil
+}
+
+// editorNames maps the IDs of the users who wrote revisions to their names.
+// Deleted users keep their name.
+func (bs *BookServiceImpl) editorNames(revisions []models.ChapterRevision) (map[uint]string, error) {
+	var ids []uint
+	for _, r := range revisions {
+		if r.UserID != nil {
+			ids = append(ids, *r.UserID)
+		}
+	}
+	names := make(map[uint]string, len(ids))
+	if len(ids) == 0 {
+		return names, nil
+	}
+
+	var users []models.User
+	if err := bs.collection.Unscoped().Select("id", "name").Find(&users, ids).Error; err != nil {
+		return nil, fmt.Errorf("bsi: failed to find revision authors: %w", err)
+	}
+	for _, u := range users {
+		names[u.ID] = u.Name
+	}
+	return names, nil
+}
+
+func summarizeRevision(r models.ChapterRevision, names map[uint]string) models.RevisionSummary {
+	summary := models.RevisionSummary{
+		ID:           r.ID,
+		UserID:       r.UserID,
+		Title:        r.Title,
+		Words:        models.CountWords(r.Text),
+		RestoredFrom: r.RestoredFrom,
+		CreatedAt:    r.CreatedAt,
+	}
+	if r.UserID != nil {
+		summary.UserName = names[*r.UserID]
+	}
+	return summary
+}
@@ -0,0 +1,129 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+const (
+	// MaxCoverSize limits an uploaded cover file.
+	MaxCoverSize = 8 << 20
+	// maxCoverPixels guards against small files that decode to huge images.
+	maxCoverPixels = 40_000_000
+
+	coverQuality = 85
+)
+
+// Cover variants are scaled down to fit these boxes, keeping the aspect ratio.
+var (
+	fullCoverBox  = image.Point{X: 600, Y: 900}
+	thumbCoverBox = image.Point{X: 200, Y: 300}
+)
+
+// coverTypes are the sniffed content types accepted as covers.
+var coverTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+var (
+	ErrCoverTooLarge   = fmt.Errorf("cover must be at most %d MB", MaxCoverSize>>20)
+	ErrInvalidCover    = errors.New("cover must be a JPEG, PNG, GIF or WebP image")
+	ErrCoverDimensions = fmt.Errorf("cover must be at most %d megapixels", maxCoverPixels/1_000_000)
+)
+
+// processedCover holds the JPEG variants of an uploaded cover.
+type processedCover struct {
+	Full  []byte
+	Thumb []byte
+}
+
+// processCover checks that the upload really is an image, whatever its file
+// name says, and re-encodes it into the normalized variants.
+func processCover(r io.Reader) (*processedCover, error) {
+	data, err := io.ReadAll(io.LimitReader(r, MaxCoverSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cover: %w", err)
+	}
+	if len(data) > MaxCoverSize {
+		return nil, ErrCoverTooLarge
+	}
+	if !coverTypes[http.DetectContentType(data)] {
+		return nil, ErrInvalidCover
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrInvalidCover
+	}
+	if config.Width*config.Height > maxCoverPixels {
+		return nil, ErrCoverDimensions
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrInvalidCover
+	}
+
+	full, err := encodeCover(img, fullCoverBox)
+	if err != nil {
+		return nil, err
+	}
+	thumb, err := encodeCover(img, thumbCoverBox)
+	if err != nil {
+		return nil, err
+	}
+
+	return &processedCover{Full: full, Thumb: thumb}, nil
+}
+
+// encodeCover scales the image down to fit box and encodes it as JPEG on a
+// white background, since JPEG has no transparency.
+func encodeCover(img image.Image, box image.Point) ([]byte, error) {
+	size := fitInto(img.Bounds().Size(), box)
+
+	dst := image.NewRGBA(image.Rectangle{Max: size})
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, img.Bounds(), draw.Over, nil)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: coverQuality}); err != nil {
+		return nil, fmt.Errorf("failed to encode cover: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// fitInto returns size scaled down to fit box. Smaller images keep their size.
+func fitInto(size, box image.Point) image.Point {
+	if size.X <= box.X && size.Y <= box.Y {
+		return size
+	}
+
+	if size.X*box.Y > size.Y*box.X {
+		return image.Point{X: box.X, Y: max(1, size.Y*box.X/size.X)}
+	}
+	return image.Point{X: max(1, size.X*box.Y/size.Y), Y: box.Y}
+}
+
+// coverNames returns new file names for the variants of a book cover. The
+// version part changes on every upload, so browsers and CDNs never show a
+// replaced cover from their cache.
+func coverNames(bookID uint) (full, thumb string) {
+	base := fmt.Sprintf("%d-%s", bookID, strconv.FormatInt(time.Now().UnixNano(), 36))
+	return base + ".jpg", base + "_thumb.jpg"
+}
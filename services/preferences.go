@@ -0,0 +1,38 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/st107853/fast_reading/models"
+	"gorm.io/gorm"
+)
+
+// GetPreferences returns the reader settings of a user, or the defaults if
+// they never saved any.
+func (us *UserServiceImpl) GetPreferences(userId uint) (models.UserPreferences, error) {
+	prefs := models.DefaultPreferences()
+	err := us.collection.WithContext(us.ctx).Where("user_id = ?", userId).First(&prefs).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		prefs.UserID = userId
+		return prefs, nil
+	}
+	if err != nil {
+		return prefs, fmt.Errorf("usi: failed to load preferences: %w", err)
+	}
+	return prefs, nil
+}
+
+// UpdatePreferences saves the settings given in input and returns the result.
+func (us *UserServiceImpl) UpdatePreferences(userId uint, input models.PreferencesInput) (models.UserPreferences, error) {
+	prefs, err := us.GetPreferences(userId)
+	if err != nil {
+		return prefs, err
+	}
+
+	input.Apply(&prefs)
+	if err := us.collection.WithContext(us.ctx).Save(&prefs).Error; err != nil {
+		return prefs, fmt.Errorf("usi: failed to save preferences: %w", err)
+	}
+	return prefs, nil
+}
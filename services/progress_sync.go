@@ -0,0 +1,97 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/st107853/fast_reading/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SyncBooksMarks merges bookmarks queued by an offline client into the
+// reading progress. For every book the bookmark with the latest UpdatedAt
+// wins, whether it was stored before or arrives in this batch. Timestamps
+// from clocks running ahead are cut to the current time.
+func (us *UserServiceImpl) SyncBooksMarks(userId uint, marks []models.BookmarkUpdate) (models.SyncResult, error) {
+	result := models.SyncResult{Rejected: []uint{}, Progress: []models.ReadingProgress{}}
+
+	bookIDs := make([]uint, 0, len(marks))
+	seen := make(map[uint]bool, len(marks))
+	for _, m := range marks {
+		if !seen[m.BookID] {
+			seen[m.BookID] = true
+			bookIDs = append(bookIDs, m.BookID)
+		}
+	}
+	if len(bookIDs) == 0 {
+		return result, nil
+	}
+
+	var existing []uint
+	err := us.collection.WithContext(us.ctx).Model(&models.BookBase{}).Where("id IN ?", bookIDs).Pluck("id", &existing).Error
+	if err != nil {
+		return result, fmt.Errorf("usi: failed to find books: %w", err)
+	}
+	exists := make(map[uint]bool, len(existing))
+	for _, id := range existing {
+		exists[id] = true
+	}
+	for _, id := range bookIDs {
+		if !exists[id] {
+			result.Rejected = append(result.Rejected, id)
+		}
+	}
+
+	latest := time.Now().UTC().Add(clockSkew)
+	err = us.collection.WithContext(us.ctx).Transaction(func(tx *gorm.DB) error {
+		for _, m := range marks {
+			if !exists[m.BookID] {
+				continue
+			}
+
+			at := m.UpdatedAt.UTC()
+			if at.After(latest) {
+				at = time.Now().UTC()
+			}
+
+			// Overwrite an older bookmark, or add the first one of the book
+			update := tx.Model(&models.ReadingProgress{}).
+				Where("user_id = ? AND book_id = ?", userId, m.BookID).
+				Where("updated_at IS NULL OR updated_at < ?", at).
+				UpdateColumns(map[string]interface{}{
+					"chapter_id": m.ChapterID,
+					"last_index": m.LastIndex,
+					"updated_at": at,
+				})
+			if update.Error != nil {
+				return update.Error
+			}
+			if update.RowsAffected > 0 {
+				result.Applied++
+				continue
+			}
+
+			insert := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.ReadingProgress{
+				UserID:    userId,
+				BookID:    m.BookID,
+				ChapterID: m.ChapterID,
+				LastIndex: m.LastIndex,
+				UpdatedAt: &at,
+			})
+			if insert.Error != nil {
+				return insert.Error
+			}
+			result.Applied += int(insert.RowsAffected)
+		}
+
+		return tx.Where("user_id = ? AND book_id IN ?", userId, existing).Find(&result.Progress).Error
+	})
+	if err != nil {
+		return result, fmt.Errorf("usi: failed to sync bookmarks: %w", err)
+	}
+
+	sort.Slice(result.Progress, func(i, j int) bool { return result.Progress[i].BookID < result.Progress[j].BookID })
+	return result, nil
+}
@@ -0,0 +1,317 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/st107853/fast_reading/models"
+)
+
+const (
+	defaultStatsDays = 30
+	maxStatsDays     = 365
+
+	// maxSessionLength rejects sessions left running by a forgotten tab.
+	maxSessionLength = 12 * time.Hour
+	// clockSkew is how far in the future a reported session may end.
+	clockSkew = 5 * time.Minute
+
+	dateLayout = "2006-01-02"
+)
+
+var (
+	ErrInvalidSession  = errors.New("session must end after it starts, within 12 hours and not in the future")
+	ErrInvalidTimeZone = errors.New("tz must be an IANA time zone such as Europe/Moscow")
+)
+
+// RecordReadingSession stores a session reported by the reader.
+func (us *UserServiceImpl) RecordReadingSession(userId uint, input models.ReadingSessionInput) (models.ReadingSession, error) {
+	length := input.EndedAt.Sub(input.StartedAt)
+	if length <= 0 || length > maxSessionLength || input.EndedAt.After(time.Now().Add(clockSkew)) {
+		return models.ReadingSession{}, ErrInvalidSession
+	}
+
+	var book models.BookBase
+	if err := us.collection.WithContext(us.ctx).Select("id").First(&book, input.BookID).Error; err != nil {
+		return models.ReadingSession{}, fmt.Errorf("usi: failed to find book: %w", err)
+	}
+
+	session := models.ReadingSession{
+		UserID:    userId,
+		BookID:    input.BookID,
+		ChapterID: input.ChapterID,
+		StartedAt: input.StartedAt.UTC(),
+		EndedAt:   input.EndedAt.UTC(),
+		WordsRead: input.WordsRead,
+		WPM:       input.WPM,
+	}
+	if err := us.collection.WithContext(us.ctx).Create(&session).Error; err != nil {
+		return session, fmt.Errorf("usi: failed to save reading session: %w", err)
+	}
+
+	return session, nil
+}
+
+// GetReadingStats returns the daily reading of the requested period, the
+// reading streaks and how long each started book takes to finish.
+func (us *UserServiceImpl) GetReadingStats(userId uint, query models.StatsQuery) (models.ReadingStats, error) {
+	var stats models.ReadingStats
+
+	days := query.Days
+	if days < 1 {
+		days = defaultStatsDays
+	}
+	if days > maxStatsDays {
+		days = maxStatsDays
+	}
+
+	loc := time.UTC
+	if query.TZ != "" {
+		var err error
+		if loc, err = time.LoadLocation(query.TZ); err != nil {
+			return stats, ErrInvalidTimeZone
+		}
+	}
+
+	now := time.Now().In(loc)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	from := today.AddDate(0, 0, -(days - 1))
+
+	// Only the sessions of the period are loaded in full. Streaks need the
+	// start of every session, and the all-time speed is summed up in SQL.
+	var sessions []models.ReadingSession
+	err := us.collection.WithContext(us.ctx).
+		Where("user_id = ? AND started_at >= ?", userId, from.UTC()).
+		Order("started_at").
+		Find(&sessions).Error
+	if err != nil {
+		return stats, fmt.Errorf("usi: failed to load reading sessions: %w", err)
+	}
+
+	var starts []time.Time
+	err = us.collection.WithContext(us.ctx).
+		Model(&models.ReadingSession{}).
+		Where("user_id = ?", userId).
+		Pluck("started_at", &starts).Error
+	if err != nil {
+		return stats, fmt.Errorf("usi: failed to load reading days: %w", err)
+	}
+
+	stats.From = from.Format(dateLayout)
+	stats.To = today.Format(dateLayout)
+	stats.TimeZone = loc.String()
+	stats.Days = make([]models.DailyReading, days)
+	byDate := make(map[string]*models.DailyReading, days)
+	for i := range stats.Days {
+		stats.Days[i].Date = from.AddDate(0, 0, i).Format(dateLayout)
+		byDate[stats.Days[i].Date] = &stats.Days[i]
+	}
+
+	readOn := make(map[string]bool)
+	for _, start := range starts {
+		readOn[start.In(loc).Format(dateLayout)] = true
+	}
+
+	var weighted float64 // WPM × words over the period
+	for _, s := range sessions {
+		day, ok := byDate[s.StartedAt.In(loc).Format(dateLayout)]
+		if !ok {
+			continue
+		}
+		day.Words += s.WordsRead
+		day.Minutes += s.EndedAt.Sub(s.StartedAt).Minutes()
+		day.AverageWPM += float64(s.WPM * s.WordsRead) // divided by the words below
+		day.MaxWPM = max(day.MaxWPM, s.WPM)
+
+		stats.Sessions++
+		stats.TotalWords += s.WordsRead
+		stats.TotalMinutes += s.EndedAt.Sub(s.StartedAt).Minutes()
+		stats.MaxWPM = max(stats.MaxWPM, s.WPM)
+		weighted += float64(s.WPM * s.WordsRead)
+	}
+
+	for i := range stats.Days {
+		day := &stats.Days[i]
+		if day.Words > 0 {
+			day.AverageWPM = round1(day.AverageWPM / float64(day.Words))
+		}
+		day.Minutes = round1(day.Minutes)
+	}
+	stats.TotalMinutes = round1(stats.TotalMinutes)
+	if stats.TotalWords > 0 {
+		stats.AverageWPM = round1(weighted / float64(stats.TotalWords))
+	}
+
+	stats.CurrentStreak, stats.LongestStreak = readingStreaks(readOn, today)
+
+	// Estimates use the recent speed, or the all-time one after a break
+	speed := stats.AverageWPM
+	if speed == 0 && len(starts) > 0 {
+		if speed, err = us.allTimeWPM(userId); err != nil {
+			return stats, err
+		}
+	}
+	if stats.Books, err = us.bookEstimates(userId, speed); err != nil {
+		return stats, err
+	}
+
+	return stats, nil
+}
+
+// readingStreaks counts consecutive reading days. The current streak is
+// still alive when the user has not read yet today but did yesterday.
+func readingStreaks(readOn map[string]bool, today time.Time) (current, longest int) {
+	day := today
+	if !readOn[day.Format(dateLayout)] {
+		day = day.AddDate(0, 0, -1)
+	}
+	for readOn[day.Format(dateLayout)] {
+		current++
+		day = day.AddDate(0, 0, -1)
+	}
+
+	dates := make([]string, 0, len(readOn))
+	for date := range readOn {
+		dates = append(dates, date)
+	}
+	sort.Strings(dates)
+
+	run := 0
+	var prev time.Time
+	for i, date := range dates {
+		d, _ := time.Parse(dateLayout, date)
+		if i > 0 && d.Equal(prev.AddDate(0, 0, 1)) {
+			run++
+		} else {
+			run = 1
+		}
+		longest = max(longest, run)
+		prev = d
+	}
+
+	return current, longest
+}
+
+// allTimeWPM returns the reading speed of all sessions of the user, weighted
+// by the words read at each speed.
+func (us *UserServiceImpl) allTimeWPM(userId uint) (float64, error) {
+	var total struct {
+		Weighted float64
+		Words    float64
+	}
+	err := us.collection.WithContext(us.ctx).
+		Model(&models.ReadingSession{}).
+		Select("COALESCE(SUM(wpm * words_read), 0) AS weighted, COALESCE(SUM(words_read), 0) AS words").
+		Where("user_id = ?", userId).
+		Scan(&total).Error
+	if err != nil {
+		return 0, fmt.Errorf("usi: failed to sum reading sessions: %w", err)
+	}
+	if total.Words == 0 {
+		return 0, nil
+	}
+	return total.Weighted / total.Words, nil
+}
+
+// bookEstimates measures every started book in words and how many of them
+// are behind the reading mark.
+func (us *UserServiceImpl) bookEstimates(userId uint, wpm float64) ([]models.BookEstimate, error) {
+	estimates := []models.BookEstimate{}
+
+	var marks []models.ReadingProgress
+	if err := us.collection.WithContext(us.ctx).Where("user_id = ?", userId).Find(&marks).Error; err != nil {
+		return estimates, fmt.Errorf("usi: failed to load reading progress: %w", err)
+	}
+	if len(marks) == 0 {
+		return estimates, nil
+	}
+
+	ids := make([]uint, len(marks))
+	for i, mark := range marks {
+		ids[i] = mark.BookID
+	}
+
+	var books []models.BookBase
+	if err := us.collection.WithContext(us.ctx).Where("id IN ?", ids).Order("name").Find(&books).Error; err != nil {
+		return estimates, fmt.Errorf("usi: failed to load started books: %w", err)
+	}
+
+	chapters, err := us.chapterWordCounts(ids)
+	if err != nil {
+		return estimates, err
+	}
+
+	markOf := make(map[uint]models.ReadingProgress, len(marks))
+	for _, mark := range marks {
+		markOf[mark.BookID] = mark
+	}
+
+	total := make(map[uint]int)
+	read := make(map[uint]int)
+	for _, ch := range chapters {
+		words := *ch.WordCount
+		total[ch.BookID] += words
+
+		mark := markOf[ch.BookID]
+		switch {
+		case uint(ch.ChapterOrder) < mark.ChapterID:
+			read[ch.BookID] += words
+		case uint(ch.ChapterOrder) == mark.ChapterID:
+			read[ch.BookID] += min(words, int(mark.LastIndex))
+		}
+	}
+
+	for _, book := range books {
+		e := models.BookEstimate{Book: book, TotalWords: total[book.BookID], WordsRead: read[book.BookID]}
+		if e.TotalWords > 0 {
+			e.Percent = round1(100 * float64(e.WordsRead) / float64(e.TotalWords))
+		}
+		if wpm > 0 {
+			e.MinutesLeft = round1(float64(e.TotalWords-e.WordsRead) / wpm)
+		}
+		estimates = append(estimates, e)
+	}
+
+	return estimates, nil
+}
+
+// chapterWordCounts returns the chapters of the books with their word count
+// but not their text. Chapters written before word counts were stored are
+// counted once here and keep the count.
+func (us *UserServiceImpl) chapterWordCounts(bookIDs []uint) ([]models.Chapter, error) {
+	var chapters []models.Chapter
+	err := us.collection.WithContext(us.ctx).
+		Select("id", "book_id", "chapter_order", "word_count").
+		Where("book_id IN ?", bookIDs).
+		Find(&chapters).Error
+	if err != nil {
+		return nil, fmt.Errorf("usi: failed to load chapters: %w", err)
+	}
+
+	for i := range chapters {
+		ch := &chapters[i]
+		if ch.WordCount != nil {
+			continue
+		}
+
+		var text string
+		err := us.collection.WithContext(us.ctx).Model(&models.Chapter{}).Where("id = ?", ch.ChapterID).Pluck("text", &text).Error
+		if err != nil {
+			return nil, fmt.Errorf("usi: failed to count chapter words: %w", err)
+		}
+		words := models.CountWords(text)
+		ch.WordCount = &words
+		if err := us.collection.WithContext(us.ctx).Model(&models.Chapter{}).Where("id = ?", ch.ChapterID).Update("word_count", words).Error; err != nil {
+			return nil, fmt.Errorf("usi: failed to save chapter word count: %w", err)
+		}
+	}
+
+	return chapters, nil
+}
+
+func round1(v float64) float64 {
+	return math.Round(v*10) / 10
+}
@@ -0,0 +1,285 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/st107853/fast_reading/models"
+)
+
+// SplitMode selects how a plain-text book is broken into chapters.
+type SplitMode string
+
+const (
+	// SplitAuto uses Markdown headings for .md files, "Chapter N" lines when
+	// the text has at least two of them, and a word count otherwise.
+	SplitAuto SplitMode = ""
+	// SplitMarkdown starts a chapter at every heading up to HeadingLevel.
+	SplitMarkdown SplitMode = "markdown"
+	// SplitChapterLines starts a chapter at lines like "Chapter 12" or "Глава XII".
+	SplitChapterLines SplitMode = "chapter"
+	// SplitWordCount cuts a chapter at the first paragraph end after
+	// WordsPerChapter words, or at a sentence or line end without blank lines.
+	SplitWordCount SplitMode = "words"
+)
+
+const (
+	defaultHeadingLevel    = 2
+	defaultWordsPerChapter = 3000
+
+	// maxTextImportSize limits a single uploaded .txt or .md file.
+	maxTextImportSize = 16 << 20
+)
+
+// SplitOptions configure SplitText.
+type SplitOptions struct {
+	Mode            SplitMode
+	HeadingLevel    int
+	WordsPerChapter int
+	// Markdown strips inline Markdown markup from chapter text.
+	Markdown bool
+}
+
+// numberWord matches a number written as a word, as in "Chapter Twelve" or
+// "Глава первая". Other words after "Chapter" do not make a chapter line, so
+// "Book review" or "Глава семьи" stay in the text.
+const numberWord = `(one|two|three|four|five|six|seven|eight|nine|ten|eleven|twelve|` +
+	`(thir|four|fif|six|seven|eigh|nine)teen|twenty|thirty|forty|fifty|sixty|seventy|eighty|ninety|` +
+	`first|second|third|fourth|fifth|sixth|seventh|eighth|ninth|tenth|eleventh|twelfth|` +
+	`(thir|four|fif|six|seven|eigh|nine)teenth|(twen|thir|for|fif|six|seven|eigh|nine)tieth|` +
+	`один|одна|два|две|три|четыре|пять|шесть|семь|восемь|девять|десять|` +
+	`(перв|втор|трет|четв[её]рт|пят|шест|седьм|восьм|девят|десят|` +
+	`(один|две|три|четыр|пят|шест|сем|восем|девят)надцат|двадцат|тридцат)(ая|ый|ой|ое|ий|ья|ье))`
+
+// romanNumeral matches a Roman numeral up to 3999. It is case-sensitive so
+// that words such as "civil" or "mix" are not taken for numbers, and it also
+// matches the empty string, which isChapterLine rules out.
+const romanNumeral = `M{0,3}(?:CM|CD|D?C{0,3})(?:XC|XL|L?X{0,3})(?:IX|IV|V?I{0,3})`
+
+var (
+	markdownHeading = regexp.MustCompile(`^(#{1,6})\s+(.+?)\s*#*\s*$`)
+	chapterLine     = regexp.MustCompile(`^\s*(?i:chapter|part|book|глава|часть|книга)\s+(\d+|` + romanNumeral + `|(?i:` + numberWord + `(-` + numberWord + `)?))([\s.:\-–—].*)?$`)
+	sentenceEnd     = regexp.MustCompile(`[.!?…]+["'»”’)\]]*(\s+|$)`)
+	endsSentence    = regexp.MustCompile(`[.!?…]+["'»”’)\]]*\s*$`)
+
+	mdImage    = regexp.MustCompile(`!\[[^\]]*\]\([^)]*\)`)
+	mdLink     = regexp.MustCompile(`\[([^\]]*)\]\([^)]*\)`)
+	mdEmphasis = regexp.MustCompile("(\\*\\*|__|\\*|_|`)([^*_`]+)(\\*\\*|__|\\*|_|`)")
+	mdListItem = regexp.MustCompile(`^\s*([-*+]|\d+\.)\s+`)
+)
+
+// SplitText breaks a plain-text or Markdown book into chapters in reading
+// order. ChapterOrder is left unset; it is assigned when the chapters are
+// inserted.
+func SplitText(text string, opts SplitOptions) []models.Chapter {
+	text = strings.TrimPrefix(text, "\ufeff")
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	lines := strings.Split(text, "\n")
+
+	if opts.HeadingLevel <= 0 {
+		opts.HeadingLevel = defaultHeadingLevel
+	}
+	if opts.WordsPerChapter <= 0 {
+		opts.WordsPerChapter = defaultWordsPerChapter
+	}
+
+	mode := opts.Mode
+	if mode == SplitAuto {
+		mode = detectSplitMode(lines, opts)
+	}
+
+	var chapters []models.Chapter
+	switch mode {
+	case SplitMarkdown:
+		chapters = splitOnHeadings(lines, func(line string) (string, bool) {
+			m := markdownHeading.FindStringSubmatch(line)
+			if m == nil || len(m[1]) > opts.HeadingLevel {
+				return "", false
+			}
+			return m[2], true
+		})
+	case SplitChapterLines:
+		chapters = splitOnHeadings(lines, func(line string) (string, bool) {
+			if len([]rune(line)) > 80 || !isChapterLine(line) {
+				return "", false
+			}
+			return strings.TrimSpace(line), true
+		})
+	default:
+		chapters = splitOnWordCount(lines, opts.WordsPerChapter)
+	}
+
+	var out []models.Chapter
+	for _, ch := range chapters {
+		if opts.Markdown {
+			ch.Title = stripMarkdown(ch.Title)
+			ch.Text = stripMarkdown(ch.Text)
+		}
+		ch.Text = strings.TrimSpace(ch.Text)
+		if ch.Text == "" {
+			continue
+		}
+		out = append(out, ch)
+	}
+
+	return out
+}
+
+// isChapterLine reports whether line is a chapter keyword followed by a
+// number, such as "Chapter 3", "Part IV: Home" or "Глава первая".
+func isChapterLine(line string) bool {
+	m := chapterLine.FindStringSubmatch(line)
+	return m != nil && m[1] != ""
+}
+
+func detectSplitMode(lines []string, opts SplitOptions) SplitMode {
+	var headings, chapterLines int
+	for _, line := range lines {
+		if m := markdownHeading.FindStringSubmatch(line); m != nil && len(m[1]) <= opts.HeadingLevel {
+			headings++
+		}
+		if len([]rune(line)) <= 80 && isChapterLine(line) {
+			chapterLines++
+		}
+	}
+
+	switch {
+	case opts.Markdown && headings > 0:
+		return SplitMarkdown
+	case chapterLines >= 2:
+		return SplitChapterLines
+	default:
+		return SplitWordCount
+	}
+}
+
+// splitOnHeadings starts a new chapter at every line accepted by isHeading.
+// Text before the first heading becomes a "Preface" chapter.
+func splitOnHeadings(lines []string, isHeading func(string) (string, bool)) []models.Chapter {
+	var (
+		chapters []models.Chapter
+		body     []string
+		title    = "Preface"
+	)
+
+	flush := func() {
+		chapters = append(chapters, models.Chapter{Title: title, Text: joinParagraphs(body)})
+		body = nil
+	}
+
+	for _, line := range lines {
+		if heading, ok := isHeading(line); ok {
+			flush()
+			title = heading
+			continue
+		}
+		body = append(body, line)
+	}
+	flush()
+
+	return chapters
+}
+
+// splitOnWordCount cuts chapters at paragraph boundaries once they reach
+// wordsPerChapter words. Text without blank lines in reach is cut at the end
+// of a sentence instead, and failing that at the end of a line; lines too
+// long for one chapter are broken up at their sentences.
+func splitOnWordCount(lines []string, wordsPerChapter int) []models.Chapter {
+	var (
+		chapters []models.Chapter
+		body     []string
+		words    int
+	)
+
+	flush := func() {
+		chapters = append(chapters, models.Chapter{
+			Title: fmt.Sprintf("Part %d", len(chapters)+1),
+			Text:  joinParagraphs(body),
+		})
+		body = nil
+		words = 0
+	}
+
+	for _, line := range lines {
+		for i, part := range splitLongLine(line, wordsPerChapter) {
+			// The rest of a broken up line stays on the same line
+			if i > 0 && len(body) > 0 {
+				body[len(body)-1] += " " + part
+			} else {
+				body = append(body, part)
+			}
+			words += len(strings.Fields(part))
+
+			switch {
+			case words >= wordsPerChapter && strings.TrimSpace(part) == "",
+				words >= wordsPerChapter*5/4 && endsSentence.MatchString(part),
+				words >= wordsPerChapter*3/2:
+				flush()
+			}
+		}
+	}
+	if words > 0 {
+		flush()
+	}
+
+	return chapters
+}
+
+// splitLongLine breaks a line of more than limit words after each sentence.
+func splitLongLine(line string, limit int) []string {
+	if len(strings.Fields(line)) <= limit {
+		return []string{line}
+	}
+
+	var parts []string
+	start := 0
+	for _, end := range sentenceEnd.FindAllStringIndex(line, -1) {
+		parts = append(parts, strings.TrimSpace(line[start:end[1]]))
+		start = end[1]
+	}
+	if rest := strings.TrimSpace(line[start:]); rest != "" {
+		parts = append(parts, rest)
+	}
+	return parts
+}
+
+// joinParagraphs normalises blank-line separated paragraphs.
+func joinParagraphs(lines []string) string {
+	var (
+		paragraphs []string
+		current    []string
+	)
+
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			if len(current) > 0 {
+				paragraphs = append(paragraphs, strings.Join(current, "\n"))
+				current = nil
+			}
+			continue
+		}
+		current = append(current, strings.TrimRight(line, " \t"))
+	}
+	if len(current) > 0 {
+		paragraphs = append(paragraphs, strings.Join(current, "\n"))
+	}
+
+	return strings.Join(paragraphs, "\n\n")
+}
+
+func stripMarkdown(text string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if m := markdownHeading.FindStringSubmatch(line); m != nil {
+			line = m[2]
+		}
+		line = strings.TrimLeft(line, "> ")
+		line = mdListItem.ReplaceAllString(line, "")
+		line = mdImage.ReplaceAllString(line, "")
+		line = mdLink.ReplaceAllString(line, "$1")
+		line = mdEmphasis.ReplaceAllString(line, "$2")
+		lines[i] = line
+	}
+	return strings.Join(lines, "\n")
+}
@@ -1,14 +1,29 @@
 package services
 
 import (
+	"errors"
+
 	"github.com/st107853/fast_reading/models"
 )
 
+// ErrInvalidRole is returned when an admin assigns an unknown role.
+var ErrInvalidRole = errors.New("role must be user or admin")
+
 type UserService interface {
 	FindUserById(uint) (*models.User, error)
 	FindUserByEmail(string) (*models.User, error)
+	HasActiveSession(userId uint, familyID string) (bool, error)
 	AddBookToFavoriteBooks(id, bookId uint) error
 	GetBooksMark(userId, bookId uint) *models.ReadingProgress
 	SaveBooksMark(userId, bookId, chapterId, lastIndex uint) error
+	SyncBooksMarks(userId uint, marks []models.BookmarkUpdate) (models.SyncResult, error)
 	IsBookFavorited(userId, bookId uint) (bool, error)
+	ListUsers(query models.UserQuery) (models.UserPage, error)
+	SetUserRole(userId uint, role string) error
+	SetUserVerified(userId uint, verified bool) error
+	SetUserBanned(userId uint, banned bool) error
+	RecordReadingSession(userId uint, input models.ReadingSessionInput) (models.ReadingSession, error)
+	GetReadingStats(userId uint, query models.StatsQuery) (models.ReadingStats, error)
+	GetPreferences(userId uint) (models.UserPreferences, error)
+	UpdatePreferences(userId uint, input models.PreferencesInput) (models.UserPreferences, error)
 }
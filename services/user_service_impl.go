@@ -2,6 +2,9 @@ package services
 
 import (
 	"context"
+	"fmt"
+	"strings"
+	"time"
 
 	"github.com/st107853/fast_reading/models"
 	"gorm.io/gorm"
@@ -25,6 +28,20 @@ func (us *UserServiceImpl) FindUserById(id uint) (*models.User, error) {
 	return user, nil
 }
 
+// HasActiveSession reports whether the device session familyID of the user
+// is still logged in: not revoked by logout, a password reset or a refresh
+// token reuse, and not expired.
+func (us *UserServiceImpl) HasActiveSession(userId uint, familyID string) (bool, error) {
+	var count int64
+	err := us.collection.WithContext(us.ctx).Model(&models.Session{}).
+		Where("user_id = ? AND family_id = ? AND revoked_at IS NULL AND expires_at > ?", userId, familyID, time.Now()).
+		Count(&count).Error
+	if err != nil {
+		return false, fmt.Errorf("usi: failed to find session: %w", err)
+	}
+	return count > 0, nil
+}
+
 func (us *UserServiceImpl) FindUserByEmail(email string) (*models.User, error) {
 	var user *models.User
 	if err := us.collection.WithContext(us.ctx).Where("email = ?", email).First(&user).Error; err != nil {
@@ -61,16 +78,18 @@ func (us *UserServiceImpl) AddBookToFavoriteBooks(id, bookId uint) error {
 }
 
 func (us *UserServiceImpl) SaveBooksMark(userId uint, bookId uint, chapterID uint, lastIndex uint) error {
+	now := time.Now().UTC()
 	progress := models.ReadingProgress{
 		UserID:    userId,
 		BookID:    bookId,
 		ChapterID: chapterID,
 		LastIndex: lastIndex,
+		UpdatedAt: &now,
 	}
 
 	return us.collection.WithContext(us.ctx).Clauses(clause.OnConflict{
 		Columns:   []clause.Column{{Name: "user_id"}, {Name: "book_id"}},
-		DoUpdates: clause.AssignmentColumns([]string{"chapter_id", "last_index"}),
+		DoUpdates: clause.AssignmentColumns([]string{"chapter_id", "last_index", "updated_at"}),
 	}).Create(&progress).Error
 }
 
@@ -90,3 +109,94 @@ func (us *UserServiceImpl) IsBookFavorited(userID uint, bookId uint) (bool, erro
 	count := us.collection.Model(&user).Where("id = ?", bookId).Association("FavoriteBooks").Count()
 	return count > 0, nil
 }
+
+const (
+	defaultUsersPerPage = 20
+	maxUsersPerPage     = 100
+)
+
+// ListUsers returns a page of users, newest first, optionally filtered by a
+// name or email substring.
+func (us *UserServiceImpl) ListUsers(query models.UserQuery) (models.UserPage, error) {
+	page := models.UserPage{Page: query.Page, PerPage: query.PerPage}
+	if page.Page < 1 {
+		page.Page = 1
+	}
+	if page.PerPage < 1 {
+		page.PerPage = defaultUsersPerPage
+	}
+	if page.PerPage > maxUsersPerPage {
+		page.PerPage = maxUsersPerPage
+	}
+
+	db := us.collection.WithContext(us.ctx).Model(&models.User{})
+	if search := strings.TrimSpace(query.Search); search != "" {
+		pattern := likePattern(search)
+		db = db.Where("LOWER(name) LIKE ? ESCAPE '!' OR LOWER(email) LIKE ? ESCAPE '!'", pattern, pattern)
+	}
+	db = db.Session(&gorm.Session{})
+
+	if err := db.Count(&page.Total).Error; err != nil {
+		return page, fmt.Errorf("usi: failed to count users: %w", err)
+	}
+
+	page.Users = []models.UserSummary{}
+	err := db.Order("created_at DESC, id DESC").
+		Offset((page.Page - 1) * page.PerPage).
+		Limit(page.PerPage).
+		Find(&page.Users).Error
+	if err != nil {
+		return page, fmt.Errorf("usi: failed to list users: %w", err)
+	}
+
+	return page, nil
+}
+
+// SetUserRole changes the role of a user.
+func (us *UserServiceImpl) SetUserRole(userId uint, role string) error {
+	if role != models.RoleUser && role != models.RoleAdmin {
+		return ErrInvalidRole
+	}
+
+	return us.updateUser(userId, "role", role)
+}
+
+// SetUserVerified marks the email of a user as verified or not.
+func (us *UserServiceImpl) SetUserVerified(userId uint, verified bool) error {
+	return us.updateUser(userId, "verified", verified)
+}
+
+// SetUserBanned suspends or restores an account. Banning also revokes every
+// session of the user, so they cannot refresh or log back in; DeserializeUser
+// rejects their access tokens right away.
+func (us *UserServiceImpl) SetUserBanned(userId uint, banned bool) error {
+	if !banned {
+		return us.updateUser(userId, "banned_at", nil)
+	}
+
+	now := time.Now()
+	if err := us.updateUser(userId, "banned_at", now); err != nil {
+		return err
+	}
+
+	err := us.collection.WithContext(us.ctx).Model(&models.Session{}).
+		Where("user_id = ? AND revoked_at IS NULL", userId).
+		Update("revoked_at", now).Error
+	if err != nil {
+		return fmt.Errorf("usi: failed to revoke sessions: %w", err)
+	}
+
+	return nil
+}
+
+func (us *UserServiceImpl) updateUser(userId uint, column string, value interface{}) error {
+	result := us.collection.WithContext(us.ctx).Model(&models.User{}).Where("id = ?", userId).Update(column, value)
+	if result.Error != nil {
+		return fmt.Errorf("usi: failed to update user: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("usi: user %d: %w", userId, gorm.ErrRecordNotFound)
+	}
+
+	return nil
+}
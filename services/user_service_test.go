@@ -0,0 +1,266 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/st107853/fast_reading/models"
+	"github.com/st107853/fast_reading/testutil"
+	"gorm.io/gorm"
+)
+
+func newTestUserService(t *testing.T) (UserService, *testutil.Fixtures, *gorm.DB) {
+	t.Helper()
+	db, f := setup(t)
+	return NewUserServiceImpl(db, context.Background()), f, db
+}
+
+func TestFindUser(t *testing.T) {
+	us, f, _ := newTestUserService(t)
+
+	user, err := us.FindUserById(f.Author.ID)
+	if err != nil || user.Email != "author@example.com" {
+		t.Errorf("FindUserById = %+v, %v", user, err)
+	}
+	user, err = us.FindUserByEmail("reader@example.com")
+	if err != nil || user.ID != f.Reader.ID {
+		t.Errorf("FindUserByEmail = %+v, %v", user, err)
+	}
+	if _, err := us.FindUserById(999); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("missing user: got %v", err)
+	}
+}
+
+func TestFavouriteToggles(t *testing.T) {
+	us, f, _ := newTestUserService(t)
+
+	for i, want := range []bool{true, false, true} {
+		if err := us.AddBookToFavoriteBooks(f.Reader.ID, f.Fox.BookID); err != nil {
+			t.Fatal(err)
+		}
+		got, err := us.IsBookFavorited(f.Reader.ID, f.Fox.BookID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Fatalf("after %d toggles favourited = %v, want %v", i+1, got, want)
+		}
+	}
+
+	if got, _ := us.IsBookFavorited(f.Reader.ID, f.Poems.BookID); got {
+		t.Error("a book the reader never favourited is favourited")
+	}
+}
+
+func TestBooksMarks(t *testing.T) {
+	us, f, _ := newTestUserService(t)
+
+	if mark := us.GetBooksMark(f.Reader.ID, f.Fox.BookID); mark.LastIndex != 0 {
+		t.Errorf("unread book has mark %+v", mark)
+	}
+
+	us.SaveBooksMark(f.Reader.ID, f.Fox.BookID, 1, 7)
+	if err := us.SaveBooksMark(f.Reader.ID, f.Fox.BookID, 2, 3); err != nil {
+		t.Fatal(err)
+	}
+	mark := us.GetBooksMark(f.Reader.ID, f.Fox.BookID)
+	if mark.ChapterID != 2 || mark.LastIndex != 3 || mark.UpdatedAt == nil {
+		t.Errorf("mark = %+v, want the second save", mark)
+	}
+}
+
+func TestSyncBooksMarks(t *testing.T) {
+	us, f, _ := newTestUserService(t)
+
+	if err := us.SaveBooksMark(f.Reader.ID, f.Fox.BookID, 2, 10); err != nil {
+		t.Fatal(err)
+	}
+
+	earlier := time.Now().Add(-time.Hour)
+	result, err := us.SyncBooksMarks(f.Reader.ID, []models.BookmarkUpdate{
+		{BookID: f.Fox.BookID, ChapterID: 1, LastIndex: 1, UpdatedAt: earlier},
+		{BookID: f.Poems.BookID, ChapterID: 1, LastIndex: 4, UpdatedAt: earlier},
+		{BookID: 999, ChapterID: 1, LastIndex: 4, UpdatedAt: earlier},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Applied != 1 {
+		t.Errorf("applied %d bookmarks, want only the Poems one", result.Applied)
+	}
+	if len(result.Rejected) != 1 || result.Rejected[0] != 999 {
+		t.Errorf("rejected = %v, want the unknown book", result.Rejected)
+	}
+	if mark := us.GetBooksMark(f.Reader.ID, f.Fox.BookID); mark.ChapterID != 2 {
+		t.Errorf("an older bookmark replaced a newer one: %+v", mark)
+	}
+	if len(result.Progress) != 2 {
+		t.Errorf("progress = %+v", result.Progress)
+	}
+}
+
+func TestListUsers(t *testing.T) {
+	us, _, _ := newTestUserService(t)
+
+	page, err := us.ListUsers(models.UserQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 4 || len(page.Users) != 4 || page.Page != 1 {
+		t.Errorf("page = %+v", page)
+	}
+
+	page, _ = us.ListUsers(models.UserQuery{Search: "READER"})
+	if page.Total != 1 || page.Users[0].Name != "Roman Reader" {
+		t.Errorf("search = %+v", page.Users)
+	}
+
+	// LIKE wildcards in the search are taken literally
+	for _, search := range []string{"%", "_", "r_a"} {
+		if page, _ = us.ListUsers(models.UserQuery{Search: search}); page.Total != 0 {
+			t.Errorf("search %q matched %d users", search, page.Total)
+		}
+	}
+
+	page, _ = us.ListUsers(models.UserQuery{Page: 2, PerPage: 3})
+	if len(page.Users) != 1 {
+		t.Errorf("second page has %d users, want 1", len(page.Users))
+	}
+}
+
+func TestAdminUserChanges(t *testing.T) {
+	us, f, db := newTestUserService(t)
+
+	if err := us.SetUserRole(f.Reader.ID, "owner"); !errors.Is(err, ErrInvalidRole) {
+		t.Errorf("unknown role: got %v", err)
+	}
+	if err := us.SetUserRole(999, models.RoleAdmin); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("missing user: got %v", err)
+	}
+	if err := us.SetUserRole(f.Reader.ID, models.RoleAdmin); err != nil {
+		t.Fatal(err)
+	}
+	if err := us.SetUserVerified(f.Newcomer.ID, true); err != nil {
+		t.Fatal(err)
+	}
+
+	db.Create(&models.Session{UserID: f.Author.ID, TokenID: "t1", FamilyID: "f1", ExpiresAt: time.Now().Add(time.Hour)})
+	if err := us.SetUserBanned(f.Author.ID, true); err != nil {
+		t.Fatal(err)
+	}
+
+	reader, _ := us.FindUserById(f.Reader.ID)
+	newcomer, _ := us.FindUserById(f.Newcomer.ID)
+	author, _ := us.FindUserById(f.Author.ID)
+	if reader.Role != models.RoleAdmin || !newcomer.Verified || author.BannedAt == nil {
+		t.Errorf("role %q, verified %v, banned at %v", reader.Role, newcomer.Verified, author.BannedAt)
+	}
+
+	var active int64
+	db.Model(&models.Session{}).Where("user_id = ? AND revoked_at IS NULL", f.Author.ID).Count(&active)
+	if active != 0 {
+		t.Error("banning left a session active")
+	}
+
+	if err := us.SetUserBanned(f.Author.ID, false); err != nil {
+		t.Fatal(err)
+	}
+	if author, _ := us.FindUserById(f.Author.ID); author.BannedAt != nil {
+		t.Error("user is still banned")
+	}
+}
+
+func TestReadingStats(t *testing.T) {
+	us, f, db := newTestUserService(t)
+
+	ended := time.Now().Add(-time.Minute)
+	session, err := us.RecordReadingSession(f.Reader.ID, models.ReadingSessionInput{
+		BookID: f.Fox.BookID, ChapterID: 1,
+		StartedAt: ended.Add(-2 * time.Minute), EndedAt: ended,
+		WordsRead: 600, WPM: 300,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session.ID == 0 {
+		t.Fatal("session was not stored")
+	}
+
+	_, err = us.RecordReadingSession(f.Reader.ID, models.ReadingSessionInput{
+		BookID: f.Fox.BookID, ChapterID: 1, StartedAt: ended, EndedAt: ended.Add(-time.Minute), WordsRead: 1, WPM: 1,
+	})
+	if !errors.Is(err, ErrInvalidSession) {
+		t.Errorf("session ending before it starts: got %v", err)
+	}
+
+	stats, err := us.GetReadingStats(f.Reader.ID, models.StatsQuery{Days: 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Sessions != 1 || stats.TotalWords != 600 || stats.AverageWPM != 300 || len(stats.Days) != 7 {
+		t.Errorf("stats = %+v", stats)
+	}
+
+	if _, err := us.GetReadingStats(f.Reader.ID, models.StatsQuery{TZ: "Mars/Olympus"}); !errors.Is(err, ErrInvalidTimeZone) {
+		t.Errorf("unknown time zone: got %v", err)
+	}
+
+	// Reading from before the period counts for streaks and the all-time
+	// speed only
+	long := ended.AddDate(0, 0, -20)
+	for _, start := range []time.Time{long.Add(-24 * time.Hour), long} {
+		_, err = us.RecordReadingSession(f.Author.ID, models.ReadingSessionInput{
+			BookID: f.Fox.BookID, ChapterID: 1, StartedAt: start.Add(-time.Minute), EndedAt: start, WordsRead: 200, WPM: 60,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	us.SaveBooksMark(f.Author.ID, f.Fox.BookID, 2, 3)
+	// A chapter from before word counts were stored
+	db.Exec("UPDATE chapters SET word_count = NULL WHERE id = ?", f.FoxChapters[1].ChapterID)
+
+	stats, err = us.GetReadingStats(f.Author.ID, models.StatsQuery{Days: 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Sessions != 0 || stats.AverageWPM != 0 || stats.LongestStreak != 2 || stats.CurrentStreak != 0 {
+		t.Errorf("stats = %+v", stats)
+	}
+	// The Fox has 19 + 12 words, 22 of them read, 9 left at 60 wpm
+	if len(stats.Books) != 1 || stats.Books[0].TotalWords != 31 || stats.Books[0].WordsRead != 22 || stats.Books[0].MinutesLeft != 0.2 {
+		t.Errorf("books = %+v", stats.Books)
+	}
+	var counted models.Chapter
+	db.First(&counted, f.FoxChapters[1].ChapterID)
+	if counted.WordCount == nil || *counted.WordCount != 12 {
+		t.Errorf("the word count of the chapter was not stored: %v", counted.WordCount)
+	}
+}
+
+func TestPreferences(t *testing.T) {
+	us, f, _ := newTestUserService(t)
+
+	prefs, err := us.GetPreferences(f.Reader.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prefs.WPM != models.DefaultPreferences().WPM {
+		t.Errorf("new user has %d wpm, want the default", prefs.WPM)
+	}
+
+	wpm, theme := 900, "dark"
+	if _, err := us.UpdatePreferences(f.Reader.ID, models.PreferencesInput{WPM: &wpm}); err != nil {
+		t.Fatal(err)
+	}
+	prefs, err = us.UpdatePreferences(f.Reader.ID, models.PreferencesInput{Theme: &theme})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prefs.WPM != 900 || prefs.Theme != "dark" || prefs.ChunkSize != 1 {
+		t.Errorf("prefs = %+v, want both changes on top of the defaults", prefs)
+	}
+}
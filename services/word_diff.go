@@ -0,0 +1,157 @@
+package services
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/st107853/fast_reading/models"
+)
+
+// maxDiffEdits bounds the work of diffWords. Texts further apart than that
+// are shown as the whole old text deleted and the new one inserted.
+const maxDiffEdits = 2000
+
+var diffTokenRe = regexp.MustCompile(`\S+|\s+`)
+
+// diffWords returns the word-level difference between two texts and the
+// number of words inserted and deleted. Whitespace runs are tokens of their
+// own, so the ops join back into both texts exactly.
+func diffWords(from, to string) (ops []models.DiffOp, inserted, deleted int) {
+	a := diffTokenRe.FindAllString(from, -1)
+	b := diffTokenRe.FindAllString(to, -1)
+
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	add := func(op, token string) {
+		if op != models.DiffEqual && strings.TrimSpace(token) != "" {
+			if op == models.DiffInsert {
+				inserted++
+			} else {
+				deleted++
+			}
+		}
+		if n := len(ops); n > 0 && ops[n-1].Op == op {
+			ops[n-1].Text += token
+			return
+		}
+		ops = append(ops, models.DiffOp{Op: op, Text: token})
+	}
+
+	for _, token := range a[:prefix] {
+		add(models.DiffEqual, token)
+	}
+	for _, step := range myers(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]) {
+		add(step.Op, step.Text)
+	}
+	for _, token := range a[len(a)-suffix:] {
+		add(models.DiffEqual, token)
+	}
+
+	if ops == nil {
+		ops = []models.DiffOp{}
+	}
+	return ops, inserted, deleted
+}
+
+// myers returns the shortest edit script turning a into b, one op per
+// token, using Myers' O(ND) algorithm.
+func myers(a, b []string) []models.DiffOp {
+	n, m := len(a), len(b)
+	if n == 0 || m == 0 {
+		return replaceAll(a, b)
+	}
+
+	limit := n + m
+	if limit > maxDiffEdits {
+		limit = maxDiffEdits
+	}
+
+	// v[k+offset] is the furthest x reached on diagonal k; trace[d] keeps
+	// the diagonals -d..d of v after d edits for the way back.
+	offset := limit + 1
+	v := make([]int, 2*offset+1)
+	var trace [][]int
+	for d := 0; d <= limit; d++ {
+		done := false
+		for k := -d; k <= d && !done; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			done = x >= n && y >= m
+		}
+		trace = append(trace, append([]int(nil), v[offset-d:offset+d+1]...))
+		if done {
+			return backtrack(a, b, trace)
+		}
+	}
+
+	return replaceAll(a, b)
+}
+
+// backtrack walks trace from the end of both sequences to their start.
+func backtrack(a, b []string, trace [][]int) []models.DiffOp {
+	x, y := len(a), len(b)
+	ops := make([]models.DiffOp, 0, x+y)
+	for d := len(trace) - 1; d > 0; d-- {
+		prev := trace[d-1]
+		at := func(k int) int { return prev[k+d-1] }
+
+		k := x - y
+		prevK := k - 1
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		}
+		prevX := at(prevK)
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			x--
+			y--
+			ops = append(ops, models.DiffOp{Op: models.DiffEqual, Text: a[x]})
+		}
+		if x == prevX {
+			y--
+			ops = append(ops, models.DiffOp{Op: models.DiffInsert, Text: b[y]})
+		} else {
+			x--
+			ops = append(ops, models.DiffOp{Op: models.DiffDelete, Text: a[x]})
+		}
+	}
+	for x > 0 {
+		x--
+		ops = append(ops, models.DiffOp{Op: models.DiffEqual, Text: a[x]})
+	}
+
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+	return ops
+}
+
+// replaceAll deletes every token of a and inserts every token of b.
+func replaceAll(a, b []string) []models.DiffOp {
+	ops := make([]models.DiffOp, 0, len(a)+len(b))
+	for _, token := range a {
+		ops = append(ops, models.DiffOp{Op: models.DiffDelete, Text: token})
+	}
+	for _, token := range b {
+		ops = append(ops, models.DiffOp{Op: models.DiffInsert, Text: token})
+	}
+	return ops
+}
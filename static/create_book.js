@@ -306,9 +306,10 @@ async function submitChapter(button, bookId, chapterId) {
             return;
         }
 
-        // Update chapter
+        // Update chapter; the server discarded the draft
         if (chapterId && response.status === 200) {
             button.classList.add('clicked');
+            lastSavedDraft = JSON.stringify(payload);
             return;
         }
 
@@ -322,6 +323,189 @@ async function submitChapter(button, bookId, chapterId) {
 }
 
 
+// Draft autosave: while the editor content changes it is saved every few
+// seconds, and a draft left from an earlier visit is offered back on open.
+// Autosave waits until the user restores or discards that draft.
+const draftBanner = document.getElementById('draft-banner');
+const DRAFT_INTERVAL_MS = 5000;
+let pendingDraft = null;
+let lastSavedDraft = '';
+
+function draftUrl() {
+    const bookId = encodeURIComponent(draftBanner.dataset.bookId);
+    const chapterId = draftBanner.dataset.chapterId;
+    return chapterId
+        ? `/library/addbook/${bookId}/chapter/${encodeURIComponent(chapterId)}/draft`
+        : `/library/addbook/${bookId}/chapter/draft`;
+}
+
+function editorContent() {
+    return {
+        title: document.getElementById('chapter-name').value.trim(),
+        text: document.getElementById('scrollable-content-reading').value
+    };
+}
+
+async function autosaveDraft() {
+    const body = JSON.stringify(editorContent());
+    if (pendingDraft || body === lastSavedDraft) {
+        return;
+    }
+
+    try {
+        const response = await fetch(draftUrl(), {
+            method: "PUT",
+            headers: { "Content-Type": "application/json" },
+            credentials: "include",
+            body
+        });
+        if (response.ok) {
+            lastSavedDraft = body;
+        }
+    } catch (err) {
+        console.error("Error of saving the draft:", err);
+    }
+}
+
+async function checkDraft() {
+    lastSavedDraft = JSON.stringify(editorContent());
+
+    try {
+        const response = await fetch(draftUrl(), { credentials: "include" });
+        if (response.ok) {
+            const draft = await response.json();
+            if (JSON.stringify({ title: draft.title, text: draft.text }) !== lastSavedDraft) {
+                pendingDraft = draft;
+                document.getElementById('draft-message').textContent =
+                    `You have unsaved changes from ${new Date(draft.updated_at).toLocaleString()}. Restore the draft?`;
+                draftBanner.hidden = false;
+            }
+        }
+    } catch (err) {
+        console.error("Error of loading the draft:", err);
+    }
+
+    setInterval(autosaveDraft, DRAFT_INTERVAL_MS);
+}
+
+function restoreDraft() {
+    document.getElementById('chapter-name').value = pendingDraft.title;
+    document.getElementById('scrollable-content-reading').value = pendingDraft.text;
+    lastSavedDraft = JSON.stringify(editorContent());
+    pendingDraft = null;
+    draftBanner.hidden = true;
+}
+
+async function discardDraft() {
+    try {
+        await fetch(draftUrl(), { method: "DELETE", credentials: "include" });
+    } catch (err) {
+        console.error("Error of discarding the draft:", err);
+    }
+    pendingDraft = null;
+    draftBanner.hidden = true;
+}
+
+if (draftBanner) {
+    checkDraft();
+}
+
+// Chapter history: list the revisions, show what each one changed and
+// restore an older one
+function revisionsUrl(history) {
+    return `/library/addbook/${encodeURIComponent(history.dataset.bookId)}/chapter/${encodeURIComponent(history.dataset.chapterId)}/revisions`;
+}
+
+async function loadRevisions(history) {
+    if (!history.open) {
+        return;
+    }
+
+    const list = document.getElementById('revision-list');
+    try {
+        const response = await fetch(revisionsUrl(history), { credentials: "include" });
+        if (!response.ok) {
+            throw new Error(await response.text());
+        }
+        const { revisions } = await response.json();
+
+        list.innerHTML = '';
+        revisions.forEach((revision, i) => {
+            const item = document.createElement('li');
+            const when = new Date(revision.created_at).toLocaleString();
+            const who = revision.user_name || 'original';
+            item.textContent = `${when}, ${who}, ${revision.words} words` +
+                (revision.restored_from ? ` (restored #${revision.restored_from})` : '');
+
+            // Each revision is compared with the one before it
+            const older = revisions[i + 1];
+            if (older) {
+                const diff = document.createElement('button');
+                diff.type = 'button';
+                diff.textContent = 'Changes';
+                diff.onclick = () => showRevisionDiff(history, older.id, revision.id);
+                item.append(' ', diff);
+            }
+            if (i > 0) {
+                const restore = document.createElement('button');
+                restore.type = 'button';
+                restore.textContent = 'Restore';
+                restore.onclick = () => restoreRevision(history, revision.id);
+                item.append(' ', restore);
+            }
+            list.appendChild(item);
+        });
+    } catch (err) {
+        console.error("Error of loading revisions:", err);
+    }
+}
+
+async function showRevisionDiff(history, from, to) {
+    const view = document.getElementById('revision-diff');
+    try {
+        const response = await fetch(`${revisionsUrl(history)}/diff?from=${from}&to=${to}`, { credentials: "include" });
+        if (!response.ok) {
+            throw new Error(await response.text());
+        }
+        const diff = await response.json();
+
+        view.innerHTML = '';
+        diff.ops.forEach(op => {
+            const tag = op.op === 'insert' ? 'ins' : op.op === 'delete' ? 'del' : 'span';
+            const run = document.createElement(tag);
+            run.textContent = op.text;
+            view.appendChild(run);
+        });
+    } catch (err) {
+        console.error("Error of loading the diff:", err);
+    }
+}
+
+async function restoreRevision(history, id) {
+    if (!confirm("Replace the chapter with this revision? The current text stays in the history.")) {
+        return;
+    }
+
+    try {
+        const response = await fetch(`${revisionsUrl(history)}/${id}/restore`, {
+            method: "POST",
+            credentials: "include"
+        });
+        if (!response.ok) {
+            throw new Error(await response.text());
+        }
+        const chapter = await response.json();
+
+        document.getElementById('chapter-name').value = chapter.title;
+        document.getElementById('scrollable-content-reading').value = chapter.text;
+        document.getElementById('revision-diff').innerHTML = '';
+        loadRevisions(history);
+    } catch (err) {
+        console.error("Error of restoring the revision:", err);
+    }
+}
+
+
 // Handle book deletion
 function deleteBook(id) {
     if (!id) {
@@ -31,17 +31,22 @@ function toggleLabel(event) {
 }
 
 
+// Cursor of the next page for every filter code, set from the last response
+const nextCursors = {};
+
 // Main function to apply filters and fetch results
 // Filter code:
 // 0 - regular
 // 1 - continue reading
 // 2 - created
 // 3 - favourite
-async function applyFilters(code) {
+// With append set the next page is added below the books already shown.
+async function applyFilters(code, append = false) {
     await wait(100); // Small delay to ensure label id updates before fetching
 
     const keywordInput = document.getElementById('keyword-input');
     const keyword = keywordInput ? keywordInput.value.trim() : '';
+    const sortSelect = document.getElementById('sort-select');
     const resultsContainer = getResultsContainer(code);
     
     if (!resultsContainer) {
@@ -49,7 +54,10 @@ async function applyFilters(code) {
         return;
     }
 
-    resultsContainer.innerHTML = '<p>Searching...</p>';
+    if (!append) {
+        resultsContainer.innerHTML = '<p>Searching...</p>';
+        nextCursors[code] = '';
+    }
     
     let queryParams = new URLSearchParams();
 
@@ -63,6 +71,16 @@ async function applyFilters(code) {
         queryParams.append('labels', selectedLabelIds.join(','));
     }
 
+    // 3. Sort order, e.g. "name" or "popularity"
+    if (sortSelect && sortSelect.value) {
+        queryParams.append('sort', sortSelect.value);
+    }
+
+    // 4. Page to continue from
+    if (append && nextCursors[code]) {
+        queryParams.append('cursor', nextCursors[code]);
+    }
+
     queryParams.append('code', code);
 
     const url = `/library/filter/?${queryParams.toString()}`;
@@ -73,9 +91,11 @@ async function applyFilters(code) {
             throw new Error(`Server responded with status ${response.status}`);
         }
 
-        const books = await response.json();
-        
-        renderResults(books, resultsContainer);
+        const page = await response.json();
+        nextCursors[code] = page.next_cursor || '';
+
+        renderResults(page.books, resultsContainer, append);
+        renderLoadMore(code, resultsContainer);
 
     } catch (error) {
         console.error('Search failed:', error);
@@ -83,6 +103,26 @@ async function applyFilters(code) {
     }
 }
 
+// Shows a "Load more" button after the results while there is a next page
+function renderLoadMore(code, container) {
+    const id = `${container.id}-more`;
+    let button = document.getElementById(id);
+
+    if (!nextCursors[code]) {
+        if (button) button.remove();
+        return;
+    }
+
+    if (!button) {
+        button = document.createElement('button');
+        button.id = id;
+        button.className = 'fr-btn fr-btn--large';
+        button.textContent = 'Load more';
+        button.addEventListener('click', () => applyFilters(code, true));
+        container.after(button);
+    }
+}
+
 function getResultsContainer(code) {
     if (code === 2) {
         return document.getElementById('created-results-container');
@@ -102,14 +142,16 @@ function clearSelectedLabels() {
 }
 
 // Function to render results, matching the structure
-function renderResults(books, container) {
+function renderResults(books, container, append = false) {
     if (!container) {
         console.warn('renderResults: no results container found');
         return;
     }
-    container.innerHTML = ''; // Clearing container
+    if (!append) {
+        container.innerHTML = ''; // Clearing container
+    }
 
-    if (books.length === 0) {
+    if (books.length === 0 && !append) {
         container.innerHTML = '<p class="col-span-full text-gray-500">Nothing found, try changing the filters.</p>';
         return;
     }
@@ -122,7 +164,7 @@ function renderResults(books, container) {
         bookElement.innerHTML = `
             <a href="/library/book/${book.id}">
                 ${book.cover_path
-                    ? `<img src="/covers/${book.cover_path}" alt="book cover" class="book-cover">` 
+                    ? `<img src="/covers/${book.cover_thumb_path || book.cover_path}" alt="book cover" class="book-cover">` 
                     : `<div class="fr-blue-box">${book.name}</div>`
                 }
             </a>
@@ -3,6 +3,15 @@ const registerForm = document.getElementById("register-form");
 const formTitle = document.getElementById("form-title");
 const toggleText = document.getElementById("toggle-text");
 const toggleLink = document.getElementById("toggle-link");
+const forgotLink = document.getElementById("forgot-link");
+const resetForm = document.getElementById("reset-form");
+const resetToken = new URLSearchParams(window.location.search).get("reset_token");
+
+if (resetToken) {
+    formTitle.textContent = "Choose a New Password";
+    loginForm.classList.add("fr-hidden");
+    resetForm.classList.remove("fr-hidden");
+}
 
 let isLogin = true;
 
@@ -95,3 +104,56 @@ registerForm.addEventListener("submit", async (e) => {
         console.error(err);
     }
 });
+
+forgotLink.addEventListener("click", async () => {
+    const email = prompt("Enter the email of your account", document.getElementById("login-email").value);
+    if (!email) {
+        return;
+    }
+
+    try {
+        const response = await fetch("/library/auth/forgot-password", {
+            method: "POST",
+            headers: { "Content-Type": "application/json" },
+            body: JSON.stringify({ email })
+        });
+
+        const data = await response.json();
+        alert(data.message || data.error);
+    } catch (err) {
+        alert("Something went wrong, please try again");
+        console.error(err);
+    }
+});
+
+resetForm.addEventListener("submit", async (e) => {
+    e.preventDefault();
+
+    const password = document.getElementById("reset-password").value;
+    const passwordConfirm = document.getElementById("reset-confirm-password").value;
+
+    if (password !== passwordConfirm) {
+        alert("Passwords do not match");
+        return;
+    }
+
+    try {
+        const response = await fetch("/library/auth/reset-password", {
+            method: "POST",
+            headers: { "Content-Type": "application/json" },
+            body: JSON.stringify({ token: resetToken, password, passwordConfirm })
+        });
+
+        const data = await response.json();
+        if (!response.ok) {
+            alert(data.message || data.error || "Could not reset password");
+            return;
+        }
+
+        alert(data.message);
+        window.location.href = "/library/auth/login";
+    } catch (err) {
+        alert("Something went wrong, please try again");
+        console.error(err);
+    }
+});
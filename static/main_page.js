@@ -42,100 +42,3 @@ if (document.readyState === 'loading') {
   // DOM already loaded
   initScrollSync();
 }
-
-// Add event listeners to filter labels
-document.addEventListener('DOMContentLoaded', () => {
-    document.querySelectorAll('.fr-label').forEach(label => {
-        label.addEventListener('click', toggleLabel);
-    });
-});
-
-// Array to store selected label IDs
-let selectedLabelIds = [];
-
-// Function to toggle label state
-function toggleLabel(event) {
-    const labelElement = event.currentTarget;
-    const labelId = labelElement.getAttribute('data-id');
-
-    // Toggle 'selected' class
-    labelElement.classList.toggle('selected');
-
-    if (labelElement.classList.contains('selected')) {
-        // If selected, add ID to array
-        selectedLabelIds.push(labelId);
-    } else {
-        // If deselected, remove ID from array
-        selectedLabelIds = selectedLabelIds.filter(id => id !== labelId);
-    }
-    
-    // applyFilters(); 
-}
-
-// Main function to apply filters and fetch results
-async function applyFilters(readingOnly) {
-    const keyword = document.getElementById('keyword-input').value.trim();
-    const resultsContainer = document.getElementById('results-container');
-    
-    resultsContainer.innerHTML = '<p>Searching...</p>';
-    
-    let queryParams = new URLSearchParams();
-
-    // 1. Keyword
-    if (keyword) {
-        queryParams.append('keyword', keyword); 
-    }
-
-    // 2. Selected labels (passed as comma-separated)
-    if (selectedLabelIds.length > 0) {
-        queryParams.append('labels', selectedLabelIds.join(','));
-    }
-
-    queryParams.append('reading_only', readingOnly);
-
-    const url = `/library/filter/?${queryParams.toString()}`;
-
-    try {
-        const response = await fetch(url);
-        if (!response.ok) {
-            throw new Error(`Server responded with status ${response.status}`);
-        }
-
-        const books = await response.json();
-        
-        renderResults(books);
-
-    } catch (error) {
-        console.error('Search failed:', error);
-        resultsContainer.innerHTML = `<p class="text-red-500">Search error: ${error.message}</p>`;
-    }
-}
-
-// Function to render results, matching the structure
-function renderResults(books) {
-    const container = document.getElementById('results-container');
-    container.innerHTML = ''; // Clearing container
-
-    if (books.length === 0) {
-        container.innerHTML = '<p class="col-span-full text-gray-500">Nothing found, try changing the filters.</p>';
-        return;
-    }
-
-    books.forEach(book => {
-        // Creating fr-card element
-        const bookElement = document.createElement('div');
-        bookElement.className = 'fr-card bg-white rounded-xl shadow-md p-2 transition-shadow duration-300 hover:shadow-lg'; 
-        
-        bookElement.innerHTML = `
-            <a href="/library/book/${book.id}">
-                ${book.cover_path
-                    ? `<img src="${book.cover_path}" alt="book cover" class="book-cover">` 
-                    : `<div class="fr-blue-box">${book.name}</div>`
-                }
-            </a>
-            <div class="fr-card__title">${book.name}</div>
-            <div class="fr-card__author">${book.author}</div>
-        `;
-        container.appendChild(bookElement);
-    });
-}
\ No newline at end of file
@@ -1,5 +1,18 @@
+// Reader settings of a signed-in user. The reader pages set window.frPreferences
+// from the server; changes are sent back so every device shares them.
+window.savePreferences = function(changes) {
+    if (window.frPreferences) Object.assign(window.frPreferences, changes);
+    if (!window.isLoggedIn()) return Promise.resolve();
+    return fetch('/library/users/me/preferences', {
+        method: 'PUT',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify(changes),
+    }).catch(err => console.error("Error saving preferences:", err));
+};
+
 // Main logic for style and settings handling
 document.addEventListener('DOMContentLoaded', () => {
+    const serverPrefs = window.frPreferences || null;
     
     // --- DOM Elements ---
     const openButtons = document.querySelectorAll('[data-modal-target]');
@@ -87,6 +100,7 @@ document.addEventListener('DOMContentLoaded', () => {
     });
 
     // Swatch Initialization & Events
+    if (serverPrefs && serverPrefs.colour) saveSwatch(serverPrefs.colour);
     const initialSavedSwatch = getSavedSwatch();
     if (initialSavedSwatch) applySwatch(initialSavedSwatch);
 
@@ -105,6 +119,7 @@ document.addEventListener('DOMContentLoaded', () => {
                 if (newColor) {
                     applySwatch(newColor);
                     saveSwatch(newColor);
+                    window.savePreferences({ colour: newColor });
                 }
             });
             swatch.addEventListener('keydown', (e) => {
@@ -120,6 +135,12 @@ document.addEventListener('DOMContentLoaded', () => {
     const THEME_KEY = 'fr_theme';
     const getSavedTheme = () => { try { return localStorage.getItem(THEME_KEY); } catch (e) { return null; } };
     const saveTheme = (theme) => { try { localStorage.setItem(THEME_KEY, theme); } catch (e) {} };
+    if (serverPrefs) {
+        // The "system" theme follows the device setting
+        try {
+            if (serverPrefs.theme === 'system') localStorage.removeItem(THEME_KEY); else saveTheme(serverPrefs.theme);
+        } catch (e) {}
+    }
     const prefersDark = () => window.matchMedia && window.matchMedia('(prefers-color-scheme: dark)').matches;
 
     const applyTheme = (theme) => {
@@ -141,6 +162,7 @@ document.addEventListener('DOMContentLoaded', () => {
             const theme = e.target.checked ? 'dark' : 'light';
             applyTheme(theme);
             saveTheme(theme);
+            window.savePreferences({ theme });
         }));
     }
 
@@ -163,6 +185,10 @@ document.addEventListener('DOMContentLoaded', () => {
 
     const saveTextPref = (key, value) => { try { localStorage.setItem(key, value); } catch (e) {} };
     const getSavedTextPref = (key) => { try { return localStorage.getItem(key); } catch (e) { return null; } };
+    if (serverPrefs) {
+        if (serverPrefs.font_family) saveTextPref(FONT_FAMILY_KEY, serverPrefs.font_family);
+        if (serverPrefs.font_size) saveTextPref(FONT_SIZE_KEY, serverPrefs.font_size);
+    }
     
     //Loads saved settings and applies them via CSS variables
     const applyTextStyle = () => {
@@ -191,6 +217,7 @@ document.addEventListener('DOMContentLoaded', () => {
         fontSelect.addEventListener('change', (e) => {
             saveTextPref(FONT_FAMILY_KEY, e.target.value);
             applyTextStyle(); 
+            window.savePreferences({ font_family: e.target.value });
         });
     }
 
@@ -198,6 +225,7 @@ document.addEventListener('DOMContentLoaded', () => {
         sizeSelect.addEventListener('change', (e) => {
             saveTextPref(FONT_SIZE_KEY, e.target.value);
             applyTextStyle(); 
+            window.savePreferences({ font_size: e.target.value });
         });
     }
     
@@ -236,6 +264,43 @@ window.login = function() {
     }
 };
 
+// Bookmarks saved while offline, sent to the server once it is reachable.
+// The server keeps the latest bookmark of each book.
+const BOOKMARK_QUEUE_KEY = 'fr_bookmark_queue';
+
+const getQueuedBookmarks = () => {
+    try { return JSON.parse(localStorage.getItem(BOOKMARK_QUEUE_KEY)) || []; } catch (e) { return []; }
+};
+
+window.queueBookmark = function(bookmark) {
+    if (!window.isLoggedIn()) return;
+    const queue = getQueuedBookmarks().filter(b => b.book_id !== bookmark.book_id);
+    queue.push(bookmark);
+    try { localStorage.setItem(BOOKMARK_QUEUE_KEY, JSON.stringify(queue)); } catch (e) {}
+};
+
+window.syncBookmarks = async function() {
+    const bookmarks = getQueuedBookmarks();
+    if (!bookmarks.length || !window.isLoggedIn() || !navigator.onLine) return;
+    try {
+        const response = await fetch('/api/v1/progress/sync', {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ bookmarks }),
+        });
+        if (!response.ok && response.status !== 400) return;
+        // Keep bookmarks queued while the request was running
+        const sent = new Set(bookmarks.map(b => `${b.book_id}@${b.updated_at}`));
+        const rest = getQueuedBookmarks().filter(b => !sent.has(`${b.book_id}@${b.updated_at}`));
+        localStorage.setItem(BOOKMARK_QUEUE_KEY, JSON.stringify(rest));
+    } catch (err) {
+        console.error("Error syncing bookmarks:", err);
+    }
+};
+
+window.addEventListener('online', () => window.syncBookmarks());
+document.addEventListener('DOMContentLoaded', () => window.syncBookmarks());
+
 // Scroll synchronization logic
 document.addEventListener('DOMContentLoaded', initializeScrollSync);
 
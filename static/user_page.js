@@ -16,7 +16,7 @@ document.addEventListener('DOMContentLoaded', function () {
         var tabButtons = document.querySelectorAll('[data-target]');
         if (!tabButtons || tabButtons.length === 0) return;
 
-        var panels = function () { return document.querySelectorAll('#favBooks, #createdBooks'); };
+        var panels = function () { return document.querySelectorAll('#favBooks, #createdBooks, #readingStats'); };
 
         tabButtons.forEach(function (btn) {
             btn.addEventListener('click', function (ev) {
@@ -30,6 +30,7 @@ document.addEventListener('DOMContentLoaded', function () {
                 // show the requested panel
                 var show = document.querySelector(target);
                 if (show) show.classList.remove('fr-hidden');
+                if (target === '#readingStats') loadReadingStats();
 
                 // clear selected filter labels when switching tab
                 if (typeof clearSelectedLabels === 'function') {
@@ -45,4 +46,61 @@ document.addEventListener('DOMContentLoaded', function () {
     } catch (e) {
         console.error('user_page toggle init error', e);
     }
-});
\ No newline at end of file
+});
+
+// Reading statistics, loaded the first time the tab is opened
+var readingStatsLoaded = false;
+
+async function loadReadingStats() {
+    if (readingStatsLoaded) return;
+    readingStatsLoaded = true;
+
+    var summary = document.getElementById('stats-summary');
+    var tz = Intl.DateTimeFormat().resolvedOptions().timeZone || 'UTC';
+
+    try {
+        var response = await fetch('/library/users/me/stats?days=30&tz=' + encodeURIComponent(tz));
+        if (!response.ok) throw new Error('Server responded with status ' + response.status);
+        renderReadingStats((await response.json()).data);
+    } catch (err) {
+        readingStatsLoaded = false;
+        console.error('Failed to load reading statistics:', err);
+        summary.innerHTML = '<p>Could not load statistics.</p>';
+    }
+}
+
+function renderReadingStats(stats) {
+    var escape = function (s) {
+        var div = document.createElement('div');
+        div.textContent = s;
+        return div.innerHTML;
+    };
+
+    document.getElementById('stats-summary').innerHTML = [
+        ['Words read', stats.total_words],
+        ['Minutes', stats.total_minutes],
+        ['Average WPM', stats.average_wpm],
+        ['Max WPM', stats.max_wpm],
+        ['Current streak', stats.current_streak + ' days'],
+        ['Longest streak', stats.longest_streak + ' days'],
+    ].map(function (item) {
+        return '<div class="up-stat"><span class="up-stat__value">' + item[1] + '</span><span class="up-stat__name">' + item[0] + '</span></div>';
+    }).join('');
+
+    var most = Math.max.apply(null, stats.days.map(function (d) { return d.words; }).concat([1]));
+    document.getElementById('stats-chart').innerHTML = stats.days.map(function (d) {
+        var title = d.date + ': ' + d.words + ' words' + (d.words ? ', ' + d.average_wpm + ' WPM on average, ' + d.max_wpm + ' max' : '');
+        return '<div class="up-stats-bar" title="' + title + '" style="height:' + (100 * d.words / most) + '%"></div>';
+    }).join('');
+
+    var books = document.getElementById('stats-books');
+    if (stats.books.length === 0) {
+        books.innerHTML = '<p>You have not started any books yet.</p>';
+        return;
+    }
+    books.innerHTML = stats.books.map(function (b) {
+        var left = b.minutes_left ? Math.ceil(b.minutes_left) + ' min left' : 'read a little to get an estimate';
+        return '<div class="up-stats-book"><a href="/library/book/' + b.book.id + '">' + escape(b.book.name) + '</a>'
+            + '<span>' + b.percent + '% · ' + left + '</span></div>';
+    }).join('');
+}
@@ -0,0 +1,51 @@
+// Package storage keeps uploaded files such as book covers in a pluggable
+// blob store: the local disk for development, an S3-compatible bucket when
+// several API replicas share the files.
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path"
+	"strings"
+)
+
+// BlobStore stores blobs under slash-separated keys like "covers/12.jpeg".
+type BlobStore interface {
+	// Put stores the blob, replacing any blob with the same key. Size may be
+	// -1 when it is not known.
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Get opens the blob. It returns ErrNotFound when there is no such key.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes the blob. Deleting a missing key is not an error.
+	Delete(ctx context.Context, key string) error
+	// URL returns an absolute URL clients can download the blob from, or ""
+	// when the blob has to be served through the application.
+	URL(key string) string
+}
+
+var (
+	ErrNotFound   = errors.New("storage: blob not found")
+	ErrInvalidKey = errors.New("storage: invalid key")
+)
+
+// cleanKey rejects keys that could escape the store, such as "../app.env".
+func cleanKey(key string) (string, error) {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return "", ErrInvalidKey
+	}
+	clean := path.Clean(key)
+	if clean != key || clean == "." || clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", ErrInvalidKey
+	}
+	return clean, nil
+}
+
+// joinURL appends a key to a public base URL.
+func joinURL(base, key string) string {
+	if base == "" {
+		return ""
+	}
+	return strings.TrimRight(base, "/") + "/" + key
+}
@@ -0,0 +1,116 @@
+package testutil
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/st107853/fast_reading/models"
+	"github.com/st107853/fast_reading/utils"
+	"gorm.io/gorm"
+)
+
+// Password is the password of every fixture user.
+const Password = "password123"
+
+// Fixtures are the rows Seed creates. Fox and Poems are released, Draft is
+// not; Author created Fox and Draft, Admin created Poems.
+type Fixtures struct {
+	Admin, Author, Reader *models.User
+	// Newcomer has not verified their email address yet.
+	Newcomer *models.User
+
+	Fiction, Poetry, Science *models.Label
+
+	Fox, Poems, Draft *models.Book
+	// FoxChapters are the chapters of Fox in reading order.
+	FoxChapters  []*models.Chapter
+	PoemsChapter *models.Chapter
+	DraftChapter *models.Chapter
+}
+
+var (
+	hashOnce sync.Once
+	hash     string
+	hashErr  error
+)
+
+// Seed fills db with the fixtures.
+func Seed(t testing.TB, db *gorm.DB) *Fixtures {
+	t.Helper()
+
+	// bcrypt is slow on purpose, so every user shares one hash
+	hashOnce.Do(func() { hash, hashErr = utils.HashPassword(Password) })
+	if hashErr != nil {
+		t.Fatalf("testutil: hash password: %v", hashErr)
+	}
+
+	f := &Fixtures{
+		Admin:    &models.User{Name: "Ada Admin", Email: "admin@example.com", Role: models.RoleAdmin, Verified: true},
+		Author:   &models.User{Name: "Anna Author", Email: "author@example.com", Role: models.RoleUser, Verified: true},
+		Reader:   &models.User{Name: "Roman Reader", Email: "reader@example.com", Role: models.RoleUser, Verified: true},
+		Newcomer: &models.User{Name: "Nina Newcomer", Email: "newcomer@example.com", Role: models.RoleUser},
+
+		Fiction: &models.Label{Name: "Fiction"},
+		Poetry:  &models.Label{Name: "Poetry"},
+		Science: &models.Label{Name: "Science"},
+	}
+	for _, user := range []*models.User{f.Admin, f.Author, f.Reader, f.Newcomer} {
+		user.Password = hash
+		create(t, db, user)
+	}
+	for _, label := range []*models.Label{f.Fiction, f.Poetry, f.Science} {
+		create(t, db, label)
+	}
+
+	released := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	f.Fox = &models.Book{
+		BookBase:        models.BookBase{Name: "The Quick Fox", Author: "Anna Author"},
+		ReleaseDate:     released,
+		PublicationYear: 2023,
+		Released:        true,
+		Description:     "A fox, a dog and a very long jump.",
+		CreatorUserID:   f.Author.ID,
+		BookLabels:      []*models.Label{f.Fiction},
+	}
+	f.Poems = &models.Book{
+		BookBase:        models.BookBase{Name: "Collected Poems", Author: "Ada Admin"},
+		ReleaseDate:     released.AddDate(0, 1, 0),
+		PublicationYear: 1999,
+		Released:        true,
+		Description:     "Short verses about rivers.",
+		CreatorUserID:   f.Admin.ID,
+		BookLabels:      []*models.Label{f.Poetry},
+	}
+	f.Draft = &models.Book{
+		BookBase:      models.BookBase{Name: "Unfinished Notes", Author: "Anna Author"},
+		Description:   "Not ready yet.",
+		CreatorUserID: f.Author.ID,
+	}
+	for _, book := range []*models.Book{f.Fox, f.Poems, f.Draft} {
+		create(t, db, book)
+	}
+
+	f.FoxChapters = []*models.Chapter{
+		{BookID: f.Fox.BookID, Title: "The Jump", ChapterOrder: 1,
+			Text: "The quick brown fox jumps over the lazy dog. The dog does not move.\n\nThen the fox runs home."},
+		{BookID: f.Fox.BookID, Title: "The Return", ChapterOrder: 2,
+			Text: "The next morning, the fox came back; the dog was still asleep."},
+	}
+	f.PoemsChapter = &models.Chapter{BookID: f.Poems.BookID, Title: "Rivers", ChapterOrder: 1,
+		Text: "Rivers run to the sea, and the sea runs to the sky."}
+	f.DraftChapter = &models.Chapter{BookID: f.Draft.BookID, Title: "Notes", ChapterOrder: 1,
+		Text: "Some notes about nothing in particular."}
+	for _, chapter := range append(f.FoxChapters, f.PoemsChapter, f.DraftChapter) {
+		create(t, db, chapter)
+	}
+
+	return f
+}
+
+func create(t testing.TB, db *gorm.DB, value interface{}) {
+	t.Helper()
+	if err := db.Create(value).Error; err != nil {
+		t.Fatalf("testutil: create %T: %v", value, err)
+	}
+}
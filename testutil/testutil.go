@@ -0,0 +1,105 @@
+// Package testutil sets up what tests of the services and the HTTP routes
+// share: a migrated throwaway SQLite database, a config with signing keys
+// and a fixed set of users, books, chapters and labels.
+package testutil
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/st107853/fast_reading/config"
+	"github.com/st107853/fast_reading/migrations"
+	"github.com/st107853/fast_reading/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// OpenDB returns a database in the test's temporary directory with every
+// migration applied. It is closed when the test ends.
+func OpenDB(t testing.TB) *gorm.DB {
+	t.Helper()
+
+	db, err := gorm.Open(mustDialector(t, filepath.Join(t.TempDir(), "test.db")), &gorm.Config{
+		Logger: logger.Discard,
+	})
+	if err != nil {
+		t.Fatalf("testutil: open database: %v", err)
+	}
+	t.Cleanup(func() { models.RemoveDb(db) })
+
+	m, err := migrations.New(db)
+	if err != nil {
+		t.Fatalf("testutil: load migrations: %v", err)
+	}
+	if _, err := m.Up(); err != nil {
+		t.Fatalf("testutil: migrate: %v", err)
+	}
+
+	return db
+}
+
+func mustDialector(t testing.TB, path string) gorm.Dialector {
+	t.Helper()
+
+	dialector, err := models.Dialector(config.Config{DBDriver: models.DriverSQLite, DBname: path})
+	if err != nil {
+		t.Fatalf("testutil: %v", err)
+	}
+	return dialector
+}
+
+var (
+	keysOnce sync.Once
+	keys     [2]string // base64 PEM private and public key
+	keysErr  error
+)
+
+// Config returns the settings the application needs in tests: token keys
+// and lifetimes. Generating a key is slow, so all tests share one pair.
+func Config(t testing.TB) config.Config {
+	t.Helper()
+
+	keysOnce.Do(func() {
+		var key *rsa.PrivateKey
+		key, keysErr = rsa.GenerateKey(rand.Reader, 2048)
+		if keysErr != nil {
+			return
+		}
+		var public []byte
+		public, keysErr = x509.MarshalPKIXPublicKey(&key.PublicKey)
+		if keysErr != nil {
+			return
+		}
+		keys[0] = encodePEM("RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(key))
+		keys[1] = encodePEM("PUBLIC KEY", public)
+	})
+	if keysErr != nil {
+		t.Fatalf("testutil: generate keys: %v", keysErr)
+	}
+
+	return config.Config{
+		DBDriver:               models.DriverSQLite,
+		Port:                   "8080",
+		Host:                   "localhost",
+		AppURL:                 "http://localhost:8080",
+		AccessTokenPrivateKey:  keys[0],
+		AccessTokenPublicKey:   keys[1],
+		RefreshTokenPrivateKey: keys[0],
+		RefreshTokenPublicKey:  keys[1],
+		AccessTokenExpiresIn:   15 * time.Minute,
+		RefreshTokenExpiresIn:  time.Hour,
+		AccessTokenMaxAge:      15,
+		RefreshTokenMaxAge:     60,
+	}
+}
+
+func encodePEM(kind string, der []byte) string {
+	return base64.StdEncoding.EncodeToString(pem.EncodeToMemory(&pem.Block{Type: kind, Bytes: der}))
+}
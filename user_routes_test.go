@@ -0,0 +1,76 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestUserPage(t *testing.T) {
+	app := newTestApp(t)
+	c := app.signIn(app.Author.Email)
+
+	w := c.do(http.MethodGet, "/library/users/me", nil)
+	expect(t, w, http.StatusOK)
+	if !strings.Contains(w.Body.String(), app.Fox.Name) {
+		t.Error("user page does not list the books the user created")
+	}
+}
+
+func TestReadingSessionRoutes(t *testing.T) {
+	app := newTestApp(t)
+	c := app.signIn(app.Reader.Email)
+
+	ended := time.Now().Add(-time.Minute)
+	session := gin.H{
+		"book_id": app.Fox.BookID, "chapter_id": 1,
+		"started_at": ended.Add(-2 * time.Minute), "ended_at": ended,
+		"words_read": 500, "wpm": 250,
+	}
+	expect(t, c.do(http.MethodPost, "/library/users/me/reading-sessions", session), http.StatusCreated)
+	expect(t, app.guest().do(http.MethodPost, "/library/users/me/reading-sessions", session), http.StatusUnauthorized)
+
+	session["book_id"] = 999
+	expect(t, c.do(http.MethodPost, "/library/users/me/reading-sessions", session), http.StatusNotFound)
+
+	var stats struct {
+		Data struct {
+			Sessions   int `json:"sessions"`
+			TotalWords int `json:"total_words"`
+		} `json:"data"`
+	}
+	w := c.do(http.MethodGet, "/library/users/me/stats?days=7", nil)
+	expect(t, w, http.StatusOK)
+	decode(t, w, &stats)
+	if stats.Data.Sessions != 1 || stats.Data.TotalWords != 500 {
+		t.Errorf("stats = %+v", stats.Data)
+	}
+	expect(t, c.do(http.MethodGet, "/library/users/me/stats?tz=Nowhere/Special", nil), http.StatusBadRequest)
+}
+
+func TestPreferenceRoutes(t *testing.T) {
+	app := newTestApp(t)
+	c := app.signIn(app.Reader.Email)
+
+	var prefs struct {
+		Data struct {
+			WPM   int    `json:"wpm"`
+			Theme string `json:"theme"`
+		} `json:"data"`
+	}
+
+	expect(t, c.do(http.MethodPut, "/library/users/me/preferences", gin.H{"wpm": 720, "theme": "dark"}), http.StatusOK)
+	expect(t, c.do(http.MethodPut, "/library/users/me/preferences", gin.H{"wpm": 5}), http.StatusBadRequest)
+
+	w := c.do(http.MethodGet, "/library/users/me/preferences", nil)
+	expect(t, w, http.StatusOK)
+	decode(t, w, &prefs)
+	if prefs.Data.WPM != 720 || prefs.Data.Theme != "dark" {
+		t.Errorf("preferences = %+v", prefs.Data)
+	}
+
+	expect(t, app.guest().do(http.MethodGet, "/library/users/me/preferences", nil), http.StatusUnauthorized)
+}
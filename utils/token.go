@@ -1,7 +1,10 @@
 package utils
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"fmt"
 	"time"
 
@@ -9,6 +12,12 @@ import (
 )
 
 func CreateToken(ttl time.Duration, payload interface{}, privateKey string) (string, error) {
+	return CreateTokenWithID(ttl, payload, "", privateKey)
+}
+
+// CreateTokenWithID creates a token carrying tokenID in its "jti" claim so it
+// can be looked up and revoked server-side.
+func CreateTokenWithID(ttl time.Duration, payload interface{}, tokenID string, privateKey string) (string, error) {
 	decodedPrivateKey, err := base64.StdEncoding.DecodeString(privateKey)
 	if err != nil {
 		return "", fmt.Errorf("could not decode key: %w", err)
@@ -26,6 +35,9 @@ func CreateToken(ttl time.Duration, payload interface{}, privateKey string) (str
 	claims["exp"] = now.Add(ttl).Unix()
 	claims["iat"] = now.Unix()
 	claims["nbf"] = now.Unix()
+	if tokenID != "" {
+		claims["jti"] = tokenID
+	}
 
 	token, err := jwt.NewWithClaims(jwt.SigningMethodRS256, claims).SignedString(key)
 
@@ -37,15 +49,21 @@ func CreateToken(ttl time.Duration, payload interface{}, privateKey string) (str
 }
 
 func ValidateToken(token string, publicKey string) (interface{}, error) {
+	sub, _, err := ValidateTokenWithID(token, publicKey)
+	return sub, err
+}
+
+// ValidateTokenWithID validates the token and returns its subject and "jti" claim.
+func ValidateTokenWithID(token string, publicKey string) (interface{}, string, error) {
 	decodedPublicKey, err := base64.StdEncoding.DecodeString(publicKey)
 	if err != nil {
-		return nil, fmt.Errorf("could not decode: %w", err)
+		return nil, "", fmt.Errorf("could not decode: %w", err)
 	}
 
 	key, err := jwt.ParseRSAPublicKeyFromPEM(decodedPublicKey)
 
 	if err != nil {
-		return "", fmt.Errorf("validate: parse key: %w", err)
+		return nil, "", fmt.Errorf("validate: parse key: %w", err)
 	}
 
 	parsedToken, err := jwt.Parse(token, func(t *jwt.Token) (interface{}, error) {
@@ -56,13 +74,41 @@ func ValidateToken(token string, publicKey string) (interface{}, error) {
 	})
 
 	if err != nil {
-		return nil, fmt.Errorf("validate: %w", err)
+		return nil, "", fmt.Errorf("validate: %w", err)
 	}
 
 	claims, ok := parsedToken.Claims.(jwt.MapClaims)
 	if !ok || !parsedToken.Valid {
-		return nil, fmt.Errorf("validate: invalid token")
+		return nil, "", fmt.Errorf("validate: invalid token")
+	}
+
+	tokenID, _ := claims["jti"].(string)
+
+	return claims["sub"], tokenID, nil
+}
+
+// NewTokenID returns a random identifier for the "jti" claim.
+func NewTokenID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("could not generate token id: %w", err)
 	}
+	return hex.EncodeToString(b), nil
+}
+
+// NewSecretToken returns a random URL-safe token for emailed links together
+// with the hash to store in its place.
+func NewSecretToken() (token string, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", fmt.Errorf("could not generate token: %w", err)
+	}
+	token = base64.RawURLEncoding.EncodeToString(b)
+	return token, HashSecretToken(token), nil
+}
 
-	return claims["sub"], nil
+// HashSecretToken returns the stored form of a token from NewSecretToken.
+func HashSecretToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
 }